@@ -0,0 +1,327 @@
+package easytls
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// DefaultAuthorityLifetime is how long a generated Certificate Authority
+	// is valid for, if no explicit lifetime is given.
+	DefaultAuthorityLifetime = time.Hour * 24 * 365
+
+	// DefaultCertificateLifetime is how long an issued leaf certificate is
+	// valid for, if no explicit lifetime is given.
+	DefaultCertificateLifetime = time.Hour * 24 * 90
+)
+
+// CertificateOptions defines the properties of a certificate to be generated
+// by a CertificateAuthority.
+type CertificateOptions struct {
+
+	// CommonName is the Subject Common Name of the certificate.
+	CommonName string
+
+	// Optional: The Organization and Organizational Unit of the Subject.
+	Organization       []string
+	OrganizationalUnit []string
+
+	// Optional: The Subject Alternative Names of the certificate.
+	DNSNames    []string
+	IPAddresses []net.IP
+	URIs        []*url.URL
+
+	// Optional: How long the certificate will be valid for.
+	Lifetime time.Duration
+
+	// Optional: The Key Usages of the certificate. If not set, these default
+	// to the usages appropriate to the kind of certificate being issued.
+	KeyUsage    x509.KeyUsage
+	ExtKeyUsage []x509.ExtKeyUsage
+}
+
+// IssuedCertificate is a single certificate and its matching private key.
+type IssuedCertificate struct {
+	Certificate *x509.Certificate
+	Key         crypto.Signer
+}
+
+// CertificateAuthority is a simple self-signed root Certificate Authority,
+// able to issue server and client certificates.
+//
+// This is intended for development and testing, to allow for building
+// working TLSBundles without any external tooling.
+type CertificateAuthority struct {
+	IssuedCertificate
+}
+
+// NewCertificateAuthority will generate a new self-signed root Certificate
+// Authority, with the given Common Name and Lifetime.
+func NewCertificateAuthority(CommonName string, Lifetime time.Duration) (*CertificateAuthority, error) {
+
+	if Lifetime <= 0 {
+		Lifetime = DefaultAuthorityLifetime
+	}
+
+	Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	Template, err := newCertificateTemplate(CertificateOptions{
+		CommonName: CommonName,
+		Lifetime:   Lifetime,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	Template.IsCA = true
+	Template.BasicConstraintsValid = true
+	Template.MaxPathLenZero = true
+	Template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+
+	Cert, err := createCertificate(Template, Template, Key.Public(), Key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CertificateAuthority{IssuedCertificate{Certificate: Cert, Key: Key}}, nil
+}
+
+// LoadCertificateAuthority will load an existing Certificate Authority from
+// the given PEM-encoded certificate and key files, to issue new certificates.
+func LoadCertificateAuthority(CertificateFile, KeyFile string) (*CertificateAuthority, error) {
+
+	Pair, err := tls.LoadX509KeyPair(CertificateFile, KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	Cert, err := x509.ParseCertificate(Pair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if !Cert.IsCA {
+		return nil, fmt.Errorf("easytls error: Certificate [ %s ] is not a Certificate Authority", CertificateFile)
+	}
+
+	Key, ok := Pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("easytls error: Key [ %s ] cannot be used for signing", KeyFile)
+	}
+
+	return &CertificateAuthority{IssuedCertificate{Certificate: Cert, Key: Key}}, nil
+}
+
+// IssueServerCertificate will issue a new certificate, signed by the
+// Certificate Authority, suitable for use by a server.
+func (CA *CertificateAuthority) IssueServerCertificate(Options CertificateOptions) (*IssuedCertificate, error) {
+
+	if len(Options.ExtKeyUsage) == 0 {
+		Options.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+
+	if len(Options.DNSNames) == 0 && len(Options.IPAddresses) == 0 && len(Options.URIs) == 0 {
+		return nil, errors.New("easytls error: Server certificates require at least one Subject Alternative Name")
+	}
+
+	return CA.Issue(Options)
+}
+
+// IssueClientCertificate will issue a new certificate, signed by the
+// Certificate Authority, suitable for use by a client.
+func (CA *CertificateAuthority) IssueClientCertificate(Options CertificateOptions) (*IssuedCertificate, error) {
+
+	if len(Options.ExtKeyUsage) == 0 {
+		Options.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+
+	return CA.Issue(Options)
+}
+
+// Issue will issue a new certificate with exactly the given options, signed by
+// the Certificate Authority.
+func (CA *CertificateAuthority) Issue(Options CertificateOptions) (*IssuedCertificate, error) {
+
+	if Options.Lifetime <= 0 {
+		Options.Lifetime = DefaultCertificateLifetime
+	}
+
+	if Options.KeyUsage == 0 {
+		Options.KeyUsage = x509.KeyUsageDigitalSignature
+	}
+
+	Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	Template, err := newCertificateTemplate(Options)
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't issue certificates which outlive the authority itself.
+	if Template.NotAfter.After(CA.Certificate.NotAfter) {
+		Template.NotAfter = CA.Certificate.NotAfter
+	}
+
+	Cert, err := createCertificate(Template, CA.Certificate, Key.Public(), CA.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IssuedCertificate{Certificate: Cert, Key: Key}, nil
+}
+
+// CreateCRL will generate a PEM-encoded Certificate Revocation List, signed by
+// the Certificate Authority, listing the given certificates as revoked. The
+// list is valid for the given Lifetime, or one day if not set.
+func (CA *CertificateAuthority) CreateCRL(Lifetime time.Duration, Revoked ...*x509.Certificate) ([]byte, error) {
+
+	if Lifetime <= 0 {
+		Lifetime = time.Hour * 24
+	}
+
+	Now := time.Now()
+	Template := &x509.RevocationList{
+		Number:     big.NewInt(Now.UnixNano()),
+		ThisUpdate: Now.Add(-time.Minute),
+		NextUpdate: Now.Add(Lifetime),
+	}
+
+	for _, Cert := range Revoked {
+		Template.RevokedCertificates = append(Template.RevokedCertificates, pkix.RevokedCertificate{
+			SerialNumber:   Cert.SerialNumber,
+			RevocationTime: Now,
+		})
+	}
+
+	Raw, err := x509.CreateRevocationList(rand.Reader, Template, CA.Certificate, CA.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: Raw}), nil
+}
+
+// NewTLSBundle will write out the given certificate along with the certificate
+// of the Authority into Folder, and return a TLSBundle using these files.
+//
+// The files are named <Name>.crt, <Name>.key, and ca.crt respectively.
+func (CA *CertificateAuthority) NewTLSBundle(Leaf *IssuedCertificate, Folder, Name string) (*TLSBundle, error) {
+
+	if err := os.MkdirAll(Folder, 0700); err != nil {
+		return nil, err
+	}
+
+	CertificateFile := filepath.Join(Folder, Name+".crt")
+	KeyFile := filepath.Join(Folder, Name+".key")
+	AuthorityFile := filepath.Join(Folder, "ca.crt")
+
+	if err := Leaf.WriteFiles(CertificateFile, KeyFile); err != nil {
+		return nil, err
+	}
+
+	if err := ioutil.WriteFile(AuthorityFile, CA.CertificatePEM(), 0644); err != nil {
+		return nil, err
+	}
+
+	return NewTLSBundle(CertificateFile, KeyFile, AuthorityFile), nil
+}
+
+// CertificatePEM returns the PEM encoding of the certificate.
+func (C *IssuedCertificate) CertificatePEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: C.Certificate.Raw})
+}
+
+// KeyPEM returns the PEM encoding of the private key, in PKCS #8 form.
+func (C *IssuedCertificate) KeyPEM() ([]byte, error) {
+
+	Raw, err := x509.MarshalPKCS8PrivateKey(C.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: Raw}), nil
+}
+
+// WriteFiles will write out the PEM encoded certificate and key to the given files.
+// The key file is only readable by the current user.
+func (C *IssuedCertificate) WriteFiles(CertificateFile, KeyFile string) error {
+
+	Key, err := C.KeyPEM()
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(CertificateFile, C.CertificatePEM(), 0644); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(KeyFile, Key, 0600)
+}
+
+// TLSCertificate returns the certificate and key as a tls.Certificate.
+func (C *IssuedCertificate) TLSCertificate() tls.Certificate {
+	return tls.Certificate{
+		Certificate: [][]byte{C.Certificate.Raw},
+		PrivateKey:  C.Key,
+		Leaf:        C.Certificate,
+	}
+}
+
+func newCertificateTemplate(Options CertificateOptions) (*x509.Certificate, error) {
+
+	// Serial numbers must be unique per-CA, so use the full 128 bits of randomness.
+	Serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	// Back-date the certificate slightly, to allow for clock skew between hosts.
+	Now := time.Now()
+
+	return &x509.Certificate{
+		SerialNumber: Serial,
+		Subject: pkix.Name{
+			CommonName:         Options.CommonName,
+			Organization:       Options.Organization,
+			OrganizationalUnit: Options.OrganizationalUnit,
+		},
+		NotBefore:   Now.Add(-time.Minute),
+		NotAfter:    Now.Add(Options.Lifetime),
+		KeyUsage:    Options.KeyUsage,
+		ExtKeyUsage: Options.ExtKeyUsage,
+		DNSNames:    Options.DNSNames,
+		IPAddresses: Options.IPAddresses,
+		URIs:        Options.URIs,
+	}, nil
+}
+
+func createCertificate(Template, Parent *x509.Certificate, Public crypto.PublicKey, Signer crypto.Signer) (*x509.Certificate, error) {
+
+	Raw, err := x509.CreateCertificate(rand.Reader, Template, Parent, Public, Signer)
+	if err != nil {
+		return nil, err
+	}
+
+	return x509.ParseCertificate(Raw)
+}
@@ -0,0 +1,169 @@
+package easytls_test
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+	"github.com/Bearnie-H/easy-tls/client"
+	"github.com/Bearnie-H/easy-tls/server"
+)
+
+func testLogger() easytls.Logger {
+	return easytls.NewDiscardLogger()
+}
+
+// newTestAuthority creates a CA, along with server and client bundles issued from it.
+func newTestAuthority(T *testing.T) (CA *easytls.CertificateAuthority, ServerBundle, ClientBundle *easytls.TLSBundle) {
+
+	Folder := T.TempDir()
+
+	CA, err := easytls.NewCertificateAuthority("EasyTLS Test CA", 0)
+	if err != nil {
+		T.Fatalf("Failed to create CA - %s", err)
+	}
+
+	ServerCert, err := CA.IssueServerCertificate(easytls.CertificateOptions{
+		CommonName:  "server",
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	})
+	if err != nil {
+		T.Fatalf("Failed to issue server certificate - %s", err)
+	}
+
+	ClientCert, err := CA.IssueClientCertificate(easytls.CertificateOptions{CommonName: "client"})
+	if err != nil {
+		T.Fatalf("Failed to issue client certificate - %s", err)
+	}
+
+	if ServerBundle, err = CA.NewTLSBundle(ServerCert, Folder, "server"); err != nil {
+		T.Fatalf("Failed to write server bundle - %s", err)
+	}
+	ServerBundle.Auth = tls.RequireAndVerifyClientCert
+
+	if ClientBundle, err = CA.NewTLSBundle(ClientCert, Folder, "client"); err != nil {
+		T.Fatalf("Failed to write client bundle - %s", err)
+	}
+
+	return CA, ServerBundle, ClientBundle
+}
+
+// startTestServer serves a single "/hello" route over TLS, returning the base URL.
+func startTestServer(T *testing.T, Bundle *easytls.TLSBundle) string {
+
+	S, err := server.NewServerHTTPS(Bundle)
+	if err != nil {
+		T.Fatalf("Failed to create server - %s", err)
+	}
+	S.SetStructuredLogger(testLogger())
+
+	S.AddHandlers(S.Router(), server.NewSimpleHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}), "/hello", http.MethodGet))
+
+	L, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		T.Fatalf("Failed to listen - %s", err)
+	}
+
+	go S.Serve(tls.NewListener(L, S.TLSConfig))
+	T.Cleanup(func() { S.Shutdown() })
+
+	return "https://" + L.Addr().String()
+}
+
+func TestMutualTLS(T *testing.T) {
+
+	_, ServerBundle, ClientBundle := newTestAuthority(T)
+	URL := startTestServer(T, ServerBundle)
+
+	C, err := client.NewClientHTTPS(ClientBundle)
+	if err != nil {
+		T.Fatalf("Failed to create client - %s", err)
+	}
+
+	resp, err := C.Get(URL+"/hello", nil)
+	if err != nil {
+		T.Fatalf("Failed to perform mutual TLS request - %s", err)
+	}
+	defer resp.Body.Close()
+
+	Body, _ := ioutil.ReadAll(resp.Body)
+	if string(Body) != "client" {
+		T.Fatalf("Expected server to see client identity [ client ], got [ %s ]", Body)
+	}
+
+	// A client without a certificate must be rejected.
+	Anonymous, err := client.NewClientHTTPS(easytls.NewTLSBundle("", "", ClientBundle.AuthorityCertificates...))
+	if err != nil {
+		T.Fatalf("Failed to create client - %s", err)
+	}
+
+	if resp, err := Anonymous.Get(URL+"/hello", nil); err == nil {
+		resp.Body.Close()
+		T.Fatalf("Expected request without client certificate to fail")
+	}
+}
+
+func TestCertificateReload(T *testing.T) {
+
+	CA, ServerBundle, ClientBundle := newTestAuthority(T)
+
+	Events := make(chan easytls.ReloadEvent, 4)
+	ServerBundle.Reload = easytls.ReloadPolicy{
+		Enabled:  true,
+		Interval: time.Nanosecond,
+		OnReload: func(E easytls.ReloadEvent) { Events <- E },
+		Logger:   testLogger(),
+	}
+
+	URL := startTestServer(T, ServerBundle)
+
+	ServerSerial := func() string {
+		C, err := client.NewClientHTTPS(ClientBundle)
+		if err != nil {
+			T.Fatalf("Failed to create client - %s", err)
+		}
+		resp, err := C.Get(URL+"/hello", nil)
+		if err != nil {
+			T.Fatalf("Failed to perform request - %s", err)
+		}
+		resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].SerialNumber.String()
+	}
+
+	Before := ServerSerial()
+
+	// Rotate the server certificate on disk.
+	Rotated, err := CA.IssueServerCertificate(easytls.CertificateOptions{CommonName: "server", DNSNames: []string{"localhost"}, IPAddresses: []net.IP{net.ParseIP("127.0.0.1")}})
+	if err != nil {
+		T.Fatalf("Failed to issue rotated certificate - %s", err)
+	}
+	if err := Rotated.WriteFiles(ServerBundle.KeyPair.Certificate, ServerBundle.KeyPair.Key); err != nil {
+		T.Fatalf("Failed to write rotated certificate - %s", err)
+	}
+
+	if After := ServerSerial(); After == Before || After != Rotated.Certificate.SerialNumber.String() {
+		T.Fatalf("Expected rotated certificate to be served, got serial [ %s ]", After)
+	}
+	if E := <-Events; E.Err != nil {
+		T.Fatalf("Expected successful reload, got - %s", E.Err)
+	}
+
+	// Corrupt certificates must be rejected, leaving the previous ones in use.
+	if err := ioutil.WriteFile(ServerBundle.KeyPair.Certificate, []byte("not a certificate"), 0644); err != nil {
+		T.Fatalf("Failed to corrupt certificate - %s", err)
+	}
+
+	if After := ServerSerial(); After != Rotated.Certificate.SerialNumber.String() {
+		T.Fatalf("Expected previous certificate to remain in use, got serial [ %s ]", After)
+	}
+	if E := <-Events; E.Err == nil {
+		T.Fatalf("Expected corrupt certificate to be rejected")
+	}
+}
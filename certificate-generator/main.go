@@ -0,0 +1,149 @@
+// Command certificate-generator implements a basic command-line utility for
+// creating a development Certificate Authority, and issuing server and client
+// certificates from it.
+package main
+
+import (
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+)
+
+// Define the command-line flags
+var (
+	OutputFolderFlag = flag.String("out", "./", "The folder to write the generated certificates and keys into.")
+	AuthorityFlag    = flag.String("ca", "ca", "The base name of the Certificate Authority files (<ca>.crt and <ca>.key) within the output folder. These are created if they don't exist.")
+	AuthorityCNFlag  = flag.String("ca-name", "EasyTLS Development CA", "The Common Name to use if a new Certificate Authority is created.")
+	NameFlag         = flag.String("name", "", "The base name of the certificate files to issue. If empty, only the Certificate Authority is created.")
+	CommonNameFlag   = flag.String("cn", "", "The Common Name of the issued certificate. Defaults to the value of -name.")
+	SANFlag          = flag.String("san", "", "Comma-separated list of Subject Alternative Names (DNS names, IP addresses or URIs) for the issued certificate.")
+	ClientFlag       = flag.Bool("client", false, "Issue a client certificate.")
+	ServerFlag       = flag.Bool("server", false, "Issue a server certificate.")
+	LifetimeFlag     = flag.Duration("lifetime", easytls.DefaultCertificateLifetime, "How long the issued certificate will be valid for.")
+)
+
+func main() {
+	flag.Parse()
+
+	CA, err := loadOrCreateAuthority()
+	if err != nil {
+		fmt.Printf("Error: Failed to prepare Certificate Authority - %s.\n", err)
+		os.Exit(1)
+	}
+
+	if *NameFlag == "" {
+		return
+	}
+
+	Options := easytls.CertificateOptions{
+		CommonName: *CommonNameFlag,
+		Lifetime:   *LifetimeFlag,
+	}
+
+	if Options.CommonName == "" {
+		Options.CommonName = *NameFlag
+	}
+
+	if err := parseSANs(*SANFlag, &Options); err != nil {
+		fmt.Printf("Error: Failed to parse Subject Alternative Names - %s.\n", err)
+		os.Exit(1)
+	}
+
+	switch {
+	case *ClientFlag && *ServerFlag:
+		Options.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+		err = issue(CA.IssueServerCertificate, Options)
+	case *ClientFlag:
+		err = issue(CA.IssueClientCertificate, Options)
+	default:
+		err = issue(CA.IssueServerCertificate, Options)
+	}
+
+	if err != nil {
+		fmt.Printf("Error: Failed to issue certificate [ %s ] - %s.\n", *NameFlag, err)
+		os.Exit(1)
+	}
+}
+
+// loadOrCreateAuthority will load the Certificate Authority from the output
+// folder, creating and writing out a new one if it does not exist.
+func loadOrCreateAuthority() (*easytls.CertificateAuthority, error) {
+
+	CertificateFile := filepath.Join(*OutputFolderFlag, *AuthorityFlag+".crt")
+	KeyFile := filepath.Join(*OutputFolderFlag, *AuthorityFlag+".key")
+
+	if _, err := os.Stat(CertificateFile); err == nil {
+		fmt.Printf("Using existing Certificate Authority %s.\n", CertificateFile)
+		return easytls.LoadCertificateAuthority(CertificateFile, KeyFile)
+	}
+
+	CA, err := easytls.NewCertificateAuthority(*AuthorityCNFlag, easytls.DefaultAuthorityLifetime)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(*OutputFolderFlag, 0700); err != nil {
+		return nil, err
+	}
+
+	if err := CA.WriteFiles(CertificateFile, KeyFile); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Created new Certificate Authority %s.\n", CertificateFile)
+
+	return CA, nil
+}
+
+func issue(Issuer func(easytls.CertificateOptions) (*easytls.IssuedCertificate, error), Options easytls.CertificateOptions) error {
+
+	Cert, err := Issuer(Options)
+	if err != nil {
+		return err
+	}
+
+	CertificateFile := filepath.Join(*OutputFolderFlag, *NameFlag+".crt")
+	KeyFile := filepath.Join(*OutputFolderFlag, *NameFlag+".key")
+
+	if err := Cert.WriteFiles(CertificateFile, KeyFile); err != nil {
+		return err
+	}
+
+	fmt.Printf("Issued certificate %s (valid until %s).\n", CertificateFile, Cert.Certificate.NotAfter.Format(time.RFC3339))
+
+	return nil
+}
+
+// parseSANs will sort the comma-separated list of names into the DNS, IP and URI
+// Subject Alternative Names of the certificate.
+func parseSANs(Names string, Options *easytls.CertificateOptions) error {
+
+	for _, Name := range strings.Split(Names, ",") {
+
+		Name = strings.TrimSpace(Name)
+
+		switch {
+		case Name == "":
+		case net.ParseIP(Name) != nil:
+			Options.IPAddresses = append(Options.IPAddresses, net.ParseIP(Name))
+		case strings.Contains(Name, "://"):
+			URI, err := url.Parse(Name)
+			if err != nil {
+				return err
+			}
+			Options.URIs = append(Options.URIs, URI)
+		default:
+			Options.DNSNames = append(Options.DNSNames, Name)
+		}
+	}
+
+	return nil
+}
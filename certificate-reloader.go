@@ -0,0 +1,385 @@
+package easytls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultReloadInterval is the minimum time between checks for changes to
+// the files of a TLSBundle, if no explicit interval is provided.
+const DefaultReloadInterval = time.Minute
+
+// ReloadPolicy defines whether and how the files referenced by a TLSBundle are
+// re-read while the tls.Config built from it is in use.
+//
+// The files are polled lazily: at most once per Interval, the next TLS
+// handshake will check whether any of the files have changed on disk. If so,
+// the full set of resources is re-read, and swapped in for all subsequent
+// handshakes. If the new resources fail to load or parse, they are rejected
+// and the previous resources remain in use.
+//
+// Clients only verify servers against reloaded authorities for connections
+// dialed through DialTLSContext, as a SimpleClient does. Connections made
+// with the tls.Config directly use the authorities loaded when it was created.
+type ReloadPolicy struct {
+
+	// Enabled turns on watching and reloading of the TLS resources.
+	Enabled bool
+
+	// Interval is the minimum time between checks of the files on disk.
+	// Defaults to DefaultReloadInterval if not set.
+	Interval time.Duration
+
+	// OnReload is an optional callback, called after every attempt to swap in
+	// new TLS resources, whether successful or not.
+	OnReload func(ReloadEvent)
+
+	// Logger is where rotation messages will be written.
+	// Defaults to NewDefaultStructuredLogger() if not set.
+	Logger Logger
+}
+
+// ReloadEvent describes a single attempt to swap in changed TLS resources.
+type ReloadEvent struct {
+
+	// The files which were detected to have changed.
+	Files []string
+
+	// When the change was detected.
+	Time time.Time
+
+	// Err is non-nil if the new resources were rejected.
+	Err error
+}
+
+// fileVersion is the set of properties used to detect a changed file.
+type fileVersion struct {
+	modified time.Time
+	size     int64
+}
+
+// certificateReloader holds the most recently accepted set of TLS resources for
+// a TLSBundle, and swaps in new ones as the underlying files change.
+type certificateReloader struct {
+	bundle TLSBundle
+	logger Logger
+
+	mu           *sync.RWMutex
+	lastCheck    time.Time
+	versions     map[string]fileVersion
+	certificates []tls.Certificate
+	roots        *x509.CertPool
+	clients      *x509.CertPool
+}
+
+func newCertificateReloader(TLS *TLSBundle, Certificates []tls.Certificate, Roots, Clients *x509.CertPool) *certificateReloader {
+
+	r := &certificateReloader{
+		bundle:       *TLS,
+		logger:       TLS.Reload.Logger,
+		mu:           &sync.RWMutex{},
+		lastCheck:    time.Now(),
+		versions:     make(map[string]fileVersion),
+		certificates: Certificates,
+		roots:        Roots,
+		clients:      Clients,
+	}
+
+	if r.bundle.Reload.Interval <= 0 {
+		r.bundle.Reload.Interval = DefaultReloadInterval
+	}
+
+	if r.logger == nil {
+		r.logger = NewDefaultStructuredLogger()
+	}
+
+	r.versions, _ = r.stat()
+
+	return r
+}
+
+// configure will replace the static resources of the tls.Config with
+// callbacks to retrieve the current resources from the reloader.
+func (r *certificateReloader) configure(Config *tls.Config) {
+
+	// Certificates must be empty for GetCertificate to always be consulted.
+	Config.Certificates = nil
+	Config.GetCertificate = r.getCertificate
+	Config.GetClientCertificate = r.getClientCertificate
+
+	// Clients verify servers against RootCAs, which are fixed once the
+	// connection starts. The Config keeps the built-in verification against
+	// the authorities loaded now, and DialTLSContext verifies against the
+	// current set instead.
+	if len(r.bundle.serverAuthorityFiles()) > 0 {
+		reloadingConfigs.Store(Config, r)
+	}
+
+	// Servers verify clients against ClientCAs, which can only be swapped
+	// per-connection by returning a whole new tls.Config. This is cloned from
+	// the live Config, so any hooks added after this are carried over.
+	Config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.check()
+		C := Config.Clone()
+		C.GetConfigForClient = nil
+		C.ClientCAs = r.currentClientAuthorities()
+		return C, nil
+	}
+}
+
+func (r *certificateReloader) getCertificate(Hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.check()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return selectCertificate(Hello, r.certificates)
+}
+
+func (r *certificateReloader) getClientCertificate(Request *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.check()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return selectClientCertificate(Request, r.certificates)
+}
+
+// selectCertificate picks the first of the Certificates supported by the client.
+func selectCertificate(Hello *tls.ClientHelloInfo, Certificates []tls.Certificate) (*tls.Certificate, error) {
+
+	if len(Certificates) == 0 {
+		return nil, errors.New("easytls error: No certificate available")
+	}
+
+	for i := range Certificates {
+		if Hello.SupportsCertificate(&Certificates[i]) == nil {
+			return &Certificates[i], nil
+		}
+	}
+
+	// If none are explicitly supported, let the handshake fail on the first.
+	return &Certificates[0], nil
+}
+
+// selectClientCertificate picks the first of the Certificates acceptable to the server.
+func selectClientCertificate(Request *tls.CertificateRequestInfo, Certificates []tls.Certificate) (*tls.Certificate, error) {
+
+	for i := range Certificates {
+		if Request.SupportsCertificate(&Certificates[i]) == nil {
+			return &Certificates[i], nil
+		}
+	}
+
+	// Sending no certificate is valid, and leaves the server to decide.
+	return &tls.Certificate{}, nil
+}
+
+func (r *certificateReloader) currentRootAuthorities() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.roots
+}
+
+func (r *certificateReloader) currentClientAuthorities() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.clients
+}
+
+// verifyServer performs the standard verification of the certificate chain
+// presented by a server, against the current set of authorities, returning
+// the verified chains. As the built-in verification is skipped, any
+// VerifyPeerCertificate callback is called from here instead.
+func (r *certificateReloader) verifyServer(State tls.ConnectionState, ServerName string, VerifyPeer func([][]byte, [][]*x509.Certificate) error) ([][]*x509.Certificate, error) {
+	r.check()
+
+	if len(State.PeerCertificates) == 0 {
+		return nil, errors.New("easytls error: Server presented no certificates")
+	}
+
+	// An empty name would skip the check of the identity of the server.
+	if ServerName == "" {
+		return nil, errors.New("easytls error: No server name available to verify the server certificate against")
+	}
+
+	Options := x509.VerifyOptions{
+		Roots:         r.currentRootAuthorities(),
+		DNSName:       ServerName,
+		Intermediates: x509.NewCertPool(),
+	}
+
+	Raw := make([][]byte, 0, len(State.PeerCertificates))
+	for i, Cert := range State.PeerCertificates {
+		Raw = append(Raw, Cert.Raw)
+		if i > 0 {
+			Options.Intermediates.AddCert(Cert)
+		}
+	}
+
+	Chains, err := State.PeerCertificates[0].Verify(Options)
+	if err != nil {
+		return nil, err
+	}
+
+	if VerifyPeer != nil {
+		if err := VerifyPeer(Raw, Chains); err != nil {
+			return nil, err
+		}
+	}
+
+	return Chains, nil
+}
+
+// reloadingConfigs holds the reloader of each tls.Config which reloads the
+// authorities used to verify servers, for DialTLSContext to use. These are
+// expected to live as long as the process, so are never removed.
+var reloadingConfigs = &sync.Map{}
+
+// DialTLSContext returns a function, suitable for http.Transport.DialTLSContext,
+// which dials TLS connections with a copy of the Config naming the host being
+// dialed as the server, unless the Config already names one.
+//
+// If the Config was created by NewTLSConfig and reloads its authorities, the
+// server is verified against the current set of them, rather than those
+// loaded when the Config was created.
+func DialTLSContext(Config *tls.Config) func(ctx context.Context, Network, Address string) (net.Conn, error) {
+
+	var r *certificateReloader
+	if R, ok := reloadingConfigs.Load(Config); ok {
+		r = R.(*certificateReloader)
+	}
+
+	return func(ctx context.Context, Network, Address string) (net.Conn, error) {
+
+		C := Config.Clone()
+		if C == nil {
+			C = &tls.Config{}
+		}
+
+		if C.ServerName == "" {
+			Host, _, err := net.SplitHostPort(Address)
+			if err != nil {
+				Host = Address
+			}
+			C.ServerName = Host
+		}
+
+		// Skip the built-in verification, which would call the
+		// VerifyPeerCertificate callback itself, and verify here instead.
+		if r != nil && !C.InsecureSkipVerify {
+			ServerName, VerifyPeer, Verify := C.ServerName, C.VerifyPeerCertificate, C.VerifyConnection
+			C.InsecureSkipVerify = true
+			C.VerifyPeerCertificate = nil
+			C.VerifyConnection = func(State tls.ConnectionState) error {
+				Chains, err := r.verifyServer(State, ServerName, VerifyPeer)
+				if err != nil {
+					return err
+				}
+				if Verify != nil {
+					State.VerifiedChains = Chains
+					return Verify(State)
+				}
+				return nil
+			}
+		}
+
+		return (&tls.Dialer{Config: C}).DialContext(ctx, Network, Address)
+	}
+}
+
+// check will, at most once per Interval, look for changes to the watched files
+// and reload the resources if any are found.
+func (r *certificateReloader) check() {
+
+	r.mu.Lock()
+	if time.Since(r.lastCheck) < r.bundle.Reload.Interval {
+		r.mu.Unlock()
+		return
+	}
+	r.lastCheck = time.Now()
+	r.mu.Unlock()
+
+	Versions, err := r.stat()
+	if err != nil {
+		r.report(ReloadEvent{Time: time.Now(), Err: err})
+		return
+	}
+
+	Changed := []string{}
+	r.mu.RLock()
+	for Filename, V := range Versions {
+		if r.versions[Filename] != V {
+			Changed = append(Changed, Filename)
+		}
+	}
+	r.mu.RUnlock()
+
+	if len(Changed) == 0 {
+		return
+	}
+
+	r.reload(ReloadEvent{Files: Changed, Time: time.Now()}, Versions)
+}
+
+// reload will attempt to load the full set of resources, only swapping them
+// in if they all load successfully.
+func (r *certificateReloader) reload(Event ReloadEvent, Versions map[string]fileVersion) {
+
+	Certificates, err := r.bundle.loadCertificates()
+	if err != nil {
+		Event.Err = err
+		r.report(Event)
+		return
+	}
+
+	Roots, Clients, err := r.bundle.loadAuthorities()
+	if err != nil {
+		Event.Err = err
+		r.report(Event)
+		return
+	}
+
+	r.mu.Lock()
+	r.certificates = Certificates
+	r.roots = Roots
+	r.clients = Clients
+	r.versions = Versions
+	r.mu.Unlock()
+
+	r.report(Event)
+}
+
+func (r *certificateReloader) report(Event ReloadEvent) {
+
+	if Event.Err != nil {
+		r.logger.Error("Rejected changed TLS resources", "files", Event.Files, "error", Event.Err)
+	} else {
+		r.logger.Info("Reloaded changed TLS resources", "files", Event.Files)
+	}
+
+	if r.bundle.Reload.OnReload != nil {
+		r.bundle.Reload.OnReload(Event)
+	}
+}
+
+// stat reads the current version of all of the watched files.
+func (r *certificateReloader) stat() (map[string]fileVersion, error) {
+
+	Versions := make(map[string]fileVersion)
+
+	for _, Filename := range r.bundle.watchedFiles() {
+		Info, err := r.bundle.statFile(Filename)
+		if err != nil {
+			return nil, err
+		}
+		Versions[Filename] = fileVersion{modified: Info.ModTime(), size: Info.Size()}
+	}
+
+	return Versions, nil
+}
@@ -0,0 +1,150 @@
+package easytls_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+	"github.com/Bearnie-H/easy-tls/client"
+)
+
+func TestReloadVerifiesServerName(T *testing.T) {
+
+	CA, ServerBundle, ClientBundle := newTestAuthority(T)
+	ClientBundle.Reload = easytls.ReloadPolicy{Enabled: true, Logger: testLogger()}
+
+	// The certificate of the server is valid, but for a different address.
+	Other, err := CA.IssueServerCertificate(easytls.CertificateOptions{CommonName: "other", IPAddresses: []net.IP{net.ParseIP("10.9.9.9")}})
+	if err != nil {
+		T.Fatalf("Failed to issue server certificate - %s", err)
+	}
+	MismatchBundle := easytls.NewTLSBundleFromPEM(Other.CertificatePEM(), mustKeyPEM(T, Other), CA.CertificatePEM())
+	MismatchBundle.Auth = tls.RequireAndVerifyClientCert
+
+	C, err := client.NewClientHTTPS(ClientBundle)
+	if err != nil {
+		T.Fatalf("Failed to create client - %s", err)
+	}
+
+	if resp, err := C.Get(startTestServer(T, MismatchBundle)+"/hello", nil); err == nil {
+		resp.Body.Close()
+		T.Fatalf("Expected a server certificate for another address to be rejected")
+	}
+
+	resp, err := C.Get(startTestServer(T, ServerBundle)+"/hello", nil)
+	if err != nil {
+		T.Fatalf("Expected a server certificate for the dialed address to be accepted - %s", err)
+	}
+	resp.Body.Close()
+
+	// Using the Config directly must still check the identity of the server,
+	// even for an IP address, which is never sent in the handshake.
+	Config, err := easytls.NewTLSConfig(ClientBundle)
+	if err != nil {
+		T.Fatalf("Failed to create TLS config - %s", err)
+	}
+	URL := startTestServer(T, MismatchBundle)
+	Dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: time.Second}, Config: Config}
+	if Conn, err := Dialer.Dial("tcp", URL[len("https://"):]); err == nil {
+		Conn.Close()
+		T.Fatalf("Expected a server certificate for another address to be rejected")
+	}
+}
+
+func mustKeyPEM(T *testing.T, C *easytls.IssuedCertificate) []byte {
+	Key, err := C.KeyPEM()
+	if err != nil {
+		T.Fatalf("Failed to encode key - %s", err)
+	}
+	return Key
+}
+
+func TestReloadVerifyPeerCertificate(T *testing.T) {
+
+	_, ServerBundle, ClientBundle := newTestAuthority(T)
+	ClientBundle.Reload = easytls.ReloadPolicy{Enabled: true, Logger: testLogger()}
+	Addr := startTestServer(T, ServerBundle)[len("https://"):]
+
+	Config, err := easytls.NewTLSConfig(ClientBundle)
+	if err != nil {
+		T.Fatalf("Failed to create TLS config - %s", err)
+	}
+
+	Calls := 0
+	Config.VerifyPeerCertificate = func(Raw [][]byte, Chains [][]*x509.Certificate) error {
+		Calls++
+		if len(Raw) == 0 || len(Chains) == 0 {
+			T.Errorf("Expected both the raw certificates and the verified chains, got %d and %d", len(Raw), len(Chains))
+		}
+		return nil
+	}
+
+	Dials := map[string]func() (net.Conn, error){
+		// Using the Config directly, to an IP address, must still work.
+		"tls.Dialer": func() (net.Conn, error) {
+			return (&tls.Dialer{NetDialer: &net.Dialer{Timeout: time.Second}, Config: Config}).Dial("tcp", Addr)
+		},
+		"DialTLSContext": func() (net.Conn, error) {
+			return easytls.DialTLSContext(Config)(context.Background(), "tcp", Addr)
+		},
+	}
+
+	for Name, Dial := range Dials {
+		Calls = 0
+		Conn, err := Dial()
+		if err != nil {
+			T.Fatalf("[%s] Failed to connect - %s", Name, err)
+		}
+		Conn.Close()
+
+		if Calls != 1 {
+			T.Fatalf("[%s] Expected VerifyPeerCertificate to be called exactly once, got %d", Name, Calls)
+		}
+	}
+}
+
+func TestReloadServerAuthorities(T *testing.T) {
+
+	CA, _, ClientBundle := newTestAuthority(T)
+	ClientBundle.Reload = easytls.ReloadPolicy{Enabled: true, Interval: time.Nanosecond, Logger: testLogger()}
+
+	Config, err := easytls.NewTLSConfig(ClientBundle)
+	if err != nil {
+		T.Fatalf("Failed to create TLS config - %s", err)
+	}
+
+	// The server is issued by a new authority, which the client only trusts
+	// once its authorities are rotated on disk.
+	NewCA, err := easytls.NewCertificateAuthority("EasyTLS Rotated CA", 0)
+	if err != nil {
+		T.Fatalf("Failed to create CA - %s", err)
+	}
+	ServerCert, err := NewCA.IssueServerCertificate(easytls.CertificateOptions{CommonName: "server", IPAddresses: []net.IP{net.ParseIP("127.0.0.1")}})
+	if err != nil {
+		T.Fatalf("Failed to issue server certificate - %s", err)
+	}
+	ServerBundle := easytls.NewTLSBundleFromPEM(ServerCert.CertificatePEM(), mustKeyPEM(T, ServerCert), CA.CertificatePEM())
+	ServerBundle.Auth = tls.RequireAndVerifyClientCert
+	Addr := startTestServer(T, ServerBundle)[len("https://"):]
+
+	Dial := easytls.DialTLSContext(Config)
+	if Conn, err := Dial(context.Background(), "tcp", Addr); err == nil {
+		Conn.Close()
+		T.Fatalf("Expected a server from an untrusted authority to be rejected")
+	}
+
+	if err := os.WriteFile(ClientBundle.AuthorityCertificates[0], NewCA.CertificatePEM(), 0644); err != nil {
+		T.Fatalf("Failed to rotate authorities - %s", err)
+	}
+
+	Conn, err := Dial(context.Background(), "tcp", Addr)
+	if err != nil {
+		T.Fatalf("Expected the server to be verified against the rotated authorities - %s", err)
+	}
+	Conn.Close()
+}
@@ -0,0 +1,380 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of the circuit breaker of a single host.
+type BreakerState int
+
+// The states of a circuit breaker.
+const (
+	// BreakerClosed allows all requests, while tracking their failure rate.
+	BreakerClosed BreakerState = iota
+
+	// BreakerOpen rejects all requests until the cool-down has elapsed.
+	BreakerOpen
+
+	// BreakerHalfOpen allows a limited number of trial requests, closing
+	// the breaker if they succeed or opening it again if any fail.
+	BreakerHalfOpen
+)
+
+func (S BreakerState) String() string {
+	switch S {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// ErrCircuitOpen is matched, with errors.Is, by the errors returned for
+// requests rejected by an open circuit breaker.
+var ErrCircuitOpen = errors.New("client error: Circuit breaker open")
+
+// CircuitOpenError is returned for requests rejected by an open circuit breaker.
+type CircuitOpenError struct {
+
+	// Host is the host:port the breaker protects.
+	Host string
+
+	// RetryAfter is how long until the breaker next allows a trial request.
+	RetryAfter time.Duration
+}
+
+func (E *CircuitOpenError) Error() string {
+	return ErrCircuitOpen.Error() + " for host [ " + E.Host + " ]"
+}
+
+// Is allows errors.Is(err, ErrCircuitOpen) to match.
+func (E *CircuitOpenError) Is(Target error) bool {
+	return Target == ErrCircuitOpen
+}
+
+// The default values of the fields of BreakerOptions left unset.
+const (
+	DefaultBreakerWindowSize  = 20
+	DefaultBreakerMinRequests = 10
+	DefaultBreakerFailureRate = 0.5
+	DefaultBreakerCoolDown    = 30 * time.Second
+)
+
+// BreakerOptions configures the circuit breakers of a CircuitBreakers.
+type BreakerOptions struct {
+
+	// WindowSize is the number of most recent requests the failure rate is
+	// calculated over. Defaults to DefaultBreakerWindowSize if not set.
+	WindowSize int
+
+	// MinRequests is the number of requests in the window before the breaker
+	// may open. Defaults to DefaultBreakerMinRequests, or WindowSize if that
+	// is smaller, if not set.
+	MinRequests int
+
+	// FailureRate is the fraction of failed requests, between 0 and 1, at
+	// which the breaker opens. Defaults to DefaultBreakerFailureRate if not set.
+	FailureRate float64
+
+	// CoolDown is how long the breaker stays open before allowing trial
+	// requests. Defaults to DefaultBreakerCoolDown if not set.
+	CoolDown time.Duration
+
+	// HalfOpenRequests is the number of trial requests allowed while half-open,
+	// all of which must succeed to close the breaker. Defaults to 1 if not set.
+	HalfOpenRequests int
+
+	// IsFailure decides whether the outcome of a request counts as a failure.
+	// By default, errors and responses with a 5xx status are failures.
+	// Requests cancelled by the caller are never counted, as either a
+	// success or a failure, and are not passed to IsFailure.
+	IsFailure func(resp *http.Response, err error) bool
+
+	// OnStateChange, if set, is called whenever the breaker of a host changes
+	// state. It is called while the breakers are locked, so must not call
+	// back into them.
+	OnStateChange func(Host string, From, To BreakerState)
+}
+
+// BreakerStatus is the current state of the circuit breaker of a single host.
+type BreakerStatus struct {
+	Host      string
+	State     string
+	Requests  int
+	Failures  int
+	OpenUntil *time.Time `json:",omitempty"`
+}
+
+// CircuitBreakers is a set of circuit breakers, one per upstream host:port,
+// created as each host is first requested.
+type CircuitBreakers struct {
+	mu      *sync.Mutex
+	options BreakerOptions
+	hosts   map[string]*breaker
+}
+
+// NewCircuitBreakers will create a new set of circuit breakers, with the
+// given options applied to each.
+func NewCircuitBreakers(Options BreakerOptions) *CircuitBreakers {
+
+	if Options.WindowSize <= 0 {
+		Options.WindowSize = DefaultBreakerWindowSize
+	}
+
+	if Options.MinRequests <= 0 {
+		Options.MinRequests = DefaultBreakerMinRequests
+	}
+
+	if Options.MinRequests > Options.WindowSize {
+		Options.MinRequests = Options.WindowSize
+	}
+
+	if Options.FailureRate <= 0 {
+		Options.FailureRate = DefaultBreakerFailureRate
+	}
+
+	if Options.CoolDown <= 0 {
+		Options.CoolDown = DefaultBreakerCoolDown
+	}
+
+	if Options.HalfOpenRequests <= 0 {
+		Options.HalfOpenRequests = 1
+	}
+
+	if Options.IsFailure == nil {
+		Options.IsFailure = defaultIsFailure
+	}
+
+	return &CircuitBreakers{
+		mu:      &sync.Mutex{},
+		options: Options,
+		hosts:   make(map[string]*breaker),
+	}
+}
+
+func defaultIsFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+// Allow checks whether a request to the given host:port may be made. If it
+// may, the returned function must be called with the outcome of the request.
+// Otherwise, a *CircuitOpenError is returned.
+func (B *CircuitBreakers) Allow(Host string) (func(*http.Response, error), error) {
+
+	B.mu.Lock()
+	defer B.mu.Unlock()
+
+	b, ok := B.hosts[Host]
+	if !ok {
+		b = &breaker{outcomes: make([]bool, B.options.WindowSize)}
+		B.hosts[Host] = b
+	}
+
+	Generation, err := B.allow(Host, b, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return func(resp *http.Response, err error) {
+
+		// A cancelled request says nothing about the health of the host.
+		if errors.Is(err, context.Canceled) {
+			B.mu.Lock()
+			defer B.mu.Unlock()
+			B.release(b, Generation)
+			return
+		}
+
+		Failed := B.options.IsFailure(resp, err)
+		B.mu.Lock()
+		defer B.mu.Unlock()
+		B.record(Host, b, Generation, Failed, time.Now())
+	}, nil
+}
+
+// State returns the state of the breaker of the given host:port.
+func (B *CircuitBreakers) State(Host string) BreakerState {
+	B.mu.Lock()
+	defer B.mu.Unlock()
+	if b, ok := B.hosts[Host]; ok {
+		return b.state
+	}
+	return BreakerClosed
+}
+
+// Reset closes the breaker of the given host:port, forgetting its history.
+func (B *CircuitBreakers) Reset(Host string) {
+	B.mu.Lock()
+	defer B.mu.Unlock()
+	if b, ok := B.hosts[Host]; ok {
+		B.transition(Host, b, BreakerClosed, time.Now())
+	}
+}
+
+// Status returns the state of the breaker of every host requested, ordered by host.
+func (B *CircuitBreakers) Status() []BreakerStatus {
+
+	B.mu.Lock()
+	defer B.mu.Unlock()
+
+	Status := make([]BreakerStatus, 0, len(B.hosts))
+	for Host, b := range B.hosts {
+		S := BreakerStatus{
+			Host:     Host,
+			State:    b.state.String(),
+			Requests: b.count,
+			Failures: b.failures,
+		}
+		if b.state == BreakerOpen {
+			Until := b.openedAt.Add(B.options.CoolDown)
+			S.OpenUntil = &Until
+		}
+		Status = append(Status, S)
+	}
+
+	sort.Slice(Status, func(i, j int) bool { return Status[i].Host < Status[j].Host })
+
+	return Status
+}
+
+// ServeHTTP implements http.Handler, writing the Status of every breaker as JSON.
+func (B *CircuitBreakers) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(B.Status())
+}
+
+// breaker is the state of a single host, protected by the lock of its CircuitBreakers.
+type breaker struct {
+	state BreakerState
+
+	// The outcomes of the most recent requests while closed, true for a failure.
+	outcomes []bool
+	next     int
+	count    int
+	failures int
+
+	openedAt time.Time
+
+	// The trial requests made, and succeeded, while half-open.
+	trials    int
+	successes int
+
+	// Incremented on every transition, so outcomes of requests allowed in an
+	// earlier state are ignored.
+	generation int
+}
+
+func (B *CircuitBreakers) allow(Host string, b *breaker, Now time.Time) (int, error) {
+
+	switch b.state {
+	case BreakerOpen:
+		if Elapsed := Now.Sub(b.openedAt); Elapsed < B.options.CoolDown {
+			return 0, &CircuitOpenError{Host: Host, RetryAfter: B.options.CoolDown - Elapsed}
+		}
+		B.transition(Host, b, BreakerHalfOpen, Now)
+		fallthrough
+
+	case BreakerHalfOpen:
+		if b.trials >= B.options.HalfOpenRequests {
+			return 0, &CircuitOpenError{Host: Host}
+		}
+		b.trials++
+	}
+
+	return b.generation, nil
+}
+
+func (B *CircuitBreakers) record(Host string, b *breaker, Generation int, Failed bool, Now time.Time) {
+
+	if Generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case BreakerHalfOpen:
+		if Failed {
+			B.transition(Host, b, BreakerOpen, Now)
+			return
+		}
+		b.successes++
+		if b.successes >= B.options.HalfOpenRequests {
+			B.transition(Host, b, BreakerClosed, Now)
+		}
+
+	case BreakerClosed:
+		if b.count == len(b.outcomes) {
+			if b.outcomes[b.next] {
+				b.failures--
+			}
+		} else {
+			b.count++
+		}
+
+		b.outcomes[b.next] = Failed
+		b.next = (b.next + 1) % len(b.outcomes)
+		if Failed {
+			b.failures++
+		}
+
+		if b.count >= B.options.MinRequests && float64(b.failures)/float64(b.count) >= B.options.FailureRate {
+			B.transition(Host, b, BreakerOpen, Now)
+		}
+	}
+}
+
+// release frees the trial of a request allowed while half-open, without
+// recording an outcome, so another trial request may be made in its place.
+func (B *CircuitBreakers) release(b *breaker, Generation int) {
+	if Generation == b.generation && b.state == BreakerHalfOpen && b.trials > 0 {
+		b.trials--
+	}
+}
+
+func (B *CircuitBreakers) transition(Host string, b *breaker, To BreakerState, Now time.Time) {
+
+	From := b.state
+
+	b.state = To
+	b.generation++
+	b.trials, b.successes = 0, 0
+
+	switch To {
+	case BreakerOpen:
+		b.openedAt = Now
+	case BreakerClosed:
+		b.outcomes = make([]bool, len(b.outcomes))
+		b.next, b.count, b.failures = 0, 0, 0
+	}
+
+	if From != To && B.options.OnStateChange != nil {
+		B.options.OnStateChange(Host, From, To)
+	}
+}
+
+// breakerHost returns the host:port a request is made to, with the default
+// port of its scheme if none is given.
+func breakerHost(req *http.Request) string {
+
+	if _, _, err := net.SplitHostPort(req.URL.Host); err == nil {
+		return req.URL.Host
+	}
+
+	Port := "80"
+	if req.URL.Scheme == "https" {
+		Port = "443"
+	}
+
+	return net.JoinHostPort(req.URL.Hostname(), Port)
+}
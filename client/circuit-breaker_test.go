@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerStates(T *testing.T) {
+
+	B := NewCircuitBreakers(BreakerOptions{WindowSize: 4, MinRequests: 4, FailureRate: 0.5, CoolDown: time.Hour})
+	Now := time.Now()
+
+	Outcome := func(Failed bool) error {
+		Done, err := B.Allow("upstream:80")
+		if err != nil {
+			return err
+		}
+		if Failed {
+			Done(nil, errors.New("connection refused"))
+		} else {
+			Done(&http.Response{StatusCode: http.StatusOK}, nil)
+		}
+		return nil
+	}
+
+	for _, Failed := range []bool{true, false, false, true} {
+		if err := Outcome(Failed); err != nil {
+			T.Fatalf("Expected requests to be allowed while closed: %v", err)
+		}
+	}
+
+	if B.State("upstream:80") != BreakerOpen {
+		T.Fatalf("Expected the breaker to open at the failure rate, got %s", B.State("upstream:80"))
+	}
+
+	err := Outcome(false)
+	Open := (*CircuitOpenError)(nil)
+	if !errors.Is(err, ErrCircuitOpen) || !errors.As(err, &Open) || Open.RetryAfter <= 0 {
+		T.Fatalf("Expected requests to be rejected while open, got %v", err)
+	}
+
+	// Skip the cool-down, allowing a single trial request.
+	B.hosts["upstream:80"].openedAt = Now.Add(-2 * time.Hour)
+
+	Done, err := B.Allow("upstream:80")
+	if err != nil || B.State("upstream:80") != BreakerHalfOpen {
+		T.Fatalf("Expected a trial request once the cool-down elapsed, got %v", err)
+	}
+
+	if _, err := B.Allow("upstream:80"); !errors.Is(err, ErrCircuitOpen) {
+		T.Fatalf("Expected further requests to be rejected while the trial is in flight")
+	}
+
+	// A cancelled trial frees its place for another.
+	Done(nil, context.Canceled)
+	if B.State("upstream:80") != BreakerHalfOpen {
+		T.Fatalf("Expected a cancelled trial to leave the breaker half-open, got %s", B.State("upstream:80"))
+	}
+	if Done, err = B.Allow("upstream:80"); err != nil {
+		T.Fatalf("Expected another trial request after a cancelled one, got %v", err)
+	}
+
+	Done(&http.Response{StatusCode: http.StatusOK}, nil)
+	if B.State("upstream:80") != BreakerClosed {
+		T.Fatalf("Expected a successful trial to close the breaker, got %s", B.State("upstream:80"))
+	}
+}
+
+func TestCircuitBreakerIgnoresCancelled(T *testing.T) {
+
+	B := NewCircuitBreakers(BreakerOptions{WindowSize: 2, MinRequests: 2})
+
+	for i := 0; i < 4; i++ {
+		Done, err := B.Allow("upstream:80")
+		if err != nil {
+			T.Fatalf("Expected requests to be allowed while closed: %v", err)
+		}
+		Done(nil, fmt.Errorf("request cancelled: %w", context.Canceled))
+	}
+
+	if Status := B.Status(); len(Status) != 1 || Status[0].Requests != 0 || Status[0].Failures != 0 {
+		T.Fatalf("Expected cancelled requests to be counted as neither success nor failure, got %+v", Status)
+	}
+}
+
+func TestClientCircuitBreaker(T *testing.T) {
+
+	var Attempts int32
+	S := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&Attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer S.Close()
+
+	C := newTestClient()
+
+	var Changes int32
+	C.EnableCircuitBreakers(BreakerOptions{WindowSize: 2, MinRequests: 2, OnStateChange: func(Host string, From, To BreakerState) {
+		atomic.AddInt32(&Changes, 1)
+	}})
+
+	for i := 0; i < 2; i++ {
+		resp, err := C.Get(S.URL, nil)
+		if err != nil {
+			T.Fatalf("Expected the first requests to reach the server: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	if _, err := C.Get(S.URL, nil); !errors.Is(err, ErrCircuitOpen) {
+		T.Fatalf("Expected the open breaker to reject the request, got %v", err)
+	}
+
+	if atomic.LoadInt32(&Attempts) != 2 || atomic.LoadInt32(&Changes) != 1 {
+		T.Fatalf("Expected 2 requests and 1 state change, got %d and %d", Attempts, Changes)
+	}
+
+	Status := C.CircuitBreakers().Status()
+	if len(Status) != 1 || Status[0].State != "open" || Status[0].OpenUntil == nil {
+		T.Fatalf("Unexpected breaker status: %+v", Status)
+	}
+}
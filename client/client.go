@@ -13,6 +13,8 @@ import (
 
 	easytls "github.com/Bearnie-H/easy-tls"
 	"github.com/Bearnie-H/easy-tls/header"
+	"github.com/Bearnie-H/easy-tls/metrics"
+	"github.com/Bearnie-H/easy-tls/tracing"
 )
 
 // SimpleClient is the primary object of this library. This is the
@@ -21,17 +23,34 @@ import (
 // running in HTTP or HTTPS mode, with a basic utility function to check.
 type SimpleClient struct {
 	*http.Client
-	logger *log.Logger
+	logger easytls.Logger
 
 	tls    bool
 	bundle easytls.TLSBundle
+
+	// The (optional) monitor tracking the expiry of our own certificates, and
+	// those presented by servers.
+	expiry *easytls.ExpiryMonitor
+
+	// The (optional) Registry to record request metrics in.
+	metrics *metrics.Registry
+
+	// The (optional) Tracer to start request spans with, instead of tracing.Default.
+	tracer tracing.Tracer
+
+	// The policy failed requests are retried with.
+	retry RetryPolicy
+
+	// The (optional) circuit breakers of the upstream hosts.
+	breakers *CircuitBreakers
 }
 
 // NewClient will wrap an existing http.Client as a SimpleClient.
 func NewClient(C *http.Client) *SimpleClient {
+
 	return &SimpleClient{
 		Client: C,
-		logger: easytls.NewDefaultLogger(),
+		logger: easytls.NewDefaultStructuredLogger(),
 		tls:    false,
 		bundle: easytls.TLSBundle{},
 	}
@@ -45,6 +64,15 @@ func NewClientHTTP() *SimpleClient {
 	return C
 }
 
+// newTLSTransport creates the Transport of a client using the given TLS settings.
+func newTLSTransport(Config *tls.Config) *http.Transport {
+	return &http.Transport{
+		TLSClientConfig:   Config,
+		DialTLSContext:    easytls.DialTLSContext(Config),
+		ForceAttemptHTTP2: true,
+	}
+}
+
 // NewClientHTTPS will fully initialize a SimpleClient with TLS settings turned
 // on. These settings CAN be turned on and off as required.
 func NewClientHTTPS(TLS *easytls.TLSBundle) (*SimpleClient, error) {
@@ -54,7 +82,7 @@ func NewClientHTTPS(TLS *easytls.TLSBundle) (*SimpleClient, error) {
 		return nil, err
 	}
 
-	Logger := easytls.NewDefaultLogger()
+	Logger := easytls.NewDefaultStructuredLogger()
 
 	var saveBundle easytls.TLSBundle
 	if TLS != nil {
@@ -63,11 +91,9 @@ func NewClientHTTPS(TLS *easytls.TLSBundle) (*SimpleClient, error) {
 
 	s := &SimpleClient{
 		Client: &http.Client{
-			Timeout: time.Hour,
-			Transport: &http.Transport{
-				TLSClientConfig:   tls,
-				ForceAttemptHTTP2: true,
-			}},
+			Timeout:   time.Hour,
+			Transport: newTLSTransport(tls),
+		},
 		tls:    !(tls == nil),
 		logger: Logger,
 		bundle: saveBundle,
@@ -77,16 +103,119 @@ func NewClientHTTPS(TLS *easytls.TLSBundle) (*SimpleClient, error) {
 }
 
 // SetLogger will update the logger used by the client from the default to the
-// given output.
+// given output. Messages are written to it as by easytls.NewStdLogger.
 func (C *SimpleClient) SetLogger(logger *log.Logger) {
-	C.logger = logger
+	C.SetStructuredLogger(easytls.AsLogger(logger))
 }
 
-// Logger will return the internal logger used by the client.
+// Logger will return the internal logger used by the client, as a standard
+// *log.Logger.
 func (C *SimpleClient) Logger() *log.Logger {
+	return easytls.AsLogLogger(C.logger)
+}
+
+// SetStructuredLogger will update the leveled, structured logger used by the
+// client from the default to the given Logger.
+func (C *SimpleClient) SetStructuredLogger(logger easytls.Logger) {
+	if logger == nil {
+		logger = easytls.NewDefaultStructuredLogger()
+	}
+	C.logger = logger
+	if C.expiry != nil {
+		C.expiry.SetLogger(logger)
+	}
+}
+
+// StructuredLogger will return the internal leveled, structured logger used
+// by the client.
+func (C *SimpleClient) StructuredLogger() easytls.Logger {
 	return C.logger
 }
 
+// EnableMetrics will record the latency of every request performed by the
+// client in the given Registry, by method, host and status code. The
+// metrics.Default Registry is used if none is given.
+func (C *SimpleClient) EnableMetrics(Registry *metrics.Registry) {
+	if Registry == nil {
+		Registry = metrics.Default
+	}
+	C.metrics = Registry
+}
+
+// Metrics will return the Registry the client records metrics in, or nil if
+// metrics are not enabled.
+func (C *SimpleClient) Metrics() *metrics.Registry {
+	return C.metrics
+}
+
+// SetTracer will start the spans of the requests performed by the client
+// with the given Tracer, rather than the tracing.Default Tracer.
+func (C *SimpleClient) SetTracer(Tracer tracing.Tracer) {
+	C.tracer = Tracer
+}
+
+// Tracer will return the Tracer the client starts request spans with.
+func (C *SimpleClient) Tracer() tracing.Tracer {
+	if C.tracer == nil {
+		return tracing.Default()
+	}
+	return C.tracer
+}
+
+// EnableCircuitBreakers will protect each upstream host:port requested by the
+// client with a circuit breaker, created with the given options. Requests to
+// a host whose breaker is open fail immediately with a *CircuitOpenError.
+// Changes of state are logged, in addition to calling any OnStateChange.
+func (C *SimpleClient) EnableCircuitBreakers(Options BreakerOptions) *CircuitBreakers {
+
+	OnStateChange := Options.OnStateChange
+	Options.OnStateChange = func(Host string, From, To BreakerState) {
+		C.StructuredLogger().Warn("Circuit breaker changed state", "host", Host, "from", From.String(), "to", To.String())
+		if OnStateChange != nil {
+			OnStateChange(Host, From, To)
+		}
+	}
+
+	C.breakers = NewCircuitBreakers(Options)
+	return C.breakers
+}
+
+// SetCircuitBreakers will protect the upstream hosts requested by the client
+// with the given breakers, which may be shared with other clients. A nil
+// value disables circuit breaking.
+func (C *SimpleClient) SetCircuitBreakers(Breakers *CircuitBreakers) {
+	C.breakers = Breakers
+}
+
+// CircuitBreakers will return the circuit breakers of the client, or nil if
+// circuit breaking is not enabled.
+func (C *SimpleClient) CircuitBreakers() *CircuitBreakers {
+	return C.breakers
+}
+
+// EnableExpiryMonitoring will start tracking the expiry of the certificates
+// used by the client, and those presented by the servers it connects to,
+// logging a warning to the logger of the client as each nears expiry. The
+// monitor is returned, and is created only once.
+func (C *SimpleClient) EnableExpiryMonitoring() *easytls.ExpiryMonitor {
+
+	if C.expiry == nil {
+		C.expiry = easytls.NewExpiryMonitor(C.logger)
+		if C.tls {
+			C.expiry.AddBundle(&C.bundle)
+		}
+	}
+
+	return C.expiry
+}
+
+// ExpiryMonitor will return the monitor tracking the expiry of the
+// certificates used by the client, and those presented by the servers it has
+// connected to, or nil if expiry monitoring is not enabled.
+func (C *SimpleClient) ExpiryMonitor() *easytls.ExpiryMonitor {
+	return C.expiry
+}
+
 // CloneTLSConfig will form a proper clone of the underlying tls.Config.
 func (C *SimpleClient) CloneTLSConfig() (*tls.Config, error) {
 	return easytls.NewTLSConfig(&C.bundle)
@@ -119,7 +248,8 @@ func NewRequest(Method string, URL string, Headers http.Header, Contents io.Read
 }
 
 // NewRequestWithContext will create a new HTTP Request, ready to be used by any
-// implementation of an http.Client.
+// implementation of an http.Client. The body of the request can be sent again
+// when retried if Contents is held in memory, or is an io.ReadSeeker.
 func NewRequestWithContext(ctx context.Context, Method string, URL string, Headers http.Header, Contents io.Reader) (*http.Request, error) {
 
 	req, err := http.NewRequestWithContext(ctx, Method, URL, Contents)
@@ -127,6 +257,21 @@ func NewRequestWithContext(ctx context.Context, Method string, URL string, Heade
 		return nil, err
 	}
 
+	// Allow bodies which can be rewound to be sent again when retried, as
+	// is already done for in-memory bodies. Others are buffered if needed.
+	if Seeker, ok := Contents.(io.ReadSeeker); ok && req.GetBody == nil {
+		if _, Closer := Contents.(io.Closer); !Closer {
+			if Offset, err := Seeker.Seek(0, io.SeekCurrent); err == nil {
+				req.GetBody = func() (io.ReadCloser, error) {
+					if _, err := Seeker.Seek(Offset, io.SeekStart); err != nil {
+						return nil, err
+					}
+					return io.NopCloser(Seeker), nil
+				}
+			}
+		}
+	}
+
 	header.Merge(&(req.Header), &Headers)
 
 	return req, nil
@@ -139,16 +284,21 @@ func NewRequestWithContext(ctx context.Context, Method string, URL string, Heade
 // fail, as there are no TLS resources to work with.
 func (C *SimpleClient) EnableTLS(TLS ...*easytls.TLSBundle) (err error) {
 
+	// Use the first of the provided TLSBundles which works, saving it to fall back to later.
 	var tlsConf *tls.Config
-	for _, tls := range TLS {
-		tlsConf, err = easytls.NewTLSConfig(tls)
-		if err != nil {
-			continue
+	for _, Bundle := range TLS {
+		tlsConf, err = easytls.NewTLSConfig(Bundle)
+		if err == nil && tlsConf != nil {
+			C.bundle = *Bundle
+			if C.expiry != nil {
+				C.expiry.AddBundle(Bundle)
+			}
+			break
 		}
 	}
 
 	// If all of the provided TLSBundles failed, check if the Client has a saved one and use it.
-	if err != nil {
+	if tlsConf == nil {
 		tlsConf, err = easytls.NewTLSConfig(&C.bundle)
 		if err != nil {
 			return err
@@ -156,11 +306,8 @@ func (C *SimpleClient) EnableTLS(TLS ...*easytls.TLSBundle) (err error) {
 	}
 
 	C.Client = &http.Client{
-		Timeout: time.Hour,
-		Transport: &http.Transport{
-			TLSClientConfig:   tlsConf,
-			ForceAttemptHTTP2: true,
-		},
+		Timeout:   time.Hour,
+		Transport: newTLSTransport(tlsConf),
 	}
 	C.tls = true
 
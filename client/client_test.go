@@ -0,0 +1,12 @@
+package client
+
+import (
+	easytls "github.com/Bearnie-H/easy-tls"
+)
+
+// newTestClient returns a plain HTTP client which discards its log output.
+func newTestClient() *SimpleClient {
+	C := NewClientHTTP()
+	C.SetStructuredLogger(easytls.NewDiscardLogger())
+	return C
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"strings"
+	"sync"
+)
+
+// Codec encodes the bodies of requests, and decodes the bodies of responses,
+// for a single media type. Additional formats can be supported by
+// implementing this interface and registering it with RegisterCodec.
+type Codec interface {
+
+	// ContentType returns the media type of the encoded bodies, such as "application/json".
+	ContentType() string
+
+	// Encode writes the encoding of v to w.
+	Encode(w io.Writer, v interface{}) error
+
+	// Decode reads an encoded value from r into v, which is a pointer.
+	Decode(r io.Reader, v interface{}) error
+}
+
+// The Codecs provided by this package.
+var (
+	JSON Codec = jsonCodec{}
+	XML  Codec = xmlCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string                     { return "application/json" }
+func (jsonCodec) Encode(w io.Writer, v interface{}) error { return json.NewEncoder(w).Encode(v) }
+func (jsonCodec) Decode(r io.Reader, v interface{}) error { return json.NewDecoder(r).Decode(v) }
+
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string                     { return "application/xml" }
+func (xmlCodec) Encode(w io.Writer, v interface{}) error { return xml.NewEncoder(w).Encode(v) }
+func (xmlCodec) Decode(r io.Reader, v interface{}) error { return xml.NewDecoder(r).Decode(v) }
+
+var (
+	codecsLock = &sync.RWMutex{}
+	codecs     = map[string]Codec{
+		"application/json": JSON,
+		"application/xml":  XML,
+		"text/xml":         XML,
+	}
+)
+
+// RegisterCodec will register the Codec for its media type, and any others
+// given, so responses of that type are decoded with it by the typed request
+// functions, such as GetJSON and RequestAs.
+func RegisterCodec(C Codec, MediaTypes ...string) {
+
+	codecsLock.Lock()
+	defer codecsLock.Unlock()
+
+	for _, MediaType := range append([]string{C.ContentType()}, MediaTypes...) {
+		codecs[strings.ToLower(MediaType)] = C
+	}
+}
+
+// codecFor returns the Codec registered for the Content-Type of a response,
+// or the Fallback if there is none. Structured syntax suffixes, such as
+// "application/problem+json", are decoded with the Codec of their suffix.
+func codecFor(ContentType string, Fallback Codec) Codec {
+
+	MediaType, _, err := mime.ParseMediaType(ContentType)
+	if err != nil {
+		return Fallback
+	}
+
+	codecsLock.RLock()
+	defer codecsLock.RUnlock()
+
+	if C, ok := codecs[MediaType]; ok {
+		return C
+	}
+
+	if i := strings.LastIndex(MediaType, "+"); i >= 0 {
+		if C, ok := codecs["application/"+MediaType[i+1:]]; ok {
+			return C
+		}
+	}
+
+	return Fallback
+}
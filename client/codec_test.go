@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type codecTestItem struct {
+	XMLName xml.Name `json:"-" xml:"item"`
+	Name    string   `json:"name" xml:"name"`
+	Count   int      `json:"count" xml:"count"`
+}
+
+func TestPostJSON(T *testing.T) {
+
+	S := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Accept") != "application/json" {
+			T.Errorf("Expected JSON Content-Type and Accept headers, got %v", r.Header)
+		}
+		var Item codecTestItem
+		if err := json.NewDecoder(r.Body).Decode(&Item); err != nil {
+			T.Errorf("Failed to decode request body: %v", err)
+		}
+		Item.Count++
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(Item)
+	}))
+	defer S.Close()
+
+	Item, err := PostJSON[codecTestItem](context.Background(), newTestClient(), S.URL, codecTestItem{Name: "widget", Count: 1}, nil)
+	if err != nil {
+		T.Fatalf("Failed to perform request: %v", err)
+	}
+	if Item.Name != "widget" || Item.Count != 2 {
+		T.Fatalf("Expected the decoded response, got %+v", Item)
+	}
+}
+
+func TestGetXML(T *testing.T) {
+
+	S := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write([]byte(`<item><name>widget</name><count>3</count></item>`))
+	}))
+	defer S.Close()
+
+	Item, err := GetXML[codecTestItem](context.Background(), newTestClient(), S.URL, nil)
+	if err != nil {
+		T.Fatalf("Failed to perform request: %v", err)
+	}
+	if Item.Name != "widget" || Item.Count != 3 {
+		T.Fatalf("Expected the decoded response, got %+v", Item)
+	}
+}
+
+func TestTypedNoContent(T *testing.T) {
+
+	S := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer S.Close()
+
+	Item, err := DeleteJSON[*codecTestItem](context.Background(), newTestClient(), S.URL, nil)
+	if err != nil || Item != nil {
+		T.Fatalf("Expected a nil result and no error, got %v, %v", Item, err)
+	}
+}
+
+func TestTypedStatusError(T *testing.T) {
+
+	S := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Reason", "duplicate")
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(strings.Repeat("x", MaxErrorBodySize*2)))
+	}))
+	defer S.Close()
+
+	_, err := GetJSON[codecTestItem](context.Background(), newTestClient(), S.URL, nil)
+
+	var StatusErr *StatusError
+	if !errors.As(err, &StatusErr) {
+		T.Fatalf("Expected a *StatusError, got %v", err)
+	}
+	if StatusErr.StatusCode != http.StatusConflict || StatusErr.Method != http.MethodGet || StatusErr.Header.Get("X-Reason") != "duplicate" {
+		T.Fatalf("Unexpected status error: %+v", StatusErr)
+	}
+	if len(StatusErr.Body) != MaxErrorBodySize {
+		T.Fatalf("Expected the body to be capped at %d bytes, got %d", MaxErrorBodySize, len(StatusErr.Body))
+	}
+}
+
+func TestCodecFor(T *testing.T) {
+
+	Cases := []struct {
+		ContentType string
+		Expected    Codec
+	}{
+		{"application/json; charset=utf-8", JSON},
+		{"application/problem+json", JSON},
+		{"application/atom+xml", XML},
+		{"text/plain", XML},
+		{"", XML},
+	}
+
+	for _, C := range Cases {
+		if Got := codecFor(C.ContentType, XML); Got != C.Expected {
+			T.Fatalf("Expected %s to be decoded as %s, got %s", C.ContentType, C.Expected.ContentType(), Got.ContentType())
+		}
+	}
+}
@@ -10,9 +10,9 @@ import (
 
 // GetContext is the wrapper function for an HTTP "GET" request. This will create a
 // GET request with an empty body and the specified headers. The header map can
-// be set to nil if no additional headers are required. This function returns
-// an error and nil response on an HTTP StatusCode which is outside the 200
-// block.
+// be set to nil if no additional headers are required. The response
+// is returned whatever its status code; use CheckStatus to treat a status
+// outside the 200 block as an error.
 func (C *SimpleClient) GetContext(ctx context.Context, URL string, Headers map[string][]string) (*http.Response, error) {
 
 	// Create the request
@@ -29,9 +29,9 @@ func (C *SimpleClient) GetContext(ctx context.Context, URL string, Headers map[s
 // new HEAD request with an empty body and the specified headers. The header
 // map can be set to nil if no additional headers are required. This will ONLY
 // return the HTTP Response Header map from the server. The overall Response
-// Body (if it exists) will be closed by this function. This function returns
-// an error and nil Header on an HTTP StatusCode which is outside the 200
-// block.
+// Body (if it exists) will be closed by this function. The response
+// is returned whatever its status code; use CheckStatus to treat a status
+// outside the 200 block as an error.
 func (C *SimpleClient) HeadContext(ctx context.Context, URL string, Headers map[string][]string) (*http.Response, error) {
 
 	// Create the request
@@ -49,8 +49,9 @@ func (C *SimpleClient) HeadContext(ctx context.Context, URL string, Headers map[
 // passed in, and the specified headers. The header map can be set to nil if no
 // additional headers are required. If a nil ReadCloser is passed in, this will
 // create an empty Post body which is allowed. This will return the full HTTP
-// Response from the server, unaltered. This function returns an error and nil
-// response on an HTTP StatusCode which is outside the 200 block.
+// Response from the server, unaltered. The response is returned whatever its
+// status code; use CheckStatus to treat a status outside the 200 block as an
+// error.
 //
 // NOTE: This function "may" support MultiPart POST requests, by way of
 // io.Pipes and multipart.Writers, but this has not been tested, and multipart
@@ -73,8 +74,8 @@ func (C *SimpleClient) PostContext(ctx context.Context, URL string, Contents io.
 // The header map can be set to nil if no additional headers are required. If
 // a nil multipart.Reader is passed in, this will create an empty Post body
 // which is allowed. This will return the full HTTP Response from the server
-// unaltered. This function returns an error and nil response on an HTTP
-// StatusCode which is outside the 200 block.
+// unaltered. The response is returned whatever its status code; use CheckStatus
+// to treat a status outside the 200 block as an error.
 //
 // NOTE: This has not yet been implemented.
 func (C *SimpleClient) PostMultipartContext(ctx context.Context, URL string, Contents multipart.Reader, Headers map[string][]string) (*http.Response, error) {
@@ -86,8 +87,9 @@ func (C *SimpleClient) PostMultipartContext(ctx context.Context, URL string, Con
 // passed in, and the specified headers. The header map can be set to nil if no
 // additional headers are required. If a nil ReadCloser is passed in, this will
 // create an empty Put body which is allowed. This will return the full HTTP
-// Response from the server, unaltered. This function returns an error and nil
-// response on an HTTP StatusCode which is outside the 200 block.
+// Response from the server, unaltered. The response is returned whatever its
+// status code; use CheckStatus to treat a status outside the 200 block as an
+// error.
 func (C *SimpleClient) PutContext(ctx context.Context, URL string, Contents io.Reader, Headers map[string][]string) (*http.Response, error) {
 
 	// Create the request
@@ -122,8 +124,9 @@ func (C *SimpleClient) DeleteContext(ctx context.Context, URL string, Headers ma
 // passed in, and the specified headers. The header map can be set to nil if no
 // additional headers are required. If a nil ReadCloser is passed in, this will
 // create an empty Patch body which is allowed. This will return the full HTTP
-// Response from the server, unaltered. This function returns an error and nil
-// response on an HTTP StatusCode which is outside the 200 block.
+// Response from the server, unaltered. The response is returned whatever its
+// status code; use CheckStatus to treat a status outside the 200 block as an
+// error.
 func (C *SimpleClient) PatchContext(ctx context.Context, URL string, Contents io.Reader, Headers map[string][]string) (*http.Response, error) {
 
 	// Create the request
@@ -140,8 +143,8 @@ func (C *SimpleClient) PatchContext(ctx context.Context, URL string, Contents io
 // create a new OPTIONS request with an empty body, and the specified headers.
 // The header map can be set to nil if no additional headers are required.
 // This will return the full HTTP Response from the server, unaltered.
-// This function returns an error and nil response on an HTTP StatusCode
-//  which is outside the 200 block.
+// The response is returned whatever its status code; use CheckStatus to treat a
+// status outside the 200 block as an error.
 func (C *SimpleClient) OptionsContext(ctx context.Context, URL string, Headers map[string][]string) (*http.Response, error) {
 
 	// Create the request
@@ -158,8 +161,8 @@ func (C *SimpleClient) OptionsContext(ctx context.Context, URL string, Headers m
 // a new TRACE request with an empty body, and the specified headers.
 // The header map can be set to nil if no additional headers are required.
 // This will return the full HTTP Response from the server, unaltered.
-// This function returns an error and nil response on an HTTP StatusCode
-// which is outside the 200 block.
+// The response is returned whatever its status code; use CheckStatus to treat a
+// status outside the 200 block as an error.
 func (C *SimpleClient) TraceContext(ctx context.Context, URL string, Headers map[string][]string) (*http.Response, error) {
 
 	// Create the request
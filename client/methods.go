@@ -2,10 +2,14 @@ package client
 
 import (
 	"context"
+	"errors"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/url"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+	"github.com/Bearnie-H/easy-tls/tracing"
 )
 
 // Set the URL Scheme based on the TLS settings of the Client.
@@ -19,9 +23,9 @@ func (C *SimpleClient) setScheme(URL *url.URL) {
 
 // Get is the wrapper function for an HTTP "GET" request. This will create a
 // GET request with an empty body and the specified headers. The header map can
-// be set to nil if no additional headers are required. This function returns
-// an error and nil response on an HTTP StatusCode which is outside the 200
-// block.
+// be set to nil if no additional headers are required. The response
+// is returned whatever its status code; use CheckStatus to treat a status
+// outside the 200 block as an error.
 func (C *SimpleClient) Get(URL string, Headers map[string][]string) (*http.Response, error) {
 	return C.GetContext(context.Background(), URL, Headers)
 }
@@ -30,9 +34,9 @@ func (C *SimpleClient) Get(URL string, Headers map[string][]string) (*http.Respo
 // new HEAD request with an empty body and the specified headers. The header
 // map can be set to nil if no additional headers are required. This will ONLY
 // return the HTTP Response Header map from the server. The overall Response
-// Body (if it exists) will be closed by this function. This function returns
-// an error and nil Header on an HTTP StatusCode which is outside the 200
-// block.
+// Body (if it exists) will be closed by this function. The response
+// is returned whatever its status code; use CheckStatus to treat a status
+// outside the 200 block as an error.
 func (C *SimpleClient) Head(URL string, Headers map[string][]string) (*http.Response, error) {
 	return C.HeadContext(context.Background(), URL, Headers)
 }
@@ -42,8 +46,9 @@ func (C *SimpleClient) Head(URL string, Headers map[string][]string) (*http.Resp
 // passed in, and the specified headers. The header map can be set to nil if no
 // additional headers are required. If a nil ReadCloser is passed in, this will
 // create an empty Post body which is allowed. This will return the full HTTP
-// Response from the server, unaltered. This function returns an error and nil
-// response on an HTTP StatusCode which is outside the 200 block.
+// Response from the server, unaltered. The response is returned whatever its
+// status code; use CheckStatus to treat a status outside the 200 block as an
+// error.
 //
 // NOTE: This function "may" support MultiPart POST requests, by way of
 // io.Pipes and multipart.Writers, but this has not been tested, and multipart
@@ -58,8 +63,8 @@ func (C *SimpleClient) Post(URL string, Contents io.Reader, Headers map[string][
 // The header map can be set to nil if no additional headers are required. If
 // a nil multipart.Reader is passed in, this will create an empty Post body
 // which is allowed. This will return the full HTTP Response from the server
-// unaltered. This function returns an error and nil response on an HTTP
-// StatusCode which is outside the 200 block.
+// unaltered. The response is returned whatever its status code; use CheckStatus
+// to treat a status outside the 200 block as an error.
 //
 // NOTE: This has not yet been implemented.
 func (C *SimpleClient) PostMultipart(URL string, Contents multipart.Reader, Headers map[string][]string) (*http.Response, error) {
@@ -71,8 +76,9 @@ func (C *SimpleClient) PostMultipart(URL string, Contents multipart.Reader, Head
 // passed in, and the specified headers. The header map can be set to nil if no
 // additional headers are required. If a nil ReadCloser is passed in, this will
 // create an empty Put body which is allowed. This will return the full HTTP
-// Response from the server, unaltered. This function returns an error and nil
-// response on an HTTP StatusCode which is outside the 200 block.
+// Response from the server, unaltered. The response is returned whatever its
+// status code; use CheckStatus to treat a status outside the 200 block as an
+// error.
 func (C *SimpleClient) Put(URL string, Contents io.Reader, Headers map[string][]string) (*http.Response, error) {
 	return C.PutContext(context.Background(), URL, Contents, Headers)
 }
@@ -91,8 +97,9 @@ func (C *SimpleClient) Delete(URL string, Headers map[string][]string) (*http.Re
 // passed in, and the specified headers. The header map can be set to nil if no
 // additional headers are required. If a nil ReadCloser is passed in, this will
 // create an empty Patch body which is allowed. This will return the full HTTP
-// Response from the server, unaltered. This function returns an error and nil
-// response on an HTTP StatusCode which is outside the 200 block.
+// Response from the server, unaltered. The response is returned whatever its
+// status code; use CheckStatus to treat a status outside the 200 block as an
+// error.
 func (C *SimpleClient) Patch(URL string, Contents io.Reader, Headers map[string][]string) (*http.Response, error) {
 	return C.PatchContext(context.Background(), URL, Contents, Headers)
 }
@@ -101,8 +108,8 @@ func (C *SimpleClient) Patch(URL string, Contents io.Reader, Headers map[string]
 // create a new OPTIONS request with an empty body, and the specified headers.
 // The header map can be set to nil if no additional headers are required.
 // This will return the full HTTP Response from the server, unaltered.
-// This function returns an error and nil response on an HTTP StatusCode
-//  which is outside the 200 block.
+// The response is returned whatever its status code; use CheckStatus to treat a
+// status outside the 200 block as an error.
 func (C *SimpleClient) Options(URL string, Headers map[string][]string) (*http.Response, error) {
 	return C.OptionsContext(context.Background(), URL, Headers)
 }
@@ -111,8 +118,8 @@ func (C *SimpleClient) Options(URL string, Headers map[string][]string) (*http.R
 // a new TRACE request with an empty body, and the specified headers.
 // The header map can be set to nil if no additional headers are required.
 // This will return the full HTTP Response from the server, unaltered.
-// This function returns an error and nil response on an HTTP StatusCode
-// which is outside the 200 block.
+// The response is returned whatever its status code; use CheckStatus to treat a
+// status outside the 200 block as an error.
 func (C *SimpleClient) Trace(URL string, Headers map[string][]string) (*http.Response, error) {
 	return C.TraceContext(context.Background(), URL, Headers)
 }
@@ -120,7 +127,44 @@ func (C *SimpleClient) Trace(URL string, Headers map[string][]string) (*http.Res
 // Do is the wrapper function for a generic pre-generated HTTP request.
 //
 // This is the generic underlying call used by the rest of this library.
+// If the context of the request carries a request ID, such as one assigned
+// by the server handling an incoming request, it is forwarded in the
+// easytls.RequestIDHeader unless the request already sets one. Each request
+// is performed within a client span of the Tracer of the client, whose
+// context is sent in the W3C traceparent header. Failed requests are retried
+// according to the RetryPolicy of the client, and requests to a host whose
+// circuit breaker is open fail immediately with a *CircuitOpenError.
 func (C *SimpleClient) Do(req *http.Request) (*http.Response, error) {
 	C.setScheme(req.URL)
-	return C.Client.Do(req)
+
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+
+	if ID, ok := easytls.RequestIDFromContext(req.Context()); ok && req.Header.Get(easytls.RequestIDHeader) == "" {
+		req.Header.Set(easytls.RequestIDHeader, ID)
+	}
+
+	ctx, Span := C.Tracer().Start(req.Context(), req.Method, tracing.SpanKindClient)
+	defer Span.End()
+	Span.SetAttributes(tracing.AttrHTTPMethod, req.Method, tracing.AttrURLFull, req.URL.Redacted(), tracing.AttrServerAddress, req.URL.Host)
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := C.send(req, Span)
+	if err != nil {
+		Span.RecordError(err)
+		return resp, err
+	}
+
+	Span.SetAttributes(tracing.AttrHTTPStatusCode, resp.StatusCode)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		Span.RecordError(errors.New(resp.Status))
+	}
+
+	// Keep track of when the certificates of the servers we talk to expire.
+	if resp.TLS != nil && C.expiry != nil {
+		C.expiry.Observe(req.URL.Host, resp.TLS.PeerCertificates...)
+	}
+
+	return resp, nil
 }
@@ -0,0 +1,28 @@
+package client
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MetricRequestDuration is the name of the metric recording the latency of
+// the requests performed by a SimpleClient.
+const MetricRequestDuration = "easytls_client_request_duration_seconds"
+
+// observe records the latency of a single request, if metrics are enabled.
+// Requests which failed without a response are recorded with a status of "error".
+func (C *SimpleClient) observe(req *http.Request, resp *http.Response, Duration time.Duration) {
+
+	if C.metrics == nil {
+		return
+	}
+
+	Status := "error"
+	if resp != nil {
+		Status = strconv.Itoa(resp.StatusCode)
+	}
+
+	C.metrics.Histogram(MetricRequestDuration, "Latency of the requests performed, by method, host and status code.", nil, "method", "host", "status").
+		Observe(Duration.Seconds(), req.Method, req.URL.Host, Status)
+}
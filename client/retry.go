@@ -0,0 +1,291 @@
+package client
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+	"github.com/Bearnie-H/easy-tls/tracing"
+)
+
+// DefaultRetryStatusCodes are the response status codes retried, if no others are given.
+var DefaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// The default values of the fields of a RetryPolicy left unset.
+const (
+	DefaultRetryInitialBackoff = 100 * time.Millisecond
+	DefaultRetryMaxBackoff     = 10 * time.Second
+	DefaultRetryMaxRetryAfter  = time.Minute
+	DefaultRetryBufferSize     = 1 << 20
+)
+
+// RetryPolicy defines when and how often a SimpleClient retries a request.
+//
+// Requests are retried after connection errors, and on responses with one of
+// the RetryStatusCodes, waiting with exponential backoff between attempts, or
+// for as long as the Retry-After header of the response asks if that is longer.
+// Requests are never retried once their context is done, or if the
+// certificate of the server failed verification.
+//
+// Only idempotent methods are retried, unless RetryNonIdempotent is set, or
+// the request carries an Idempotency-Key header.
+type RetryPolicy struct {
+
+	// MaxAttempts is the total number of attempts made, including the first.
+	// Requests are not retried if this is less than 2.
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry, doubling with each
+	// subsequent retry up to MaxBackoff. These default to
+	// DefaultRetryInitialBackoff and DefaultRetryMaxBackoff if not set.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Jitter is the fraction, between 0 and 1, of each backoff which is
+	// randomized, so clients retrying together spread out. Defaults to 0.5
+	// if 0, while a negative value disables jitter.
+	Jitter float64
+
+	// RetryStatusCodes are the response status codes which are retried.
+	// Defaults to DefaultRetryStatusCodes if not set.
+	RetryStatusCodes []int
+
+	// RetryNonIdempotent allows requests with methods such as POST and PATCH
+	// to be retried, which may perform their action more than once.
+	RetryNonIdempotent bool
+
+	// MaxRetryAfter is the longest Retry-After honoured. Responses asking to
+	// wait longer are returned rather than retried. Defaults to
+	// DefaultRetryMaxRetryAfter if not set.
+	MaxRetryAfter time.Duration
+
+	// MaxBufferedBody is the largest request body buffered in memory so it
+	// can be sent again, if it cannot otherwise be rewound. Requests with
+	// larger bodies are sent only once. Defaults to DefaultRetryBufferSize if
+	// not set.
+	MaxBufferedBody int64
+}
+
+// DefaultRetryPolicy returns a RetryPolicy making up to 3 attempts, with the
+// default backoff and status codes.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3}
+}
+
+// SetRetryPolicy will set the policy the client retries failed requests with.
+// By default, each request is attempted only once.
+func (C *SimpleClient) SetRetryPolicy(Policy RetryPolicy) {
+
+	if Policy.InitialBackoff <= 0 {
+		Policy.InitialBackoff = DefaultRetryInitialBackoff
+	}
+
+	if Policy.MaxBackoff <= 0 {
+		Policy.MaxBackoff = DefaultRetryMaxBackoff
+	}
+
+	if Policy.Jitter == 0 {
+		Policy.Jitter = 0.5
+	}
+
+	if Policy.RetryStatusCodes == nil {
+		Policy.RetryStatusCodes = DefaultRetryStatusCodes
+	}
+
+	if Policy.MaxRetryAfter <= 0 {
+		Policy.MaxRetryAfter = DefaultRetryMaxRetryAfter
+	}
+
+	if Policy.MaxBufferedBody <= 0 {
+		Policy.MaxBufferedBody = DefaultRetryBufferSize
+	}
+
+	C.retry = Policy
+}
+
+// RetryPolicy will return the policy the client retries failed requests with.
+func (C *SimpleClient) RetryPolicy() RetryPolicy {
+	return C.retry
+}
+
+// send performs the request, retrying it according to the RetryPolicy of the client.
+func (C *SimpleClient) send(req *http.Request, Span tracing.Span) (*http.Response, error) {
+
+	Policy := C.retry
+	Retryable := Policy.MaxAttempts > 1 && Policy.allows(req) && Policy.replayable(req)
+
+	Attempt := req
+	for Count := 1; ; Count++ {
+
+		Done := func(*http.Response, error) {}
+		if C.breakers != nil {
+			var err error
+			if Done, err = C.breakers.Allow(breakerHost(Attempt)); err != nil {
+				return nil, err
+			}
+		}
+
+		Start := time.Now()
+		resp, err := C.Client.Do(Attempt)
+		C.observe(Attempt, resp, time.Since(Start))
+		Done(resp, err)
+
+		if !Retryable || Count >= Policy.MaxAttempts {
+			return resp, err
+		}
+
+		RetryAfter, Retry := Policy.shouldRetry(req, resp, err)
+		if !Retry {
+			return resp, err
+		}
+
+		Delay := Policy.backoff(Count)
+		if RetryAfter > Delay {
+			Delay = RetryAfter
+		}
+
+		Reason := "connection error"
+		if resp != nil {
+			Reason = resp.Status
+			io.CopyN(io.Discard, resp.Body, 4096)
+			resp.Body.Close()
+		}
+
+		easytls.RequestLogger(req.Context(), C.logger).Info("Retrying request", "method", req.Method, "url", req.URL.Redacted(), "attempt", Count+1, "reason", Reason, "error", err, "delay", Delay)
+		Span.SetAttributes("http.request.resend_count", Count)
+
+		Timer := time.NewTimer(Delay)
+		select {
+		case <-Timer.C:
+		case <-req.Context().Done():
+			Timer.Stop()
+			return nil, req.Context().Err()
+		}
+
+		Attempt = req.Clone(req.Context())
+		if req.GetBody != nil {
+			if Attempt.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// idempotentMethods are the methods which may be safely repeated.
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// allows returns whether the method of the request may be retried.
+func (P RetryPolicy) allows(req *http.Request) bool {
+	return idempotentMethods[req.Method] || P.RetryNonIdempotent || req.Header.Get("Idempotency-Key") != ""
+}
+
+// replayable ensures the body of the request can be sent again, buffering it
+// in memory if it cannot be rewound, and returns whether it can be.
+func (P RetryPolicy) replayable(req *http.Request) bool {
+
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return true
+	}
+
+	Original := req.Body
+	Buffered, err := io.ReadAll(io.LimitReader(Original, P.MaxBufferedBody+1))
+
+	if err != nil || int64(len(Buffered)) > P.MaxBufferedBody {
+		// Send what was read, followed by the remainder, only once.
+		req.Body = &struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(Buffered), Original), Original}
+		return false
+	}
+
+	Original.Close()
+	req.Body = io.NopCloser(bytes.NewReader(Buffered))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(Buffered)), nil
+	}
+
+	return true
+}
+
+// shouldRetry returns whether the outcome of an attempt should be retried,
+// along with any delay requested by the server.
+func (P RetryPolicy) shouldRetry(req *http.Request, resp *http.Response, err error) (time.Duration, bool) {
+
+	if req.Context().Err() != nil {
+		return 0, false
+	}
+
+	if err != nil {
+		var Verification *tls.CertificateVerificationError
+		return 0, !errors.As(err, &Verification)
+	}
+
+	for _, Code := range P.RetryStatusCodes {
+		if resp.StatusCode == Code {
+			RetryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+			return RetryAfter, RetryAfter <= P.MaxRetryAfter
+		}
+	}
+
+	return 0, false
+}
+
+// backoff returns the wait before the given retry, with jitter applied.
+func (P RetryPolicy) backoff(Retry int) time.Duration {
+
+	Delay := float64(P.InitialBackoff) * math.Pow(2, float64(Retry-1))
+	if Delay > float64(P.MaxBackoff) {
+		Delay = float64(P.MaxBackoff)
+	}
+
+	if P.Jitter > 0 {
+		Jitter := math.Min(P.Jitter, 1)
+		Delay = Delay*(1-Jitter) + rand.Float64()*Delay*Jitter
+	}
+
+	return time.Duration(Delay)
+}
+
+// parseRetryAfter parses a Retry-After header, given either in seconds or as
+// an HTTP date, returning 0 if it is absent or invalid.
+func parseRetryAfter(Value string) time.Duration {
+
+	if Value == "" {
+		return 0
+	}
+
+	if Seconds, err := strconv.Atoi(Value); err == nil {
+		if Seconds < 0 {
+			return 0
+		}
+		return time.Duration(Seconds) * time.Second
+	}
+
+	if When, err := http.ParseTime(Value); err == nil {
+		if Delay := time.Until(When); Delay > 0 {
+			return Delay
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+)
+
+func TestRetryStatusCodes(T *testing.T) {
+
+	var Attempts int32
+	Bodies := make(chan string, 3)
+	S := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Body, _ := io.ReadAll(r.Body)
+		Bodies <- string(Body)
+		if atomic.AddInt32(&Attempts, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer S.Close()
+
+	C := newTestClient()
+	C.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+
+	resp, err := C.Put(S.URL, io.NopCloser(strings.NewReader("payload")), nil)
+	if err != nil {
+		T.Fatalf("Failed to perform request: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || atomic.LoadInt32(&Attempts) != 3 {
+		T.Fatalf("Expected success on the third attempt, got %d after %d attempts", resp.StatusCode, Attempts)
+	}
+
+	for i := 0; i < 3; i++ {
+		if Body := <-Bodies; Body != "payload" {
+			T.Fatalf("Expected the buffered body to be sent on every attempt, got [ %s ]", Body)
+		}
+	}
+}
+
+func TestRetryNonIdempotent(T *testing.T) {
+
+	var Attempts int32
+	S := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&Attempts, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer S.Close()
+
+	C := newTestClient()
+	C.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+
+	resp, err := C.Post(S.URL, strings.NewReader("payload"), nil)
+	if err != nil {
+		T.Fatalf("Failed to perform request: %v", err)
+	}
+	resp.Body.Close()
+
+	if atomic.LoadInt32(&Attempts) != 1 {
+		T.Fatalf("Expected a POST to be attempted once, got %d attempts", Attempts)
+	}
+
+	atomic.StoreInt32(&Attempts, 0)
+	resp, err = C.Post(S.URL, strings.NewReader("payload"), map[string][]string{"Idempotency-Key": {"abc"}})
+	if err != nil {
+		T.Fatalf("Failed to perform request: %v", err)
+	}
+	resp.Body.Close()
+
+	if atomic.LoadInt32(&Attempts) != 3 {
+		T.Fatalf("Expected a POST with an Idempotency-Key to be retried, got %d attempts", Attempts)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (F roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return F(r) }
+
+func TestRetryConnectionError(T *testing.T) {
+
+	var Attempts int32
+	C := NewClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&Attempts, 1)
+		return nil, errors.New("connection refused")
+	})})
+	C.SetStructuredLogger(easytls.NewDiscardLogger())
+	C.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+
+	if _, err := C.Get("http://localhost/", nil); err == nil {
+		T.Fatalf("Expected the connection error to be returned")
+	}
+
+	if atomic.LoadInt32(&Attempts) != 3 {
+		T.Fatalf("Expected the connection error to be retried, got %d attempts", Attempts)
+	}
+}
+
+func TestParseRetryAfter(T *testing.T) {
+
+	if D := parseRetryAfter("3"); D != 3*time.Second {
+		T.Fatalf("Expected 3s, got %s", D)
+	}
+
+	if D := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); D < 59*time.Minute {
+		T.Fatalf("Expected about 1h, got %s", D)
+	}
+
+	if D := parseRetryAfter("soon"); D != 0 {
+		T.Fatalf("Expected an invalid value to be ignored, got %s", D)
+	}
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// MaxErrorBodySize is the most of the body of an unsuccessful response kept
+// in a StatusError.
+const MaxErrorBodySize = 4096
+
+// StatusError is returned by CheckStatus and the typed request functions for
+// responses with a status code outside the 200 block.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Header     http.Header
+
+	// Body is the start of the body of the response, up to MaxErrorBodySize bytes.
+	Body []byte
+}
+
+func (E *StatusError) Error() string {
+	Message := fmt.Sprintf("client error: %s %s returned [ %s ]", E.Method, E.URL, E.Status)
+	if len(E.Body) > 0 {
+		Message += " - " + string(bytes.TrimSpace(E.Body))
+	}
+	return Message
+}
+
+// CheckStatus will return a *StatusError if the status code of the response
+// is outside the 200 block, closing its body. Successful responses are left
+// unchanged.
+func CheckStatus(resp *http.Response) error {
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	defer resp.Body.Close()
+
+	Body, _ := io.ReadAll(io.LimitReader(resp.Body, MaxErrorBodySize))
+
+	E := &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       Body,
+	}
+
+	if resp.Request != nil {
+		E.Method = resp.Request.Method
+		E.URL = resp.Request.URL.Redacted()
+	}
+
+	return E
+}
+
+// RequestAs will perform a request with Body encoded by the Codec, unless it
+// is nil, and decode the response into a value of type T. The decoder is
+// chosen by the Content-Type of the response, from those registered with
+// RegisterCodec, falling back to the Codec given. Responses without a body,
+// such as "204 No Content", result in the zero value of T. Responses with a
+// status code outside the 200 block result in a *StatusError.
+func RequestAs[T any](ctx context.Context, C *SimpleClient, Codec Codec, Method string, URL string, Body interface{}, Headers map[string][]string) (T, error) {
+
+	var Result T
+
+	H := http.Header{}
+	for Key, Values := range Headers {
+		H[http.CanonicalHeaderKey(Key)] = Values
+	}
+	if H.Get("Accept") == "" {
+		H.Set("Accept", Codec.ContentType())
+	}
+
+	var Contents io.Reader
+	if Body != nil {
+		Encoded := &bytes.Buffer{}
+		if err := Codec.Encode(Encoded, Body); err != nil {
+			return Result, fmt.Errorf("client error: Failed to encode request body - %w", err)
+		}
+		Contents = Encoded
+		if H.Get("Content-Type") == "" {
+			H.Set("Content-Type", Codec.ContentType())
+		}
+	}
+
+	req, err := NewRequestWithContext(ctx, Method, URL, H, Contents)
+	if err != nil {
+		return Result, err
+	}
+
+	resp, err := C.Do(req)
+	if err != nil {
+		return Result, err
+	}
+
+	if err := CheckStatus(resp); err != nil {
+		return Result, err
+	}
+	defer resp.Body.Close()
+
+	// Peek, so an empty body is distinguished from a malformed one.
+	Buffered := &bytes.Buffer{}
+	if n, _ := io.CopyN(Buffered, resp.Body, 1); n == 0 {
+		return Result, nil
+	}
+
+	if err := codecFor(resp.Header.Get("Content-Type"), Codec).Decode(io.MultiReader(Buffered, resp.Body), &Result); err != nil {
+		return Result, fmt.Errorf("client error: Failed to decode response body - %w", err)
+	}
+
+	return Result, nil
+}
+
+// GetJSON will perform a GET request, decoding the JSON response into a value of type T.
+func GetJSON[T any](ctx context.Context, C *SimpleClient, URL string, Headers map[string][]string) (T, error) {
+	return RequestAs[T](ctx, C, JSON, http.MethodGet, URL, nil, Headers)
+}
+
+// PostJSON will perform a POST request with Body encoded as JSON, decoding
+// the JSON response into a value of type T.
+func PostJSON[T any](ctx context.Context, C *SimpleClient, URL string, Body interface{}, Headers map[string][]string) (T, error) {
+	return RequestAs[T](ctx, C, JSON, http.MethodPost, URL, Body, Headers)
+}
+
+// PutJSON will perform a PUT request with Body encoded as JSON, decoding the
+// JSON response into a value of type T.
+func PutJSON[T any](ctx context.Context, C *SimpleClient, URL string, Body interface{}, Headers map[string][]string) (T, error) {
+	return RequestAs[T](ctx, C, JSON, http.MethodPut, URL, Body, Headers)
+}
+
+// PatchJSON will perform a PATCH request with Body encoded as JSON, decoding
+// the JSON response into a value of type T.
+func PatchJSON[T any](ctx context.Context, C *SimpleClient, URL string, Body interface{}, Headers map[string][]string) (T, error) {
+	return RequestAs[T](ctx, C, JSON, http.MethodPatch, URL, Body, Headers)
+}
+
+// DeleteJSON will perform a DELETE request, decoding the JSON response into a value of type T.
+func DeleteJSON[T any](ctx context.Context, C *SimpleClient, URL string, Headers map[string][]string) (T, error) {
+	return RequestAs[T](ctx, C, JSON, http.MethodDelete, URL, nil, Headers)
+}
+
+// GetXML will perform a GET request, decoding the XML response into a value of type T.
+func GetXML[T any](ctx context.Context, C *SimpleClient, URL string, Headers map[string][]string) (T, error) {
+	return RequestAs[T](ctx, C, XML, http.MethodGet, URL, nil, Headers)
+}
+
+// PostXML will perform a POST request with Body encoded as XML, decoding the
+// XML response into a value of type T.
+func PostXML[T any](ctx context.Context, C *SimpleClient, URL string, Body interface{}, Headers map[string][]string) (T, error) {
+	return RequestAs[T](ctx, C, XML, http.MethodPost, URL, Body, Headers)
+}
@@ -66,5 +66,7 @@ func Init(args ...interface{}) ([]server.SimpleHandler, string, error) {
 		// ... Append your handlers here
 	})
 
-	return h, PluginName, nil
+	// Report any panics in the handlers through the StatusChannel, so they
+	// are attributed to this module.
+	return StatusChannel.ReportPanics(h...), PluginName, nil
 }
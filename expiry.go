@@ -0,0 +1,391 @@
+package easytls
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultExpiryThresholds are the times before expiry at which a warning is
+// logged for a certificate, if no explicit thresholds are provided.
+var DefaultExpiryThresholds = []time.Duration{
+	time.Hour * 24 * 30,
+	time.Hour * 24 * 14,
+	time.Hour * 24 * 7,
+	time.Hour * 24,
+}
+
+// DefaultExpiryCheckInterval is the minimum time between checks of the
+// monitored certificates against the thresholds.
+const DefaultExpiryCheckInterval = time.Hour
+
+// DefaultMaxObservedCertificates is the number of certificates observed from
+// peers which are monitored, if no explicit limit is provided.
+const DefaultMaxObservedCertificates = 1024
+
+// CertificateStatus describes a single monitored certificate and how long
+// remains until it expires.
+type CertificateStatus struct {
+
+	// Source describes where the certificate was found, such as the file it
+	// was read from, or the host which presented it.
+	Source string
+
+	CommonName   string
+	DNSNames     []string `json:",omitempty"`
+	Issuer       string
+	SerialNumber string
+	IsCA         bool
+
+	// Fingerprint is the hex-encoded SHA-256 digest of the certificate.
+	Fingerprint string
+
+	NotBefore time.Time
+	NotAfter  time.Time
+
+	// DaysRemaining is the number of whole days until NotAfter, negative
+	// once the certificate has expired.
+	DaysRemaining int
+	Expired       bool
+}
+
+// monitoredCertificate is a single certificate being monitored, along with the
+// most urgent threshold which has already been logged for it.
+type monitoredCertificate struct {
+	source string
+	cert   *x509.Certificate
+	warned time.Duration
+
+	// When the certificate was last observed, as Unix nanoseconds.
+	seen atomic.Int64
+
+	// Whether this was read from a monitored TLSBundle, rather than observed.
+	fromBundle bool
+}
+
+// ExpiryMonitor tracks the expiry dates of a set of certificates, both from
+// TLSBundles and those observed from peers, logging a warning as each crosses
+// one of the configured thresholds.
+//
+// Checks are performed lazily, at most once per CheckInterval, whenever
+// Poll is called or new certificates are observed.
+type ExpiryMonitor struct {
+
+	// Thresholds are the times before expiry at which warnings are logged.
+	Thresholds []time.Duration
+
+	// CheckInterval is the minimum time between checks of the thresholds.
+	CheckInterval time.Duration
+
+	// MaxObserved is the number of certificates observed from peers which
+	// are monitored. Once reached, the least recently observed are dropped.
+	MaxObserved int
+
+	logger Logger
+
+	mu           *sync.RWMutex
+	lastCheck    time.Time
+	bundles      []TLSBundle
+	certificates map[string]*monitoredCertificate
+}
+
+// NewExpiryMonitor will create a new ExpiryMonitor, writing warnings to the
+// given logger, with the default thresholds and check interval.
+func NewExpiryMonitor(logger Logger) *ExpiryMonitor {
+
+	if logger == nil {
+		logger = NewDefaultStructuredLogger()
+	}
+
+	return &ExpiryMonitor{
+		Thresholds:    DefaultExpiryThresholds,
+		CheckInterval: DefaultExpiryCheckInterval,
+		MaxObserved:   DefaultMaxObservedCertificates,
+		logger:        logger,
+		mu:            &sync.RWMutex{},
+		certificates:  make(map[string]*monitoredCertificate),
+	}
+}
+
+// SetLogger will update the logger warnings are written to.
+func (M *ExpiryMonitor) SetLogger(logger Logger) {
+	M.mu.Lock()
+	defer M.mu.Unlock()
+	M.logger = logger
+}
+
+// AddBundle will add the certificates and Certificate Authorities referenced by
+// the TLSBundle to the monitor. The bundle is re-read on every check, at most
+// once per CheckInterval, so rotated files are picked up. Authorities only present in an existing Pool
+// cannot be monitored.
+func (M *ExpiryMonitor) AddBundle(TLS *TLSBundle) {
+
+	if TLS == nil || !TLS.Enabled {
+		return
+	}
+
+	M.mu.Lock()
+	M.bundles = append(M.bundles, *TLS)
+	M.lastCheck = time.Time{}
+	M.mu.Unlock()
+
+	M.Poll()
+}
+
+// Observe will add the given certificates to the monitor, as found from
+// Source. Only the MaxObserved most recently observed certificates are kept.
+func (M *ExpiryMonitor) Observe(Source string, Certificates ...*x509.Certificate) {
+
+	Now := time.Now()
+	Keys := make([]string, len(Certificates))
+	for i, Cert := range Certificates {
+		Keys[i] = Source + "/" + fingerprint(Cert)
+	}
+
+	// The same certificates are observed over and over, such as on every
+	// request to the same host, so only take the exclusive lock when one is
+	// new or a check is due.
+	M.mu.RLock()
+	Known := true
+	for _, Key := range Keys {
+		if C, ok := M.certificates[Key]; ok {
+			C.seen.Store(Now.UnixNano())
+		} else {
+			Known = false
+		}
+	}
+	Due := M.due(Now)
+	M.mu.RUnlock()
+
+	if Known && !Due {
+		return
+	}
+
+	M.mu.Lock()
+	defer M.mu.Unlock()
+
+	for i, Cert := range Certificates {
+		if _, ok := M.certificates[Keys[i]]; !ok {
+			C := &monitoredCertificate{source: Source, cert: Cert}
+			C.seen.Store(Now.UnixNano())
+			M.certificates[Keys[i]] = C
+			M.lastCheck = time.Time{}
+		}
+	}
+	M.evict()
+
+	if M.due(time.Now()) {
+		M.check()
+	}
+}
+
+// Poll will check the monitored certificates against the thresholds, if at
+// least CheckInterval has passed since the last check.
+func (M *ExpiryMonitor) Poll() {
+
+	M.mu.Lock()
+	defer M.mu.Unlock()
+
+	if !M.due(time.Now()) {
+		return
+	}
+
+	M.check()
+}
+
+// due returns whether a check of the thresholds should be performed. This
+// must be called with the lock held.
+func (M *ExpiryMonitor) due(Now time.Time) bool {
+	return M.lastCheck.IsZero() || Now.Sub(M.lastCheck) >= M.CheckInterval
+}
+
+// evict drops the least recently observed certificates beyond MaxObserved.
+// Certificates from the monitored bundles are always kept. This must be
+// called with the lock held.
+func (M *ExpiryMonitor) evict() {
+
+	Max := M.MaxObserved
+	if Max <= 0 {
+		Max = DefaultMaxObservedCertificates
+	}
+
+	Observed := []string{}
+	for Key, C := range M.certificates {
+		if !C.fromBundle {
+			Observed = append(Observed, Key)
+		}
+	}
+
+	if len(Observed) <= Max {
+		return
+	}
+
+	sort.Slice(Observed, func(i, j int) bool {
+		return M.certificates[Observed[i]].seen.Load() < M.certificates[Observed[j]].seen.Load()
+	})
+
+	for _, Key := range Observed[:len(Observed)-Max] {
+		delete(M.certificates, Key)
+	}
+}
+
+// Check will immediately check all monitored certificates against the
+// thresholds, logging a warning for each which has crossed a new threshold.
+func (M *ExpiryMonitor) Check() {
+	M.mu.Lock()
+	defer M.mu.Unlock()
+	M.check()
+}
+
+// Status returns the current status of all monitored certificates, ordered by
+// the soonest to expire.
+func (M *ExpiryMonitor) Status() []CertificateStatus {
+	return M.ExpiringWithin(-1)
+}
+
+// ExpiringWithin returns the status of all monitored certificates which expire
+// within the given duration, ordered by the soonest to expire. A negative
+// duration returns all certificates. This performs a check first, as Poll
+// does, if one is due.
+func (M *ExpiryMonitor) ExpiringWithin(Within time.Duration) []CertificateStatus {
+
+	M.mu.Lock()
+	Now := time.Now()
+	if M.due(Now) {
+		M.check()
+	}
+	Statuses := []CertificateStatus{}
+	for _, C := range M.certificates {
+		if Within < 0 || C.cert.NotAfter.Sub(Now) <= Within {
+			Statuses = append(Statuses, newCertificateStatus(C.source, C.cert, Now))
+		}
+	}
+	M.mu.Unlock()
+
+	sort.Slice(Statuses, func(i, j int) bool {
+		if !Statuses[i].NotAfter.Equal(Statuses[j].NotAfter) {
+			return Statuses[i].NotAfter.Before(Statuses[j].NotAfter)
+		}
+		return Statuses[i].Source < Statuses[j].Source
+	})
+
+	return Statuses
+}
+
+// check must be called with the lock held.
+func (M *ExpiryMonitor) check() {
+
+	M.lastCheck = time.Now()
+	M.refresh()
+
+	// Check the most urgent thresholds first.
+	Thresholds := append([]time.Duration{}, M.Thresholds...)
+	sort.Slice(Thresholds, func(i, j int) bool { return Thresholds[i] < Thresholds[j] })
+
+	for _, C := range M.certificates {
+		Remaining := time.Until(C.cert.NotAfter)
+		for _, Threshold := range Thresholds {
+			if Remaining > Threshold {
+				continue
+			}
+			if C.warned == 0 || Threshold < C.warned {
+				C.warned = Threshold
+				if Remaining <= 0 {
+					M.logger.Warn("Certificate has expired", "subject", C.cert.Subject.CommonName, "source", C.source, "not_after", C.cert.NotAfter)
+				} else {
+					M.logger.Warn("Certificate expires soon", "subject", C.cert.Subject.CommonName, "source", C.source, "days_remaining", daysRemaining(Remaining), "not_after", C.cert.NotAfter)
+				}
+			}
+			break
+		}
+	}
+}
+
+// refresh re-reads the certificates of the monitored bundles, replacing any
+// previously read from them. This must be called with the lock held.
+func (M *ExpiryMonitor) refresh() {
+
+	if len(M.bundles) == 0 {
+		return
+	}
+
+	Current := make(map[string]*monitoredCertificate)
+
+	for i := range M.bundles {
+		TLS := &M.bundles[i]
+
+		Certificates, err := TLS.loadCertificates()
+		if err != nil {
+			M.logger.Error("Failed to read certificates to monitor for expiry", "error", err)
+		}
+
+		Source := "TLSBundle"
+		if TLS.KeyPair.Certificate != "" && len(TLS.KeyPair.CertificatePEM) == 0 {
+			Source = TLS.KeyPair.Certificate
+		}
+
+		for _, Cert := range Certificates {
+			for _, Raw := range Cert.Certificate {
+				if Parsed, err := x509.ParseCertificate(Raw); err == nil {
+					Current[Source+"/"+fingerprint(Parsed)] = &monitoredCertificate{source: Source, cert: Parsed}
+				}
+			}
+		}
+
+		for _, Cert := range TLS.authorityCertificates() {
+			Current["Authorities/"+fingerprint(Cert)] = &monitoredCertificate{source: "Authorities", cert: Cert}
+		}
+	}
+
+	// Drop bundle certificates which are no longer present, keeping those
+	// observed from peers, and keep the warnings already logged.
+	for Key, C := range M.certificates {
+		if C.fromBundle {
+			if Replacement, ok := Current[Key]; ok {
+				Replacement.warned = C.warned
+			}
+			delete(M.certificates, Key)
+		}
+	}
+
+	for Key, C := range Current {
+		C.fromBundle = true
+		M.certificates[Key] = C
+	}
+}
+
+func newCertificateStatus(Source string, Cert *x509.Certificate, Now time.Time) CertificateStatus {
+
+	Remaining := Cert.NotAfter.Sub(Now)
+
+	return CertificateStatus{
+		Source:        Source,
+		CommonName:    Cert.Subject.CommonName,
+		DNSNames:      Cert.DNSNames,
+		Issuer:        Cert.Issuer.String(),
+		SerialNumber:  Cert.SerialNumber.String(),
+		IsCA:          Cert.IsCA,
+		Fingerprint:   fingerprint(Cert),
+		NotBefore:     Cert.NotBefore,
+		NotAfter:      Cert.NotAfter,
+		DaysRemaining: daysRemaining(Remaining),
+		Expired:       Remaining <= 0,
+	}
+}
+
+func daysRemaining(Remaining time.Duration) int {
+	Days := int(Remaining / (time.Hour * 24))
+	if Remaining < 0 && Remaining%(time.Hour*24) != 0 {
+		Days--
+	}
+	return Days
+}
+
+func fingerprint(Cert *x509.Certificate) string {
+	Sum := sha256.Sum256(Cert.Raw)
+	return hex.EncodeToString(Sum[:])
+}
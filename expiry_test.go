@@ -0,0 +1,167 @@
+package easytls_test
+
+import (
+	"bytes"
+	"crypto/x509"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+	"github.com/Bearnie-H/easy-tls/client"
+)
+
+func TestExpiryMonitor(T *testing.T) {
+
+	CA, ServerBundle, ClientBundle := newTestAuthority(T)
+
+	Soon, err := CA.IssueServerCertificate(easytls.CertificateOptions{
+		CommonName:  "expiring",
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		Lifetime:    time.Hour * 36,
+	})
+	if err != nil {
+		T.Fatalf("Failed to issue certificate - %s", err)
+	}
+
+	Output := &bytes.Buffer{}
+	Monitor := easytls.NewExpiryMonitor(easytls.NewStdLogger(log.New(Output, "", 0), easytls.LevelInfo))
+	Monitor.Thresholds = []time.Duration{time.Hour * 24 * 7, time.Hour * 48}
+
+	Monitor.AddBundle(ServerBundle)
+	Monitor.Observe("peer", Soon.Certificate)
+
+	if !strings.Contains(Output.String(), "subject=expiring source=peer days_remaining=1") {
+		T.Fatalf("Expected a warning for the expiring certificate, got [ %s ]", Output.String())
+	}
+
+	// The bundle certificates are far from expiring, and must not be warned about.
+	if Lines := strings.Count(Output.String(), "\n"); Lines != 1 {
+		T.Fatalf("Expected exactly one warning, got [ %s ]", Output.String())
+	}
+
+	if len(Monitor.Status()) != 3 {
+		T.Fatalf("Expected the bundle certificate, authority and observed certificate to be monitored, got %+v", Monitor.Status())
+	}
+
+	// Checking again must not repeat warnings for thresholds already crossed.
+	Output.Reset()
+	Monitor.Check()
+	if Output.Len() != 0 {
+		T.Fatalf("Expected no repeated warnings, got [ %s ]", Output.String())
+	}
+
+	Expiring := Monitor.ExpiringWithin(time.Hour * 24 * 2)
+	if len(Expiring) != 1 || Expiring[0].CommonName != "expiring" || Expiring[0].DaysRemaining != 1 {
+		T.Fatalf("Expected only the expiring certificate to be listed, got %+v", Expiring)
+	}
+
+	// The certificates presented by servers are observed by clients.
+	URL := startTestServer(T, ServerBundle)
+
+	C, err := client.NewClientHTTPS(ClientBundle)
+	if err != nil {
+		T.Fatalf("Failed to create client - %s", err)
+	}
+	C.SetStructuredLogger(testLogger())
+
+	if C.ExpiryMonitor() != nil {
+		T.Fatalf("Expected expiry monitoring to be disabled by default")
+	}
+	C.EnableExpiryMonitoring()
+
+	resp, err := C.Get(URL+"/hello", nil)
+	if err != nil {
+		T.Fatalf("Failed to perform request - %s", err)
+	}
+	resp.Body.Close()
+
+	Found := false
+	for _, Status := range C.ExpiryMonitor().Status() {
+		if Status.CommonName == "server" && Status.Source == strings.TrimPrefix(URL, "https://") {
+			Found = true
+		}
+	}
+	if !Found {
+		T.Fatalf("Expected the server certificate to be observed by the client")
+	}
+}
+
+func TestExpiryMonitorMaxObserved(T *testing.T) {
+
+	CA, err := easytls.NewCertificateAuthority("EasyTLS Test CA", 0)
+	if err != nil {
+		T.Fatalf("Failed to create CA - %s", err)
+	}
+
+	Monitor := easytls.NewExpiryMonitor(testLogger())
+	Monitor.MaxObserved = 2
+
+	Certificates := map[string]*x509.Certificate{}
+	for _, Name := range []string{"first", "second", "third"} {
+		Cert, err := CA.IssueServerCertificate(easytls.CertificateOptions{CommonName: Name, DNSNames: []string{Name}})
+		if err != nil {
+			T.Fatalf("Failed to issue certificate - %s", err)
+		}
+		Certificates[Name] = Cert.Certificate
+	}
+
+	// Observing the first again keeps it, so the second is dropped instead.
+	for _, Name := range []string{"first", "second", "first", "third"} {
+		Monitor.Observe(Name, Certificates[Name])
+		time.Sleep(time.Millisecond)
+	}
+
+	Sources := map[string]bool{}
+	for _, Status := range Monitor.Status() {
+		Sources[Status.Source] = true
+	}
+	if len(Sources) != 2 || !Sources["first"] || !Sources["third"] {
+		T.Fatalf("Expected only the two most recently observed certificates to be kept, got %v", Sources)
+	}
+}
+
+func TestExpiryMonitorRereadInterval(T *testing.T) {
+
+	CA, ServerBundle, _ := newTestAuthority(T)
+
+	Monitor := easytls.NewExpiryMonitor(testLogger())
+	Monitor.AddBundle(ServerBundle)
+
+	if len(Monitor.Status()) != 2 {
+		T.Fatalf("Expected the bundle certificate and authority to be monitored")
+	}
+
+	Rotated, err := CA.IssueServerCertificate(easytls.CertificateOptions{CommonName: "rotated", DNSNames: []string{"localhost"}})
+	if err != nil {
+		T.Fatalf("Failed to issue certificate - %s", err)
+	}
+	if err := os.WriteFile(ServerBundle.KeyPair.Certificate, Rotated.CertificatePEM(), 0644); err != nil {
+		T.Fatalf("Failed to rotate certificate - %s", err)
+	}
+	if err := os.WriteFile(ServerBundle.KeyPair.Key, mustKeyPEM(T, Rotated), 0600); err != nil {
+		T.Fatalf("Failed to rotate key - %s", err)
+	}
+
+	// The bundle is not re-read until the next check is due.
+	for _, Status := range Monitor.Status() {
+		if Status.CommonName == "rotated" {
+			T.Fatalf("Expected the bundle not to be re-read before the check interval")
+		}
+	}
+
+	Monitor.CheckInterval = time.Nanosecond
+
+	Found := false
+	for _, Status := range Monitor.Status() {
+		if Status.CommonName == "rotated" {
+			Found = true
+		}
+	}
+	if !Found {
+		T.Fatalf("Expected the rotated certificate once a check is due")
+	}
+}
@@ -1,5 +1,8 @@
 module github.com/Bearnie-H/easy-tls
 
-go 1.16
+go 1.21
 
-require github.com/gorilla/mux v1.8.0
+require (
+	github.com/gorilla/mux v1.8.0
+	golang.org/x/crypto v0.33.0
+)
@@ -1,13 +1,275 @@
 package easytls
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
+	"strings"
 )
 
+// Level is the severity of a single log message. The values match those of
+// log/slog, so a Level can be converted directly to a slog.Level.
+type Level int
+
+// The set of log levels used throughout this project.
+const (
+	LevelDebug Level = -4
+	LevelInfo  Level = 0
+	LevelWarn  Level = 4
+	LevelError Level = 8
+)
+
+func (L Level) String() string {
+	return slog.Level(L).String()
+}
+
+// Logger is the leveled, structured logging interface accepted by all of the
+// packages of this project.
+//
+// Each message is a constant string, with any variable parts attached as
+// alternating key/value pairs, as with log/slog. Adapters for the standard
+// *log.Logger and *slog.Logger are provided by NewStdLogger and NewSlogLogger.
+type Logger interface {
+	Debug(Message string, KeysAndValues ...interface{})
+	Info(Message string, KeysAndValues ...interface{})
+	Warn(Message string, KeysAndValues ...interface{})
+	Error(Message string, KeysAndValues ...interface{})
+
+	// With returns a Logger which attaches the given key/value pairs to all
+	// messages it writes.
+	With(KeysAndValues ...interface{}) Logger
+}
+
 // NewDefaultLogger will initialize a new logger to the default used by this library.
 // This will write to STDOUT, with no additional prefix beyond the date provided by
 // log.Lstdflags.
 func NewDefaultLogger() *log.Logger {
 	return log.New(os.Stdout, "", log.LstdFlags)
 }
+
+// NewDefaultStructuredLogger will initialize a new Logger to the default used
+// by this library. This writes the messages at LevelInfo and above to the
+// output of NewDefaultLogger.
+func NewDefaultStructuredLogger() Logger {
+	return NewStdLogger(NewDefaultLogger(), LevelInfo)
+}
+
+// NewDiscardLogger will create a Logger which drops all messages.
+func NewDiscardLogger() Logger {
+	return NewStdLogger(log.New(io.Discard, "", 0), LevelError+1)
+}
+
+// stdLogger adapts a standard *log.Logger to the Logger interface.
+type stdLogger struct {
+	out     *log.Logger
+	minimum Level
+	fields  []interface{}
+}
+
+// NewStdLogger will wrap a standard *log.Logger as a Logger, writing all messages
+// at or above Minimum as a single line of the form:
+//
+//	LEVEL Message key=value key=value ...
+func NewStdLogger(l *log.Logger, Minimum Level) Logger {
+	if l == nil {
+		l = NewDefaultLogger()
+	}
+	return &stdLogger{out: l, minimum: Minimum}
+}
+
+func (L *stdLogger) Debug(Message string, KeysAndValues ...interface{}) {
+	L.log(LevelDebug, Message, KeysAndValues)
+}
+
+func (L *stdLogger) Info(Message string, KeysAndValues ...interface{}) {
+	L.log(LevelInfo, Message, KeysAndValues)
+}
+
+func (L *stdLogger) Warn(Message string, KeysAndValues ...interface{}) {
+	L.log(LevelWarn, Message, KeysAndValues)
+}
+
+func (L *stdLogger) Error(Message string, KeysAndValues ...interface{}) {
+	L.log(LevelError, Message, KeysAndValues)
+}
+
+func (L *stdLogger) With(KeysAndValues ...interface{}) Logger {
+	Fields := make([]interface{}, 0, len(L.fields)+len(KeysAndValues))
+	Fields = append(Fields, L.fields...)
+	Fields = append(Fields, KeysAndValues...)
+	return &stdLogger{out: L.out, minimum: L.minimum, fields: Fields}
+}
+
+func (L *stdLogger) log(Level Level, Message string, KeysAndValues []interface{}) {
+
+	if Level < L.minimum {
+		return
+	}
+
+	b := &strings.Builder{}
+	b.WriteString(Level.String())
+	b.WriteByte(' ')
+	b.WriteString(Message)
+	writeFields(b, L.fields)
+	writeFields(b, KeysAndValues)
+
+	L.out.Output(3, b.String())
+}
+
+// writeFields will append the key/value pairs to b, following the same
+// conventions as log/slog for unpaired values.
+func writeFields(b *strings.Builder, KeysAndValues []interface{}) {
+	for i := 0; i < len(KeysAndValues); i += 2 {
+
+		Key, ok := KeysAndValues[i].(string)
+		if !ok || i+1 == len(KeysAndValues) {
+			fmt.Fprintf(b, " !BADKEY=%s", formatValue(KeysAndValues[i]))
+			i--
+			continue
+		}
+
+		fmt.Fprintf(b, " %s=%s", Key, formatValue(KeysAndValues[i+1]))
+	}
+}
+
+func formatValue(Value interface{}) string {
+
+	var s string
+	switch v := Value.(type) {
+	case error:
+		s = v.Error()
+	case fmt.Stringer:
+		s = v.String()
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		return fmt.Sprintf("%q", s)
+	}
+
+	return s
+}
+
+// slogLogger adapts a *slog.Logger to the Logger interface.
+type slogLogger struct {
+	out *slog.Logger
+}
+
+// NewSlogLogger will wrap a *slog.Logger as a Logger. Levels and key/value
+// pairs are passed through unchanged, so any slog.Handler, such as
+// slog.JSONHandler, can be used.
+func NewSlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &slogLogger{out: l}
+}
+
+func (L *slogLogger) Debug(Message string, KeysAndValues ...interface{}) {
+	L.out.Log(context.Background(), slog.LevelDebug, Message, KeysAndValues...)
+}
+
+func (L *slogLogger) Info(Message string, KeysAndValues ...interface{}) {
+	L.out.Log(context.Background(), slog.LevelInfo, Message, KeysAndValues...)
+}
+
+func (L *slogLogger) Warn(Message string, KeysAndValues ...interface{}) {
+	L.out.Log(context.Background(), slog.LevelWarn, Message, KeysAndValues...)
+}
+
+func (L *slogLogger) Error(Message string, KeysAndValues ...interface{}) {
+	L.out.Log(context.Background(), slog.LevelError, Message, KeysAndValues...)
+}
+
+func (L *slogLogger) With(KeysAndValues ...interface{}) Logger {
+	return &slogLogger{out: L.out.With(KeysAndValues...)}
+}
+
+// logWriter is an io.Writer which forwards each write as a single message to
+// a Logger.
+type logWriter struct {
+	logger Logger
+	level  Level
+}
+
+func (W *logWriter) Write(p []byte) (int, error) {
+
+	logAt(W.logger, W.level, string(bytes.TrimRight(p, "\n")))
+	return len(p), nil
+}
+
+// logAt writes the message to the Logger with the method for the Level.
+func logAt(l Logger, Level Level, Message string) {
+	switch {
+	case Level >= LevelError:
+		l.Error(Message)
+	case Level >= LevelWarn:
+		l.Warn(Message)
+	case Level >= LevelInfo:
+		l.Info(Message)
+	default:
+		l.Debug(Message)
+	}
+}
+
+// WriteLine will write a preformatted line, such as an access log in the
+// Common Log Format, to the Logger at the given Level. A Logger created by
+// NewStdLogger writes the line as it is, without the Level or any fields, so
+// the line keeps its format. Any other Logger gets the line as a message.
+func WriteLine(l Logger, Level Level, Line string) {
+
+	if S, ok := l.(*stdLogger); ok {
+		if Level >= S.minimum {
+			S.out.Output(2, Line)
+		}
+		return
+	}
+
+	logAt(l, Level, Line)
+}
+
+// NewLogLogger will create a standard *log.Logger which writes each message to
+// the given Logger at the given Level. This is intended for APIs which still
+// require a *log.Logger, such as http.Server.ErrorLog.
+func NewLogLogger(l Logger, Level Level) *log.Logger {
+	if l == nil {
+		l = NewDefaultStructuredLogger()
+	}
+	return log.New(&logWriter{logger: l, level: Level}, "", 0)
+}
+
+// AsLogger returns the standard *log.Logger as a Logger, for the APIs which
+// accepted a *log.Logger before Logger was introduced. One created by
+// NewLogLogger is unwrapped to the Logger it writes to, so no level is lost
+// passing a logger back and forth. Any other is wrapped with NewStdLogger at
+// LevelInfo, and a nil one gives NewDefaultStructuredLogger.
+func AsLogger(l *log.Logger) Logger {
+
+	if l == nil {
+		return NewDefaultStructuredLogger()
+	}
+
+	if W, ok := l.Writer().(*logWriter); ok {
+		return W.logger
+	}
+
+	return NewStdLogger(l, LevelInfo)
+}
+
+// AsLogLogger returns the Logger as a standard *log.Logger, for the APIs
+// which returned a *log.Logger before Logger was introduced. One created by
+// NewStdLogger is unwrapped to the *log.Logger it writes to, and any other
+// is wrapped with NewLogLogger at LevelInfo.
+func AsLogLogger(l Logger) *log.Logger {
+
+	if S, ok := l.(*stdLogger); ok && len(S.fields) == 0 {
+		return S.out
+	}
+
+	return NewLogLogger(l, LevelInfo)
+}
@@ -0,0 +1,60 @@
+package easytls_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+)
+
+func TestStdLogger(T *testing.T) {
+
+	Output := &bytes.Buffer{}
+	Logger := easytls.NewStdLogger(log.New(Output, "", 0), easytls.LevelInfo)
+
+	Logger.Debug("Hidden message")
+	if Output.Len() != 0 {
+		T.Fatalf("Expected messages below the minimum level to be dropped, got [ %s ]", Output.String())
+	}
+
+	Logger.With("addr", ":8080").Warn("Request failed", "url", "/a b", "error", errors.New("timeout"))
+
+	Expected := `WARN Request failed addr=:8080 url="/a b" error=timeout` + "\n"
+	if Output.String() != Expected {
+		T.Fatalf("Expected [ %s ], got [ %s ]", Expected, Output.String())
+	}
+}
+
+func TestSlogLogger(T *testing.T) {
+
+	Output := &bytes.Buffer{}
+	Logger := easytls.NewSlogLogger(slog.New(slog.NewJSONHandler(Output, nil)))
+
+	Logger.With("module", "example").Error("Failed to start", "attempt", 2)
+
+	Record := map[string]interface{}{}
+	if err := json.Unmarshal(Output.Bytes(), &Record); err != nil {
+		T.Fatalf("Failed to decode JSON log record - %s", err)
+	}
+
+	if Record["level"] != "ERROR" || Record["msg"] != "Failed to start" || Record["module"] != "example" || Record["attempt"] != float64(2) {
+		T.Fatalf("Unexpected log record %+v", Record)
+	}
+}
+
+func TestLogLogger(T *testing.T) {
+
+	Output := &bytes.Buffer{}
+	Logger := easytls.NewLogLogger(easytls.NewStdLogger(log.New(Output, "", 0), easytls.LevelInfo), easytls.LevelError)
+
+	Logger.Printf("http: TLS handshake error from %s", "127.0.0.1")
+
+	if !strings.HasPrefix(Output.String(), "ERROR http: TLS handshake error from 127.0.0.1\n") {
+		T.Fatalf("Expected the message to be forwarded at LevelError, got [ %s ]", Output.String())
+	}
+}
@@ -0,0 +1,13 @@
+// Package metrics implements a small, dependency-free metrics registry,
+// exposed in the Prometheus / OpenMetrics text exposition format.
+//
+// A Registry holds a set of named metric families, each of which is one of a
+// Counter, Gauge or Histogram, partitioned by an ordered set of label names.
+// Families are created on first use, and retrieving a family which already
+// exists returns the existing one, so independent components can safely share
+// a single Registry.
+//
+// The other packages of this library use this to instrument the requests
+// served by a SimpleServer, the requests performed by a SimpleClient, the
+// upstream requests of the reverse proxy, and the modules of a plugin Agent.
+package metrics
@@ -0,0 +1,336 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the Content-Type of the text exposition format written by WriteText.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the default upper bounds of Histogram buckets, suitable
+// for request latencies measured in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Default is the Registry used by this library when none is explicitly given.
+var Default = NewRegistry()
+
+// The types of metric families.
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+// Sample is a single value reported by the function of a GaugeFunc.
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// Registry is a set of metric families.
+type Registry struct {
+	mu       *sync.Mutex
+	families map[string]*family
+}
+
+// NewRegistry will create a new, empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		mu:       &sync.Mutex{},
+		families: make(map[string]*family),
+	}
+}
+
+// family is a single named metric, with one series per set of label values.
+type family struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+
+	mu      *sync.Mutex
+	series  map[string]*series
+	collect func() []Sample
+}
+
+// series is the current value of a family for one set of label values.
+type series struct {
+	labelValues []string
+
+	value float64
+
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Counter is a metric family whose values only ever increase.
+type Counter struct{ f *family }
+
+// Gauge is a metric family whose values may increase or decrease.
+type Gauge struct{ f *family }
+
+// Histogram is a metric family which counts observations into buckets.
+type Histogram struct{ f *family }
+
+// Counter will return the Counter with the given name, creating it if it does
+// not already exist.
+func (R *Registry) Counter(Name, Help string, Labels ...string) *Counter {
+	return &Counter{R.family(Name, Help, kindCounter, Labels, nil, nil)}
+}
+
+// Gauge will return the Gauge with the given name, creating it if it does not
+// already exist.
+func (R *Registry) Gauge(Name, Help string, Labels ...string) *Gauge {
+	return &Gauge{R.family(Name, Help, kindGauge, Labels, nil, nil)}
+}
+
+// Histogram will return the Histogram with the given name, creating it if it
+// does not already exist. DefaultBuckets are used if no Buckets are given.
+func (R *Registry) Histogram(Name, Help string, Buckets []float64, Labels ...string) *Histogram {
+
+	if len(Buckets) == 0 {
+		Buckets = DefaultBuckets
+	}
+
+	Sorted := append([]float64{}, Buckets...)
+	sort.Float64s(Sorted)
+
+	return &Histogram{R.family(Name, Help, kindHistogram, Labels, Sorted, nil)}
+}
+
+// GaugeFunc will register a Gauge whose values are computed by calling
+// Collect each time the Registry is written out. If a family with the given
+// name already exists, Collect replaces its previous function.
+func (R *Registry) GaugeFunc(Name, Help string, Labels []string, Collect func() []Sample) {
+	R.family(Name, Help, kindGauge, Labels, nil, Collect)
+}
+
+// family will return the named family, creating it if required. This panics
+// if the family exists with a different type or set of labels, as the two
+// cannot be exposed together.
+func (R *Registry) family(Name, Help, Kind string, Labels []string, Buckets []float64, Collect func() []Sample) *family {
+
+	R.mu.Lock()
+	defer R.mu.Unlock()
+
+	if f, ok := R.families[Name]; ok {
+		if f.kind != Kind || strings.Join(f.labels, ",") != strings.Join(Labels, ",") {
+			panic(fmt.Sprintf("metrics error: Metric [ %s ] already registered as a %s with labels %v", Name, f.kind, f.labels))
+		}
+		if Collect != nil {
+			f.mu.Lock()
+			f.collect = Collect
+			f.mu.Unlock()
+		}
+		return f
+	}
+
+	f := &family{
+		name:    Name,
+		help:    Help,
+		kind:    Kind,
+		labels:  append([]string{}, Labels...),
+		buckets: Buckets,
+		mu:      &sync.Mutex{},
+		series:  make(map[string]*series),
+		collect: Collect,
+	}
+	R.families[Name] = f
+
+	return f
+}
+
+// with returns the series for the label values, creating it if required.
+// This must be called with the lock of the family held.
+func (f *family) with(LabelValues []string) *series {
+
+	if len(LabelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics error: Metric [ %s ] expects %d label values, got %d", f.name, len(f.labels), len(LabelValues)))
+	}
+
+	Key := strings.Join(LabelValues, "\xff")
+	s, ok := f.series[Key]
+	if !ok {
+		s = &series{labelValues: append([]string{}, LabelValues...)}
+		if f.kind == kindHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[Key] = s
+	}
+
+	return s
+}
+
+// Inc will increment the Counter by 1.
+func (C *Counter) Inc(LabelValues ...string) {
+	C.Add(1, LabelValues...)
+}
+
+// Add will increase the Counter by Value, which must not be negative.
+func (C *Counter) Add(Value float64, LabelValues ...string) {
+	if Value < 0 {
+		return
+	}
+	C.f.mu.Lock()
+	C.f.with(LabelValues).value += Value
+	C.f.mu.Unlock()
+}
+
+// Set will set the Gauge to Value.
+func (G *Gauge) Set(Value float64, LabelValues ...string) {
+	G.f.mu.Lock()
+	G.f.with(LabelValues).value = Value
+	G.f.mu.Unlock()
+}
+
+// Add will add Value, which may be negative, to the Gauge.
+func (G *Gauge) Add(Value float64, LabelValues ...string) {
+	G.f.mu.Lock()
+	G.f.with(LabelValues).value += Value
+	G.f.mu.Unlock()
+}
+
+// Inc will increment the Gauge by 1.
+func (G *Gauge) Inc(LabelValues ...string) {
+	G.Add(1, LabelValues...)
+}
+
+// Dec will decrement the Gauge by 1.
+func (G *Gauge) Dec(LabelValues ...string) {
+	G.Add(-1, LabelValues...)
+}
+
+// Observe will add a single observation of Value to the Histogram.
+func (H *Histogram) Observe(Value float64, LabelValues ...string) {
+
+	H.f.mu.Lock()
+	defer H.f.mu.Unlock()
+
+	s := H.f.with(LabelValues)
+	for i, Bound := range H.f.buckets {
+		if Value <= Bound {
+			s.counts[i]++
+		}
+	}
+	s.sum += Value
+	s.count++
+}
+
+// WriteText will write the current value of every metric in the Registry to
+// w, in the text exposition format.
+func (R *Registry) WriteText(w io.Writer) error {
+
+	R.mu.Lock()
+	Families := make([]*family, 0, len(R.families))
+	for _, f := range R.families {
+		Families = append(Families, f)
+	}
+	R.mu.Unlock()
+
+	sort.Slice(Families, func(i, j int) bool { return Families[i].name < Families[j].name })
+
+	b := bufio.NewWriter(w)
+	for _, f := range Families {
+		f.writeText(b)
+	}
+
+	return b.Flush()
+}
+
+// Handler will return a http.Handler which serves the Registry in the text
+// exposition format.
+func (R *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		R.WriteText(w)
+	})
+}
+
+func (f *family) writeText(w *bufio.Writer) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	Series := make([]*series, 0, len(f.series))
+	for _, s := range f.series {
+		Series = append(Series, s)
+	}
+
+	if f.collect != nil {
+		Series = Series[:0]
+		for _, Sample := range f.collect() {
+			if len(Sample.LabelValues) == len(f.labels) {
+				Series = append(Series, &series{labelValues: Sample.LabelValues, value: Sample.Value})
+			}
+		}
+	}
+
+	sort.Slice(Series, func(i, j int) bool {
+		return strings.Join(Series[i].labelValues, "\xff") < strings.Join(Series[j].labelValues, "\xff")
+	})
+
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+
+	for _, s := range Series {
+		if f.kind != kindHistogram {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, formatLabels(f.labels, s.labelValues, "", 0), formatFloat(s.value))
+			continue
+		}
+
+		for i, Bound := range f.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, s.labelValues, "le", Bound), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, s.labelValues, "le", math.Inf(1)), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name, formatLabels(f.labels, s.labelValues, "", 0), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.name, formatLabels(f.labels, s.labelValues, "", 0), s.count)
+	}
+}
+
+// formatLabels formats the label set of a single line, with an optional
+// additional label, such as the "le" label of a histogram bucket.
+func formatLabels(Names, Values []string, Extra string, ExtraValue float64) string {
+
+	if len(Names) == 0 && Extra == "" {
+		return ""
+	}
+
+	Pairs := make([]string, 0, len(Names)+1)
+	for i, Name := range Names {
+		Pairs = append(Pairs, fmt.Sprintf("%s=\"%s\"", Name, escapeLabel(Values[i])))
+	}
+	if Extra != "" {
+		Pairs = append(Pairs, fmt.Sprintf("%s=\"%s\"", Extra, formatFloat(ExtraValue)))
+	}
+
+	return "{" + strings.Join(Pairs, ",") + "}"
+}
+
+func formatFloat(Value float64) string {
+	switch {
+	case math.IsInf(Value, 1):
+		return "+Inf"
+	case math.IsInf(Value, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(Value, 'g', -1, 64)
+	}
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWriteText(T *testing.T) {
+
+	R := NewRegistry()
+
+	R.Counter("requests_total", "Total requests.", "code").Add(3, "200")
+	R.Counter("requests_total", "Total requests.", "code").Inc("500")
+	R.Gauge("temperature", "Current \"temperature\".").Set(21.5)
+	R.GaugeFunc("modules", "Known modules.", []string{"name"}, func() []Sample {
+		return []Sample{{LabelValues: []string{`a"b`}, Value: 1}}
+	})
+
+	H := R.Histogram("latency_seconds", "Request latency.", []float64{0.5, 0.1}, "route")
+	H.Observe(0.05, "/a")
+	H.Observe(0.3, "/a")
+	H.Observe(2, "/a")
+
+	Output := &bytes.Buffer{}
+	if err := R.WriteText(Output); err != nil {
+		T.Fatalf("Failed to write metrics - %s", err)
+	}
+
+	Expected := strings.Join([]string{
+		`# HELP latency_seconds Request latency.`,
+		`# TYPE latency_seconds histogram`,
+		`latency_seconds_bucket{route="/a",le="0.1"} 1`,
+		`latency_seconds_bucket{route="/a",le="0.5"} 2`,
+		`latency_seconds_bucket{route="/a",le="+Inf"} 3`,
+		`latency_seconds_sum{route="/a"} 2.35`,
+		`latency_seconds_count{route="/a"} 3`,
+		`# HELP modules Known modules.`,
+		`# TYPE modules gauge`,
+		`modules{name="a\"b"} 1`,
+		`# HELP requests_total Total requests.`,
+		`# TYPE requests_total counter`,
+		`requests_total{code="200"} 3`,
+		`requests_total{code="500"} 1`,
+		`# HELP temperature Current "temperature".`,
+		`# TYPE temperature gauge`,
+		`temperature 21.5`,
+	}, "\n") + "\n"
+
+	if Output.String() != Expected {
+		T.Fatalf("Unexpected exposition output:\n%s\nExpected:\n%s", Output.String(), Expected)
+	}
+}
+
+func TestRegistryConflictingFamily(T *testing.T) {
+
+	R := NewRegistry()
+	R.Counter("conflict", "A counter.")
+
+	defer func() {
+		if recover() == nil {
+			T.Fatalf("Expected registering a conflicting metric type to panic")
+		}
+	}()
+
+	R.Gauge("conflict", "A gauge.")
+}
@@ -0,0 +1,166 @@
+package easytls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// The maximum size of an OCSP response which will be read from a responder.
+const maxOCSPResponseSize = 1 << 20
+
+// The allowance for the clock of a responder being ahead of ours, when
+// checking that a response is not from the future.
+const ocspClockSkew = time.Minute * 5
+
+// ocspStatus is the parsed and verified status of a single certificate.
+type ocspStatus struct {
+	Raw        []byte
+	Revoked    bool
+	ThisUpdate time.Time
+	NextUpdate time.Time
+}
+
+// fetchOCSPStatus will ask the OCSP Responder at URL for the status of Cert.
+func fetchOCSPStatus(Client *http.Client, URL string, Cert, Issuer *x509.Certificate) (*ocspStatus, error) {
+
+	Request, err := ocsp.CreateRequest(Cert, Issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := Client.Post(URL, "application/ocsp-request", bytes.NewReader(Request))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("easytls ocsp error: Responder [ %s ] returned status [ %s ]", URL, resp.Status)
+	}
+
+	Raw, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxOCSPResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseOCSPResponse(Raw, Cert, Issuer, time.Now())
+}
+
+// parseOCSPResponse will parse and verify an OCSP response, returning the
+// status of Cert as of Now.
+func parseOCSPResponse(Raw []byte, Cert, Issuer *x509.Certificate, Now time.Time) (*ocspStatus, error) {
+
+	// This checks the response is signed either by the issuer itself, or by
+	// a responder certificate signed by the issuer.
+	Response, err := ocsp.ParseResponseForCert(Raw, Cert, Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("easytls ocsp error: Invalid response - %w", err)
+	}
+
+	// A delegated responder must also be authorized for OCSP signing.
+	if Delegate := Response.Certificate; Delegate != nil && !bytes.Equal(Delegate.Raw, Issuer.Raw) {
+		if !hasExtKeyUsage(Delegate, x509.ExtKeyUsageOCSPSigning) {
+			return nil, errors.New("easytls ocsp error: Responder certificate is not authorized for OCSP signing")
+		}
+		if Now.Before(Delegate.NotBefore) || Now.After(Delegate.NotAfter) {
+			return nil, errors.New("easytls ocsp error: Responder certificate is not valid at the current time")
+		}
+	}
+
+	if Response.Status == ocsp.Unknown {
+		return nil, errors.New("easytls ocsp error: Responder does not know the certificate")
+	}
+
+	if Response.ThisUpdate.After(Now.Add(ocspClockSkew)) {
+		return nil, fmt.Errorf("easytls ocsp error: Response is not valid until [ %s ]", Response.ThisUpdate)
+	}
+
+	if !Response.NextUpdate.IsZero() {
+		if Response.NextUpdate.Before(Response.ThisUpdate) {
+			return nil, errors.New("easytls ocsp error: Response expires before it becomes valid")
+		}
+		if Now.After(Response.NextUpdate) {
+			return nil, fmt.Errorf("easytls ocsp error: Response expired at [ %s ]", Response.NextUpdate)
+		}
+	}
+
+	return &ocspStatus{
+		Raw:        Raw,
+		Revoked:    Response.Status == ocsp.Revoked,
+		ThisUpdate: Response.ThisUpdate,
+		NextUpdate: Response.NextUpdate,
+	}, nil
+}
+
+func hasExtKeyUsage(Cert *x509.Certificate, Usage x509.ExtKeyUsage) bool {
+	for _, U := range Cert.ExtKeyUsage {
+		if U == Usage {
+			return true
+		}
+	}
+	return false
+}
+
+// OCSPResponder returns an http.Handler acting as an OCSP Responder for
+// certificates issued by the Certificate Authority. Certificates in Revoked
+// are reported as revoked, and all others as good. Responses are valid for
+// the given Lifetime, or one hour if not set. The Certificate Authority must
+// have an RSA or ECDSA key.
+//
+// This is intended for development and testing, for example served by an
+// httptest.Server and set as the OCSPResponder of a RevocationPolicy.
+func (CA *CertificateAuthority) OCSPResponder(Lifetime time.Duration, Revoked ...*x509.Certificate) http.Handler {
+
+	if Lifetime <= 0 {
+		Lifetime = time.Hour
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		w.Header().Set("Content-Type", "application/ocsp-response")
+
+		Body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxOCSPResponseSize))
+		if err != nil {
+			w.Write(ocsp.MalformedRequestErrorResponse)
+			return
+		}
+
+		Request, err := ocsp.ParseRequest(Body)
+		if err != nil {
+			w.Write(ocsp.MalformedRequestErrorResponse)
+			return
+		}
+
+		Now := time.Now().UTC().Truncate(time.Second)
+		Template := ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: Request.SerialNumber,
+			IssuerHash:   Request.HashAlgorithm,
+			ThisUpdate:   Now,
+			NextUpdate:   Now.Add(Lifetime),
+		}
+
+		for _, Cert := range Revoked {
+			if Cert.SerialNumber.Cmp(Request.SerialNumber) == 0 {
+				Template.Status = ocsp.Revoked
+				Template.RevokedAt = Now
+			}
+		}
+
+		Raw, err := ocsp.CreateResponse(CA.Certificate, CA.Certificate, Template, CA.Key)
+		if err != nil {
+			w.Write(ocsp.InternalErrorErrorResponse)
+			return
+		}
+
+		w.Write(Raw)
+	})
+}
@@ -0,0 +1,198 @@
+package easytls
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// ocspFixture is an issuer, and a certificate it issued to build OCSP
+// responses about.
+type ocspFixture struct {
+	CA   *CertificateAuthority
+	Leaf *x509.Certificate
+	Now  time.Time
+}
+
+func newOCSPFixture(T *testing.T) *ocspFixture {
+
+	CA, err := NewCertificateAuthority("EasyTLS OCSP Test CA", 0)
+	if err != nil {
+		T.Fatalf("Failed to create CA - %s", err)
+	}
+
+	Leaf, err := CA.IssueServerCertificate(CertificateOptions{CommonName: "server", DNSNames: []string{"localhost"}})
+	if err != nil {
+		T.Fatalf("Failed to issue certificate - %s", err)
+	}
+
+	return &ocspFixture{CA: CA, Leaf: Leaf.Certificate, Now: time.Now().UTC().Truncate(time.Second)}
+}
+
+// template returns a response reporting the certificate as good for an hour.
+func (F *ocspFixture) template() ocsp.Response {
+	return ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: F.Leaf.SerialNumber,
+		ThisUpdate:   F.Now,
+		NextUpdate:   F.Now.Add(time.Hour),
+	}
+}
+
+// sign returns the response signed by Signer. Signers other than the issuer
+// include their certificate, as a delegated responder does.
+func (F *ocspFixture) sign(T *testing.T, Signer *CertificateAuthority, Template ocsp.Response) []byte {
+	if Signer != F.CA {
+		Template.Certificate = Signer.Certificate
+	}
+	Raw, err := ocsp.CreateResponse(F.CA.Certificate, Signer.Certificate, Template, Signer.Key)
+	if err != nil {
+		T.Fatalf("Failed to sign OCSP response - %s", err)
+	}
+	return Raw
+}
+
+func (F *ocspFixture) parse(Raw []byte, Now time.Time) (*ocspStatus, error) {
+	return parseOCSPResponse(Raw, F.Leaf, F.CA.Certificate, Now)
+}
+
+func TestOCSPResponseValid(T *testing.T) {
+
+	F := newOCSPFixture(T)
+
+	Status, err := F.parse(F.sign(T, F.CA, F.template()), F.Now)
+	if err != nil {
+		T.Fatalf("Expected a valid response to be accepted - %s", err)
+	}
+	if Status.Revoked || !Status.NextUpdate.Equal(F.Now.Add(time.Hour)) {
+		T.Fatalf("Unexpected status %+v", Status)
+	}
+
+	// A delegated responder, authorized by the issuer, may sign instead.
+	Delegate, err := F.CA.Issue(CertificateOptions{CommonName: "responder", ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}})
+	if err != nil {
+		T.Fatalf("Failed to issue responder certificate - %s", err)
+	}
+	if _, err := F.parse(F.sign(T, &CertificateAuthority{*Delegate}, F.template()), F.Now); err != nil {
+		T.Fatalf("Expected a response from a delegated responder to be accepted - %s", err)
+	}
+
+	// Revoked certificates are reported, not rejected.
+	Template := F.template()
+	Template.Status, Template.RevokedAt = ocsp.Revoked, F.Now
+	if Status, err := F.parse(F.sign(T, F.CA, Template), F.Now); err != nil || !Status.Revoked {
+		T.Fatalf("Expected a revoked status, got %+v - %v", Status, err)
+	}
+}
+
+func TestOCSPResponseRejected(T *testing.T) {
+
+	F := newOCSPFixture(T)
+
+	Other, err := NewCertificateAuthority("EasyTLS Other CA", 0)
+	if err != nil {
+		T.Fatalf("Failed to create CA - %s", err)
+	}
+
+	Unauthorized, err := F.CA.Issue(CertificateOptions{CommonName: "responder", ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}})
+	if err != nil {
+		T.Fatalf("Failed to issue responder certificate - %s", err)
+	}
+
+	Expired, err := F.CA.Issue(CertificateOptions{CommonName: "responder", ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}, Lifetime: time.Minute})
+	if err != nil {
+		T.Fatalf("Failed to issue responder certificate - %s", err)
+	}
+
+	Foreign, err := Other.Issue(CertificateOptions{CommonName: "responder", ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}})
+	if err != nil {
+		T.Fatalf("Failed to issue responder certificate - %s", err)
+	}
+
+	With := func(Modify func(*ocsp.Response)) ocsp.Response {
+		Template := F.template()
+		Modify(&Template)
+		return Template
+	}
+
+	Valid := F.sign(T, F.CA, F.template())
+
+	// Flipping the last byte corrupts the signature, as nothing follows it
+	// in a response signed by the issuer.
+	Corrupt := append([]byte{}, Valid...)
+	Corrupt[len(Corrupt)-1] ^= 0xFF
+
+	Cases := []struct {
+		Name     string
+		Raw      []byte
+		Now      time.Time
+		Contains string
+	}{
+		{"garbage", []byte("not an OCSP response"), F.Now, ""},
+		{"empty", nil, F.Now, ""},
+		{"truncated", Valid[:len(Valid)/2], F.Now, ""},
+		{"trailing data", append(append([]byte{}, Valid...), 0), F.Now, "trailing data"},
+		{"unsuccessful", ocsp.InternalErrorErrorResponse, F.Now, "error from server"},
+		{"unsupported type", unsupportedOCSPResponse(T), F.Now, "bad OCSP response type"},
+		{"bad signature", Corrupt, F.Now, "bad OCSP signature"},
+		{"wrong signer", mustCreateResponse(T, F.CA.Certificate, Other, F.template()), F.Now, "bad OCSP signature"},
+		{"foreign responder", F.sign(T, &CertificateAuthority{*Foreign}, F.template()), F.Now, "bad OCSP signature"},
+		{"unauthorized responder", F.sign(T, &CertificateAuthority{*Unauthorized}, F.template()), F.Now, "not authorized for OCSP signing"},
+		{"expired responder", F.sign(T, &CertificateAuthority{*Expired}, F.template()), F.Now.Add(time.Minute * 30), "Responder certificate is not valid"},
+		{"other certificate", F.sign(T, F.CA, With(func(R *ocsp.Response) { R.SerialNumber = big.NewInt(1) })), F.Now, "no response matching"},
+		{"unknown", F.sign(T, F.CA, With(func(R *ocsp.Response) { R.Status = ocsp.Unknown })), F.Now, "does not know the certificate"},
+		{"from the future", F.sign(T, F.CA, With(func(R *ocsp.Response) {
+			R.ThisUpdate, R.NextUpdate = F.Now.Add(time.Hour), F.Now.Add(time.Hour*2)
+		})), F.Now, "not valid until"},
+		{"expired", Valid, F.Now.Add(time.Hour * 2), "expired"},
+		{"expires before valid", F.sign(T, F.CA, With(func(R *ocsp.Response) { R.NextUpdate = F.Now.Add(-time.Hour) })), F.Now, "expires before it becomes valid"},
+	}
+
+	for _, C := range Cases {
+		_, err := F.parse(C.Raw, C.Now)
+		if err == nil {
+			T.Fatalf("[%s] Expected the response to be rejected", C.Name)
+		}
+		if !strings.Contains(err.Error(), C.Contains) {
+			T.Fatalf("[%s] Expected an error containing [ %s ], got - %s", C.Name, C.Contains, err)
+		}
+	}
+
+	// A small difference between the clocks of the responder and ours is allowed.
+	Skewed := F.sign(T, F.CA, With(func(R *ocsp.Response) { R.ThisUpdate = F.Now.Add(time.Minute) }))
+	if _, err := F.parse(Skewed, F.Now); err != nil {
+		T.Fatalf("Expected a response within the allowed clock skew to be accepted - %s", err)
+	}
+}
+
+// mustCreateResponse signs a response about a certificate of Issuer with the
+// key of Signer, without including the certificate of Signer.
+func mustCreateResponse(T *testing.T, Issuer *x509.Certificate, Signer *CertificateAuthority, Template ocsp.Response) []byte {
+	Raw, err := ocsp.CreateResponse(Issuer, Signer.Certificate, Template, Signer.Key)
+	if err != nil {
+		T.Fatalf("Failed to sign OCSP response - %s", err)
+	}
+	return Raw
+}
+
+// unsupportedOCSPResponse returns a successful response of a type other than
+// the basic response.
+func unsupportedOCSPResponse(T *testing.T) []byte {
+	type responseBytes struct {
+		ResponseType asn1.ObjectIdentifier
+		Response     []byte
+	}
+	Raw, err := asn1.Marshal(struct {
+		Status        asn1.Enumerated
+		ResponseBytes responseBytes `asn1:"explicit,tag:0"`
+	}{ResponseBytes: responseBytes{ResponseType: asn1.ObjectIdentifier{1, 2, 3}}})
+	if err != nil {
+		T.Fatalf("Failed to marshal - %s", err)
+	}
+	return Raw
+}
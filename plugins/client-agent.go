@@ -31,13 +31,14 @@ func NewClientAgent(ModuleFolder string, Client *client.SimpleClient) (A *Client
 
 	// Create the new generic component of the agent.
 	OtherServerActive := false
-	A.Agent, err = NewAgent(ModuleFolder, A.client.Logger())
+	A.Agent, err = newAgent(ModuleFolder, A.client.StructuredLogger())
 	if err == ErrOtherServerActive {
 		OtherServerActive = true
 	} else if err != nil {
 		return nil, err
 	}
 	A.Agent.version = ClientFrameworkVersion
+	A.AddExpiryMonitor(A.client.EnableExpiryMonitoring())
 
 	// Load the modules from disk, putting this agent into a position where it could be started.
 	if err := A.loadModules(); err != nil {
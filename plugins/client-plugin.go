@@ -33,14 +33,14 @@ func (p *ClientPlugin) Reload() error {
 	var err error
 
 	if err = p.Stop(); err != nil {
-		p.agent.Logger().Printf("plugin reload error: Error stopping plugin [ %s ] for reload - %s", p.Name(), err)
+		p.agent.StructuredLogger().Error("plugin reload error: Error stopping plugin for reload", "module", p.Name(), "error", err)
 		return err
 	}
 
 	p.unloadSymbols()
 
 	if err = p.Load(); err != nil {
-		p.agent.Logger().Printf("plugin reload error: Error loading plugin [ %s ] for reload - %s", p.Name(), err)
+		p.agent.StructuredLogger().Error("plugin reload error: Error loading plugin for reload", "module", p.Name(), "error", err)
 		return err
 	}
 
@@ -56,28 +56,28 @@ func (p *ClientPlugin) Load() error {
 	switch p.state {
 	case stateNotLoaded:
 	case stateLoaded:
-		p.agent.Logger().Printf("Cannot Load() module [ %s ], symbols already loaded", p.Name())
+		p.agent.StructuredLogger().Warn("Cannot Load() module, symbols already loaded", "module", p.Name())
 		return nil
 	case stateActive:
-		p.agent.Logger().Printf("Cannot Load() module [ %s ], already running", p.Name())
+		p.agent.StructuredLogger().Warn("Cannot Load() module, already running", "module", p.Name())
 		return nil
 	}
 
 	// Load the default symbols.
 	if err := p.loadDefaultSymbols(); err != nil {
-		p.agent.Logger().Printf("plugin load error: Failed to load default symbols from file [ %s ]", p.filename)
+		p.agent.StructuredLogger().Error("plugin load error: Failed to load default symbols from file", "file", p.filename)
 		return err
 	}
 
 	// Load the type-specific symbols.
 	if err := p.loadClientSymbols(); err != nil {
-		p.agent.Logger().Printf("plugin load error: Failed to load client symbols from file [ %s ]", p.filename)
+		p.agent.StructuredLogger().Error("plugin load error: Failed to load client symbols from file", "file", p.filename)
 		return err
 	}
 
 	p.state = stateLoaded
 
-	p.agent.Logger().Printf("Loaded all client symbols for module [ %s ]", p.Name())
+	p.agent.StructuredLogger().Info("Loaded all client symbols for module", "module", p.Name())
 
 	return nil
 }
@@ -96,7 +96,7 @@ func (p *ClientPlugin) Start(Args ...interface{}) error {
 		}
 	case stateLoaded:
 	case stateActive:
-		p.agent.Logger().Printf("Cannot Start() module [ %s ], already running", p.Name())
+		p.agent.StructuredLogger().Warn("Cannot Start() module, already running", "module", p.Name())
 		return nil
 	}
 
@@ -121,7 +121,8 @@ func (p *ClientPlugin) Start(Args ...interface{}) error {
 		return fmt.Errorf("plugin error: No Init() function loaded for module [ %s ]", p.Name())
 	}
 
-	p.agent.Logger().Printf("Started module [ %s ]", p.Name())
+	p.agent.StructuredLogger().Info("Started module", "module", p.Name())
+	p.agent.moduleStarted(p)
 
 	return nil
 }
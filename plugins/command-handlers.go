@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Bearnie-H/easy-tls/server"
 	"github.com/gorilla/mux"
@@ -20,6 +22,7 @@ const (
 	URLStateHandler   string = "/state/{Name}"
 	URLListHandler    string = "/list"
 	URLActiveHandler  string = "/active"
+	URLExpiryHandler  string = "/expiring"
 	URLHelpHandler    string = "/"
 )
 
@@ -37,6 +40,7 @@ var availableOptions = []string{
 	"stop <Name>",
 	"list",
 	"active",
+	"expiring <Days>",
 	"help",
 }
 
@@ -53,6 +57,7 @@ func formatCommandHandlers(Agent *Agent) []server.SimpleHandler {
 	h = append(h, server.NewSimpleHandler(stateHandler(Agent), URLStateHandler, http.MethodGet, http.MethodPost))
 	h = append(h, server.NewSimpleHandler(loadedHandler(Agent), URLListHandler, http.MethodGet, http.MethodPost))
 	h = append(h, server.NewSimpleHandler(activeHandler(Agent), URLActiveHandler, http.MethodGet, http.MethodPost))
+	h = append(h, server.NewSimpleHandler(expiryHandler(Agent), URLExpiryHandler, http.MethodGet, http.MethodPost))
 	h = append(h, server.NewSimpleHandler(helpHandler(Agent), URLHelpHandler, http.MethodGet, http.MethodPost))
 
 	return h
@@ -90,7 +95,7 @@ func startHandler(Agent *Agent) http.Handler {
 		M, err := Agent.GetByName(Name)
 		if err != nil {
 			s := exitHandler(w, http.StatusNotFound, "No module matching name [ %s ] could be found", err, Name)
-			Agent.Logger().Println(s.String())
+			s.writeTo(Agent.StructuredLogger())
 			return
 		}
 
@@ -99,12 +104,12 @@ func startHandler(Agent *Agent) http.Handler {
 			ExtraArgs = append(ExtraArgs, arg)
 		}
 
-		if err := M.Start(ExtraArgs...); err != nil {
+		if err := traceModule(r.Context(), "start", M, func() error { return M.Start(ExtraArgs...) }); err != nil {
 			s := exitHandler(w, http.StatusInternalServerError, "Failed to start module [ %s ]", err, M.Name())
-			Agent.Logger().Println(s.String())
+			s.writeTo(Agent.StructuredLogger())
 		} else {
 			s := exitHandler(w, http.StatusOK, "Successfully started module [ %s ]", nil, M.Name())
-			Agent.Logger().Println(s.String())
+			s.writeTo(Agent.StructuredLogger())
 		}
 	})
 }
@@ -118,13 +123,13 @@ func restartHandler(Agent *Agent) http.Handler {
 		M, err := Agent.GetByName(Name)
 		if err != nil {
 			s := exitHandler(w, http.StatusNotFound, "No module matching name [ %s ] could be found", err, Name)
-			Agent.Logger().Println(s.String())
+			s.writeTo(Agent.StructuredLogger())
 			return
 		}
 
-		if err := M.Stop(); err != nil {
+		if err := traceModule(r.Context(), "stop", M, M.Stop); err != nil {
 			s := exitHandler(w, http.StatusInternalServerError, "Failed to stop module [ %s ]", err, M.Name())
-			Agent.Logger().Println(s.String())
+			s.writeTo(Agent.StructuredLogger())
 			return
 		}
 
@@ -133,14 +138,14 @@ func restartHandler(Agent *Agent) http.Handler {
 			ExtraArgs = append(ExtraArgs, arg)
 		}
 
-		if err := M.Start(ExtraArgs...); err != nil {
+		if err := traceModule(r.Context(), "start", M, func() error { return M.Start(ExtraArgs...) }); err != nil {
 			s := exitHandler(w, http.StatusInternalServerError, "Failed to start module [ %s ]", err, M.Name())
-			Agent.Logger().Println(s.String())
+			s.writeTo(Agent.StructuredLogger())
 			return
 		}
 
 		s := exitHandler(w, http.StatusOK, "Successfully restarted module [ %s ]", nil, M.Name())
-		Agent.Logger().Println(s.String())
+		s.writeTo(Agent.StructuredLogger())
 	})
 }
 
@@ -153,13 +158,13 @@ func reloadHandler(Agent *Agent) http.Handler {
 		M, err := Agent.GetByName(Name)
 		if err != nil {
 			s := exitHandler(w, http.StatusNotFound, "No module matching name [ %s ] could be found", err, Name)
-			Agent.Logger().Println(s.String())
+			s.writeTo(Agent.StructuredLogger())
 			return
 		}
 
-		if err := M.Reload(); err != nil {
+		if err := traceModule(r.Context(), "reload", M, M.Reload); err != nil {
 			s := exitHandler(w, http.StatusInternalServerError, "Failed to reload module [ %s ]", err, M.Name())
-			Agent.Logger().Println(s.String())
+			s.writeTo(Agent.StructuredLogger())
 			return
 		}
 
@@ -168,14 +173,14 @@ func reloadHandler(Agent *Agent) http.Handler {
 			ExtraArgs = append(ExtraArgs, arg)
 		}
 
-		if err := M.Start(ExtraArgs...); err != nil {
+		if err := traceModule(r.Context(), "start", M, func() error { return M.Start(ExtraArgs...) }); err != nil {
 			s := exitHandler(w, http.StatusInternalServerError, "Failed to start module [ %s ]", err, M.Name())
-			Agent.Logger().Println(s.String())
+			s.writeTo(Agent.StructuredLogger())
 			return
 		}
 
 		s := exitHandler(w, http.StatusOK, "Successfully reloaded module [ %s ]", nil, M.Name())
-		Agent.Logger().Println(s.String())
+		s.writeTo(Agent.StructuredLogger())
 	})
 }
 
@@ -188,19 +193,19 @@ func versionHandler(Agent *Agent) http.Handler {
 		M, err := Agent.GetByName(Name)
 		if err != nil {
 			s := exitHandler(w, http.StatusNotFound, "No module matching name [ %s ] could be found", err, Name)
-			Agent.Logger().Println(s.String())
+			s.writeTo(Agent.StructuredLogger())
 			return
 		}
 
 		V, err := M.GetVersion()
 		if err != nil {
 			s := exitHandler(w, http.StatusInternalServerError, "Failed to retrieve version for module [ %s ]", err, M.Name())
-			Agent.Logger().Println(s.String())
+			s.writeTo(Agent.StructuredLogger())
 			return
 		}
 
 		s := exitHandler(w, http.StatusOK, "Successfully retrieved version for module [ %s ] - %s", nil, M.Name(), V.String())
-		Agent.Logger().Println(s.String())
+		s.writeTo(Agent.StructuredLogger())
 	})
 }
 
@@ -212,18 +217,18 @@ func stopHandler(Agent *Agent) http.Handler {
 		M, err := Agent.GetByName(Name)
 		if err != nil {
 			s := exitHandler(w, http.StatusNotFound, "No module matching name [ %s ] could be found", err, Name)
-			Agent.Logger().Println(s.String())
+			s.writeTo(Agent.StructuredLogger())
 			return
 		}
 
-		if err := M.Stop(); err != nil {
+		if err := traceModule(r.Context(), "stop", M, M.Stop); err != nil {
 			s := exitHandler(w, http.StatusInternalServerError, "Failed to stop module [ %s ]", err, M.Name())
-			Agent.Logger().Println(s.String())
+			s.writeTo(Agent.StructuredLogger())
 			return
 		}
 
 		s := exitHandler(w, http.StatusOK, "Successfully stopped module [ %s ]", nil, M.Name())
-		Agent.Logger().Println(s.String())
+		s.writeTo(Agent.StructuredLogger())
 	})
 }
 
@@ -243,11 +248,11 @@ func activeHandler(Agent *Agent) http.Handler {
 			b.WriteString("No active modules found")
 		}
 
-		exitHandler(w, http.StatusOK, b.String(), nil)
+		exitHandler(w, http.StatusOK, "%s", nil, b.String())
 
 		for _, S := range strings.Split(b.String(), "\n") {
 			if S != "" {
-				Agent.Logger().Println(S)
+				Agent.StructuredLogger().Info(S)
 			}
 		}
 	})
@@ -261,13 +266,13 @@ func stateHandler(Agent *Agent) http.Handler {
 		M, err := Agent.GetByName(Name)
 		if err != nil {
 			s := exitHandler(w, http.StatusNotFound, "No module matching name [ %s ] could be found", err, Name)
-			Agent.Logger().Println(s.String())
+			s.writeTo(Agent.StructuredLogger())
 			return
 		}
 
 		State := M.State()
 		s := exitHandler(w, http.StatusOK, "Module [ %s ] is [ %s ] (up for %s)", nil, M.Name(), State.String(), M.Uptime())
-		Agent.Logger().Println(s.String())
+		s.writeTo(Agent.StructuredLogger())
 	})
 }
 
@@ -287,11 +292,51 @@ func loadedHandler(Agent *Agent) http.Handler {
 			b.WriteString("No modules found")
 		}
 
-		exitHandler(w, http.StatusOK, b.String(), nil)
+		exitHandler(w, http.StatusOK, "%s", nil, b.String())
 
 		for _, S := range strings.Split(b.String(), "\n") {
 			if S != "" {
-				Agent.Logger().Println(S)
+				Agent.StructuredLogger().Info(S)
+			}
+		}
+	})
+}
+
+// ExpiryHandler will list the certificates known to the agent which expire
+// within the given number of days, defaulting to 30.
+func expiryHandler(Agent *Agent) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		Days := 30
+		if Args := r.Header[HeaderArgumentKey]; len(Args) > 0 {
+			D, err := strconv.Atoi(Args[0])
+			if err != nil || D < 0 {
+				s := exitHandler(w, http.StatusBadRequest, "Invalid number of days [ %s ]", err, Args[0])
+				s.writeTo(Agent.StructuredLogger())
+				return
+			}
+			Days = D
+		}
+
+		b := bytes.NewBuffer(nil)
+
+		for _, C := range Agent.ExpiringCertificates(time.Duration(Days) * time.Hour * 24) {
+			if C.Expired {
+				b.WriteString(fmt.Sprintf("Certificate [ %s ] from [ %s ] EXPIRED at %s\n", C.CommonName, C.Source, C.NotAfter))
+			} else {
+				b.WriteString(fmt.Sprintf("Certificate [ %s ] from [ %s ] expires in %d days, at %s\n", C.CommonName, C.Source, C.DaysRemaining, C.NotAfter))
+			}
+		}
+
+		if b.Len() == 0 {
+			b.WriteString(fmt.Sprintf("No certificates expire within %d days", Days))
+		}
+
+		exitHandler(w, http.StatusOK, "%s", nil, b.String())
+
+		for _, S := range strings.Split(b.String(), "\n") {
+			if S != "" {
+				Agent.StructuredLogger().Info(S)
 			}
 		}
 	})
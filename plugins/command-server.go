@@ -5,13 +5,12 @@ import (
 	"crypto/sha1"
 	"encoding/hex"
 	"errors"
-	"io/ioutil"
-	"log"
 	"net"
 	"net/http"
 	"os"
 	"path"
 
+	easytls "github.com/Bearnie-H/easy-tls"
 	"github.com/Bearnie-H/easy-tls/client"
 	"github.com/Bearnie-H/easy-tls/server"
 )
@@ -23,43 +22,48 @@ var (
 )
 
 // newCommandServer will return a new, fully configured Command server to the plugin agent.
+// Along with the command handlers, this serves the "/about" and health check
+// handlers of every SimpleServer, with a check for each module the agent runs.
 func newCommandServer(Agent *Agent) (*server.SimpleServer, error) {
 
 	Agent.commandServerSock = formatSocketName(Agent.moduleFolder)
 
-	// Check if there is already a socket open with this name
-	_, err := os.Stat(Agent.commandServerSock)
-	if err == nil {
-
-		// Check if someone is listening on the other end.
-		if Agent.commandServerActive() {
-			Agent.Logger().Printf("Plugin Agent socket already active.")
-			return nil, ErrOtherServerActive
-		}
-		if err := os.Remove(Agent.commandServerSock); err != nil {
-			return nil, err
-		}
+	// Check if there is already an agent listening on a socket with this name.
+	// Any stale socket left behind is replaced by the listener of the server.
+	if _, err := os.Lstat(Agent.commandServerSock); err == nil && Agent.commandServerActive() {
+		Agent.StructuredLogger().Info("Plugin Agent socket already active")
+		return nil, ErrOtherServerActive
 	}
 
-	// Spawn a new listener to listen on the unix domain socket
-	L, err := newCommandListener(Agent.commandServerSock)
-	if err != nil {
+	// Create the server, serving only on the unix domain socket
+	Agent.StructuredLogger().Info("Creating plugin command server", "addr", Agent.commandServerSock)
+	S := server.NewServerHTTP("")
+	S.SetStructuredLogger(Agent.StructuredLogger())
+	if err := S.AddListener(server.ListenerConfig{Network: "unix", Addr: Agent.commandServerSock}); err != nil {
 		return nil, err
 	}
 
-	// Create the server
-	Agent.Logger().Printf("Creating plugin command server at [ %s ]", L.Addr().String())
-	S := server.NewServerHTTP(L.Addr().String())
+	// Trace each command, as the parent of the spans of the module operations.
+	S.AddMiddlewares(server.MiddlewareTracing(nil))
 
 	// Add in the dedicated handlers to perform actions on the plugins loaded by the agent
 	S.AddHandlers(S.Router(), formatCommandHandlers(Agent)...)
+	Agent.RegisterHealthChecks(S.Health())
+
+	// Open the socket now, so any failure is returned rather than leaving the
+	// agent without a command server.
+	if err := S.Listen(); err != nil {
+		return nil, err
+	}
 
-	Agent.Logger().Printf("Serving command server at [ %s ]", S.Addr())
+	Agent.StructuredLogger().Info("Serving command server", "addr", Agent.commandServerSock)
 
 	// Serve traffic on the listener
-	go func(S *server.SimpleServer, L *net.UnixListener) {
-		S.Serve(L)
-	}(S, L)
+	go func(S *server.SimpleServer) {
+		if err := S.ListenAndServe(); err != nil {
+			Agent.StructuredLogger().Error("Failed to serve command server", "addr", Agent.commandServerSock, "error", err)
+		}
+	}(S)
 
 	return S, nil
 }
@@ -77,13 +81,13 @@ func (A *Agent) commandServerActive() bool {
 	})
 
 	// Don't log any of these intermediate steps
-	l := A.Logger()
+	l := A.StructuredLogger()
 
-	discardLogger := log.New(ioutil.Discard, "", 0)
-	C.SetLogger(discardLogger)
-	A.SetLogger(discardLogger)
+	discardLogger := easytls.NewDiscardLogger()
+	C.SetStructuredLogger(discardLogger)
+	A.SetStructuredLogger(discardLogger)
 
-	defer A.SetLogger(l)
+	defer A.SetStructuredLogger(l)
 
 	// Create the simplest request to send and try to get a response
 	c := command{action: "help", name: ""}
@@ -95,19 +99,6 @@ func (A *Agent) commandServerActive() bool {
 	return true
 }
 
-func newCommandListener(SocketName string) (*net.UnixListener, error) {
-
-	l, err := net.ListenUnix(
-		"unix",
-		&net.UnixAddr{
-			Name: SocketName,
-			Net:  "unix",
-		},
-	)
-
-	return l, err
-}
-
 // formatSocketName will generate a unique valid name for a Unix socket to listen
 // on and serve the command server.
 func formatSocketName(Seed string) string {
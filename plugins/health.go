@@ -0,0 +1,76 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Bearnie-H/easy-tls/server"
+)
+
+// healthCheckName returns the name of the health check registered for a module.
+func healthCheckName(Name string) string {
+	return "plugin:" + Name
+}
+
+// moduleHealthCheck returns a health check which passes while the module is running.
+func moduleHealthCheck(M Module) server.HealthCheck {
+	Name := M.Name()
+	return server.HealthCheck{
+		Name: healthCheckName(Name),
+		Check: func(context.Context) error {
+			if State := M.State(); State != stateActive {
+				return fmt.Errorf("plugin error: Module [ %s ] is [ %s ]", Name, State)
+			}
+			return nil
+		},
+	}
+}
+
+// RegisterHealthChecks will register a health check with the HealthChecker
+// for every module the Agent starts, which fails if the module stops running
+// unexpectedly. The check is removed when the module is explicitly stopped.
+// Modules which are already running are registered immediately.
+func (A *Agent) RegisterHealthChecks(H *server.HealthChecker) {
+
+	if H == nil {
+		return
+	}
+
+	A.mu.Lock()
+	A.health = append(A.health, H)
+	Modules := make([]Module, 0, len(A.loadedModules))
+	for _, M := range A.loadedModules {
+		Modules = append(Modules, M)
+	}
+	A.mu.Unlock()
+
+	for _, M := range Modules {
+		if M.State() == stateActive {
+			H.AddCheck(moduleHealthCheck(M))
+		}
+	}
+}
+
+// moduleStarted registers the health check of a newly started module.
+func (A *Agent) moduleStarted(M Module) {
+
+	A.mu.Lock()
+	Checkers := append([]*server.HealthChecker{}, A.health...)
+	A.mu.Unlock()
+
+	for _, H := range Checkers {
+		H.AddCheck(moduleHealthCheck(M))
+	}
+}
+
+// moduleStopped removes the health check of an explicitly stopped module.
+func (A *Agent) moduleStopped(Name string) {
+
+	A.mu.Lock()
+	Checkers := append([]*server.HealthChecker{}, A.health...)
+	A.mu.Unlock()
+
+	for _, H := range Checkers {
+		H.RemoveCheck(healthCheckName(Name))
+	}
+}
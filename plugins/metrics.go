@@ -0,0 +1,55 @@
+package plugins
+
+import (
+	"github.com/Bearnie-H/easy-tls/metrics"
+)
+
+// The names of the metrics recorded for the modules of an Agent.
+const (
+	MetricModuleState  = "easytls_plugin_state"
+	MetricModuleUptime = "easytls_plugin_uptime_seconds"
+)
+
+// metricStates are the label values used for each PluginState.
+var metricStates = []struct {
+	state PluginState
+	label string
+}{
+	{stateNotLoaded, "not_loaded"},
+	{stateLoaded, "stopped"},
+	{stateActive, "running"},
+}
+
+// RegisterMetrics will expose the state and uptime of every module known to
+// the Agent in the given Registry. These are read from the modules whenever
+// the Registry is written out. The metrics.Default Registry is used if none
+// is given.
+func (A *Agent) RegisterMetrics(Registry *metrics.Registry) {
+
+	if Registry == nil {
+		Registry = metrics.Default
+	}
+
+	Registry.GaugeFunc(MetricModuleState, "State of each module, with a value of 1 for the current state.", []string{"module", "state"}, func() []metrics.Sample {
+		Samples := []metrics.Sample{}
+		for _, M := range A.Modules() {
+			State := M.State()
+			for _, S := range metricStates {
+				Value := 0.0
+				if S.state == State {
+					Value = 1
+				}
+				Samples = append(Samples, metrics.Sample{LabelValues: []string{M.Name(), S.label}, Value: Value})
+			}
+		}
+		return Samples
+	})
+
+	Registry.GaugeFunc(MetricModuleUptime, "Time each module has been running for, or 0 if it is not running.", []string{"module"}, func() []metrics.Sample {
+		Samples := []metrics.Sample{}
+		for _, M := range A.Modules() {
+			Samples = append(Samples, metrics.Sample{LabelValues: []string{M.Name()}, Value: M.Uptime().Seconds()})
+		}
+		return Samples
+	})
+}
@@ -33,7 +33,7 @@ func (c *command) url(Agent *Agent) *url.URL {
 
 func (c *command) do(C *client.SimpleClient, A *Agent) error {
 
-	A.Logger().Printf("Submitting command [ %+v ]", *c)
+	A.StructuredLogger().Debug("Submitting command", "command", *c)
 
 	H := http.Header{}
 
@@ -43,19 +43,19 @@ func (c *command) do(C *client.SimpleClient, A *Agent) error {
 
 	resp, err := C.Get(c.url(A).String(), H)
 	if err != nil {
-		A.Logger().Printf("plugin command error: Error occurred while submitting command [ %+v ] - %s", *c, err)
+		A.StructuredLogger().Error("plugin command error: Error occurred while submitting command", "command", *c, "error", err)
 		return err
 	}
 
 	Contents, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		A.Logger().Printf("plugin command error: Error occurred while reading response body - %s", err)
+		A.StructuredLogger().Error("plugin command error: Error occurred while reading response body", "error", err)
 		return err
 	}
 
 	for _, s := range strings.Split(string(Contents), "\n") {
 		if s != "" {
-			A.Logger().Print(s)
+			A.StructuredLogger().Info(s)
 		}
 	}
 	resp.Body.Close()
@@ -80,10 +80,10 @@ func (c *command) do(C *client.SimpleClient, A *Agent) error {
 // a sub-prefix is given, the shortest name will match first.
 func (A *Agent) SendCommands(Args ...string) {
 
-	A.Logger().Println("Existing plugin agent active on socket, attempting to send commands.")
+	A.StructuredLogger().Info("Existing plugin agent active on socket, attempting to send commands")
 
 	if len(Args) == 0 {
-		A.Logger().Println("No arguments provided, exiting.")
+		A.StructuredLogger().Info("No arguments provided, exiting")
 		return
 	}
 
@@ -98,7 +98,7 @@ func (A *Agent) SendCommands(Args ...string) {
 	})
 
 	// Share logging.
-	C.SetLogger(A.Logger())
+	C.SetStructuredLogger(A.StructuredLogger())
 
 	A.sendCommands(C, Args...)
 }
@@ -113,7 +113,7 @@ func (A *Agent) sendCommands(C *client.SimpleClient, args ...string) {
 			arguments: args[2:],
 		}
 		if err := Command.do(C, A); err != nil {
-			A.Logger().Printf("Error submitting [ %s ] command - %s", Command.action, err)
+			A.StructuredLogger().Error("Error submitting command", "command", Command.action, "error", err)
 		}
 	case "restart":
 		Command := command{
@@ -122,7 +122,7 @@ func (A *Agent) sendCommands(C *client.SimpleClient, args ...string) {
 			arguments: args[2:],
 		}
 		if err := Command.do(C, A); err != nil {
-			A.Logger().Printf("Error submitting [ %s ] command - %s", Command.action, err)
+			A.StructuredLogger().Error("Error submitting command", "command", Command.action, "error", err)
 		}
 	case "reload":
 		Command := command{
@@ -131,7 +131,7 @@ func (A *Agent) sendCommands(C *client.SimpleClient, args ...string) {
 			arguments: args[2:],
 		}
 		if err := Command.do(C, A); err != nil {
-			A.Logger().Printf("Error submitting [ %s ] command - %s", Command.action, err)
+			A.StructuredLogger().Error("Error submitting command", "command", Command.action, "error", err)
 		}
 	case "version":
 		Command := command{
@@ -140,7 +140,7 @@ func (A *Agent) sendCommands(C *client.SimpleClient, args ...string) {
 		for _, name := range args[1:] {
 			Command.name = name
 			if err := Command.do(C, A); err != nil {
-				A.Logger().Printf("Error submitting [ %s ] command - %s", Command.action, err)
+				A.StructuredLogger().Error("Error submitting command", "command", Command.action, "error", err)
 			}
 		}
 	case "state":
@@ -150,7 +150,7 @@ func (A *Agent) sendCommands(C *client.SimpleClient, args ...string) {
 		for _, name := range args[1:] {
 			Command.name = name
 			if err := Command.do(C, A); err != nil {
-				A.Logger().Printf("Error submitting [ %s ] command - %s", Command.action, err)
+				A.StructuredLogger().Error("Error submitting command", "command", Command.action, "error", err)
 			}
 		}
 	case "stop":
@@ -160,7 +160,7 @@ func (A *Agent) sendCommands(C *client.SimpleClient, args ...string) {
 		for _, name := range args[1:] {
 			Command.name = name
 			if err := Command.do(C, A); err != nil {
-				A.Logger().Printf("Error submitting [ %s ] command - %s", Command.action, err)
+				A.StructuredLogger().Error("Error submitting command", "command", Command.action, "error", err)
 			}
 		}
 	case "list":
@@ -168,21 +168,29 @@ func (A *Agent) sendCommands(C *client.SimpleClient, args ...string) {
 			action: args[0],
 		}
 		if err := Command.do(C, A); err != nil {
-			A.Logger().Printf("Error submitting [ %s ] command - %s", Command.action, err)
+			A.StructuredLogger().Error("Error submitting command", "command", Command.action, "error", err)
 		}
 	case "active":
 		Command := command{
 			action: args[0],
 		}
 		if err := Command.do(C, A); err != nil {
-			A.Logger().Printf("Error submitting [ %s ] command - %s", Command.action, err)
+			A.StructuredLogger().Error("Error submitting command", "command", Command.action, "error", err)
+		}
+	case "expiring":
+		Command := command{
+			action:    args[0],
+			arguments: args[1:],
+		}
+		if err := Command.do(C, A); err != nil {
+			A.StructuredLogger().Error("Error submitting command", "command", Command.action, "error", err)
 		}
 	default:
 		Command := command{
 			action: "help",
 		}
 		if err := Command.do(C, A); err != nil {
-			A.Logger().Printf("Error submitting [ %s ] command - %s", Command.action, err)
+			A.StructuredLogger().Error("Error submitting command", "command", Command.action, "error", err)
 		}
 	}
 }
@@ -1,6 +1,7 @@
 package plugins
 
 import (
+	"context"
 	"errors"
 	"log"
 	"path"
@@ -8,6 +9,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	easytls "github.com/Bearnie-H/easy-tls"
 	"github.com/Bearnie-H/easy-tls/server"
@@ -29,7 +31,7 @@ type Agent struct {
 
 	// The logger to write Status messages from the plugins to, as well
 	// as agent-level messages and errors.
-	logger *log.Logger
+	logger easytls.Logger
 
 	// Protects loadedModules, the set of currently loaded but not
 	// necessarily active modules.
@@ -45,16 +47,29 @@ type Agent struct {
 	commandServer     *server.SimpleServer
 	commandServerSock string
 
-	done chan struct{}
+	// The monitors tracking the expiry of the certificates used by the agent.
+	expiry []*easytls.ExpiryMonitor
+
+	// The health checkers to register a check with for each active module.
+	health []*server.HealthChecker
+
+	done   chan struct{}
+	closed bool
 }
 
 // NewAgent will create and return a new generic plugin agent.
 func NewAgent(ModuleFolder string, logger *log.Logger) (*Agent, error) {
+	return newAgent(ModuleFolder, easytls.AsLogger(logger))
+}
+
+// newAgent will create and return a new generic plugin agent, writing to the
+// given leveled, structured logger.
+func newAgent(ModuleFolder string, logger easytls.Logger) (*Agent, error) {
 
 	var err error
 
 	if logger == nil {
-		logger = easytls.NewDefaultLogger()
+		logger = easytls.NewDefaultStructuredLogger()
 	}
 
 	A := &Agent{
@@ -108,10 +123,21 @@ func (A *Agent) loadModules() error {
 }
 
 // Logger returns the logger used by an Agent
-func (A *Agent) Logger() *log.Logger { return A.logger }
+func (A *Agent) Logger() *log.Logger { return easytls.AsLogLogger(A.logger) }
 
 // SetLogger will set the internal logger of the Agent to l
-func (A *Agent) SetLogger(l *log.Logger) { A.logger = l }
+func (A *Agent) SetLogger(l *log.Logger) { A.logger = easytls.AsLogger(l) }
+
+// StructuredLogger returns the leveled, structured logger used by an Agent
+func (A *Agent) StructuredLogger() easytls.Logger { return A.logger }
+
+// SetStructuredLogger will set the internal leveled, structured logger of the Agent to l
+func (A *Agent) SetStructuredLogger(l easytls.Logger) {
+	if l == nil {
+		l = easytls.NewDefaultStructuredLogger()
+	}
+	A.logger = l
+}
 
 // NewGenericModule will return a new GenericPlugin, which satisfies most, but not all
 // of the Module interface.
@@ -146,8 +172,8 @@ func (A *Agent) StartAll() error {
 		wg.Add(1)
 		go func(M Module, wg *sync.WaitGroup) {
 			defer wg.Done()
-			if err := M.Start(); err != nil {
-				A.Logger().Printf("plugin agent error: Error occurred while starting module [ %s ] - %s", M.Name(), err)
+			if err := traceModule(context.Background(), "start", M, func() error { return M.Start() }); err != nil {
+				A.StructuredLogger().Error("plugin agent error: Error occurred while starting module", "module", M.Name(), "error", err)
 			}
 		}(M, wg)
 	}
@@ -191,8 +217,8 @@ func (A *Agent) StopAll() error {
 		wg.Add(1)
 		go func(M Module, wg *sync.WaitGroup) {
 			defer wg.Done()
-			if err := M.Stop(); err != nil {
-				A.Logger().Printf("plugin agent error: Error occurred while stopping module [ %s ] - %s", M.Name(), err)
+			if err := traceModule(context.Background(), "stop", M, M.Stop); err != nil {
+				A.StructuredLogger().Error("plugin agent error: Error occurred while stopping module", "module", M.Name(), "error", err)
 			}
 		}(M, wg)
 	}
@@ -202,16 +228,17 @@ func (A *Agent) StopAll() error {
 	return nil
 }
 
-// Close will close down an agent, stopping all plugins and releasing all resources
+// Close will close down an agent, stopping all plugins and releasing all resources.
+// Calling Close on an agent which is already closed does nothing.
 func (A *Agent) Close() error {
 
-	select {
-	case _, ok := <-A.Done():
-		if !ok {
-			return nil
-		}
-	default:
+	A.mu.Lock()
+	if A.closed {
+		A.mu.Unlock()
+		return nil
 	}
+	A.closed = true
+	A.mu.Unlock()
 
 	defer func() {
 		A.Done() <- struct{}{}
@@ -220,14 +247,14 @@ func (A *Agent) Close() error {
 
 	var err error
 
-	A.Logger().Printf("Stopping all modules...")
+	A.StructuredLogger().Info("Stopping all modules")
 	if err = A.StopAll(); err != nil {
-		A.Logger().Printf("plugin agent error: Error(s) occurred while stopping - %s", err)
+		A.StructuredLogger().Error("plugin agent error: Error(s) occurred while stopping", "error", err)
 	}
 
-	A.Logger().Printf("Shutting down command server...")
+	A.StructuredLogger().Info("Shutting down command server")
 	if err = A.commandServer.Shutdown(); err != nil {
-		A.Logger().Printf("plugin agent error: Error occurred while shutting down command server - %s", err)
+		A.StructuredLogger().Error("plugin agent error: Error occurred while shutting down command server", "error", err)
 	}
 
 	return err
@@ -267,12 +294,47 @@ func (A *Agent) registerModule(M Module) error {
 		A.loadedModules = make(map[string]Module)
 	}
 
-	A.Logger().Printf("Adding module [ %s ]", M.Name())
+	A.StructuredLogger().Info("Adding module", "module", M.Name())
 	A.loadedModules[M.Name()] = M
 
 	return nil
 }
 
+// AddExpiryMonitor will add the given monitors to the set consulted when
+// reporting which certificates expire soon.
+func (A *Agent) AddExpiryMonitor(Monitors ...*easytls.ExpiryMonitor) {
+
+	A.mu.Lock()
+	defer A.mu.Unlock()
+
+	for _, M := range Monitors {
+		if M != nil {
+			A.expiry = append(A.expiry, M)
+		}
+	}
+}
+
+// ExpiringCertificates will return the status of all certificates known to
+// the agent which expire within the given duration, ordered by the soonest
+// to expire. A negative duration returns all certificates.
+func (A *Agent) ExpiringCertificates(Within time.Duration) []easytls.CertificateStatus {
+
+	A.mu.Lock()
+	Monitors := append([]*easytls.ExpiryMonitor{}, A.expiry...)
+	A.mu.Unlock()
+
+	Statuses := []easytls.CertificateStatus{}
+	for _, M := range Monitors {
+		Statuses = append(Statuses, M.ExpiringWithin(Within)...)
+	}
+
+	sort.SliceStable(Statuses, func(i, j int) bool {
+		return Statuses[i].NotAfter.Before(Statuses[j].NotAfter)
+	})
+
+	return Statuses
+}
+
 // Modules will return the set of known modules as an array in name
 // sorted order, regardless of the underlying formatting.
 func (A *Agent) Modules() []Module {
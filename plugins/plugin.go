@@ -3,13 +3,14 @@ package plugins
 import (
 	"errors"
 	"fmt"
-	"log"
 	"path"
 	"plugin"
 	"reflect"
 	"strings"
 	"sync"
 	"time"
+
+	easytls "github.com/Bearnie-H/easy-tls"
 )
 
 // Module is the interface which must be satisfied by any plugins intended
@@ -97,7 +98,7 @@ type GenericPlugin struct {
 	done chan struct{}
 
 	// The logger to write messages to from the module.
-	logger *log.Logger
+	logger easytls.Logger
 
 	// Protects state
 	mu    *sync.Mutex
@@ -151,27 +152,27 @@ func (p *GenericPlugin) Load() error {
 	switch p.state {
 	case stateNotLoaded:
 	case stateLoaded:
-		p.agent.Logger().Printf("Cannot Load() module [ %s ], symbols already loaded", p.Name())
+		p.agent.StructuredLogger().Warn("Cannot Load() module, symbols already loaded", "module", p.Name())
 		return nil
 	case stateActive:
-		p.agent.Logger().Printf("Cannot Load() module [ %s ], already running", p.Name())
+		p.agent.StructuredLogger().Warn("Cannot Load() module, already running", "module", p.Name())
 		return nil
 	}
 
 	// Load the default symbols.
 	if err := p.loadDefaultSymbols(); err != nil {
-		p.agent.Logger().Printf("plugin load error: Failed to load default symbols from file [ %s ]", p.filename)
+		p.agent.StructuredLogger().Error("plugin load error: Failed to load default symbols from file", "file", p.filename)
 		return err
 	}
 
 	if p.init == nil {
-		p.agent.Logger().Printf("Loaded all symbols except Init() for module [ %s ]", p.Name())
+		p.agent.StructuredLogger().Info("Loaded all symbols except Init() for module", "module", p.Name())
 		return nil
 	}
 
 	p.state = stateLoaded
 
-	p.agent.Logger().Printf("Loaded all symbols for module [ %s ]", p.Name())
+	p.agent.StructuredLogger().Info("Loaded all symbols for module", "module", p.Name())
 
 	return nil
 }
@@ -191,7 +192,7 @@ func (p *GenericPlugin) Start(Args ...interface{}) error {
 		}
 	case stateLoaded:
 	case stateActive:
-		p.agent.Logger().Printf("Cannot Start() module [ %s ], already running", p.Name())
+		p.agent.StructuredLogger().Warn("Cannot Start() module, already running", "module", p.Name())
 		return nil
 	}
 
@@ -216,7 +217,8 @@ func (p *GenericPlugin) Start(Args ...interface{}) error {
 		return fmt.Errorf("plugin error: No Init() function loaded for module [ %s ]", p.Name())
 	}
 
-	p.agent.Logger().Printf("Started module [ %s ]", p.Name())
+	p.agent.StructuredLogger().Info("Started module", "module", p.Name())
+	p.agent.moduleStarted(p)
 
 	return nil
 }
@@ -229,14 +231,14 @@ func (p *GenericPlugin) Reload() error {
 	var err error
 
 	if err = p.Stop(); err != nil {
-		p.agent.Logger().Printf("plugin reload error: Error stopping plugin [ %s ] for reload - %s", p.Name(), err)
+		p.agent.StructuredLogger().Error("plugin reload error: Error stopping plugin for reload", "module", p.Name(), "error", err)
 		return err
 	}
 
 	p.unloadDefaultSymbols()
 
 	if err = p.Load(); err != nil {
-		p.agent.Logger().Printf("plugin reload error: Error loading plugin [ %s ] for reload - %s", p.Name(), err)
+		p.agent.StructuredLogger().Error("plugin reload error: Error loading plugin for reload", "module", p.Name(), "error", err)
 		return err
 	}
 
@@ -274,7 +276,7 @@ func (p *GenericPlugin) ReadStatus() error {
 		// This only happens at the end of a Stop(), which allows closing of this channel to
 		// indicate the plugin is stopped.
 		defer func() {
-			p.agent.Logger().Printf("Finished logging for module [ %s ]", p.Name())
+			p.agent.StructuredLogger().Debug("Finished logging for module", "module", p.Name())
 			p.done <- struct{}{}
 			p.mu.Lock()
 			p.state = stateLoaded
@@ -292,12 +294,12 @@ func (p *GenericPlugin) ReadStatus() error {
 				// channel
 				p.stop()
 			}
-			p.logger.Println(M.String())
+			M.writeTo(p.logger)
 		}
 
 	}(p, C)
 
-	p.agent.Logger().Printf("Started logging for module [ %s ]", p.Name())
+	p.agent.StructuredLogger().Debug("Started logging for module", "module", p.Name())
 
 	return nil
 }
@@ -318,14 +320,14 @@ func (p *GenericPlugin) Uptime() time.Duration {
 // returning any errors which occurred during shutdown
 func (p *GenericPlugin) Stop() error {
 
-	p.agent.Logger().Printf("Stopping module [ %s ]", p.Name())
+	p.agent.StructuredLogger().Info("Stopping module", "module", p.Name())
 
 	switch p.state {
 	case stateNotLoaded:
-		p.agent.Logger().Printf("Module [ %s ] not loaded, nothing to stop", p.Name())
+		p.agent.StructuredLogger().Info("Module not loaded, nothing to stop", "module", p.Name())
 		return nil
 	case stateLoaded:
-		p.agent.Logger().Printf("Module [ %s ] already stopped", p.Name())
+		p.agent.StructuredLogger().Info("Module already stopped", "module", p.Name())
 		return nil
 	default:
 	}
@@ -335,7 +337,8 @@ func (p *GenericPlugin) Stop() error {
 		p.mu.Lock()
 		p.state = stateLoaded
 		p.mu.Unlock()
-		p.agent.Logger().Printf("Stopped module [ %s ]", p.Name())
+		p.agent.StructuredLogger().Info("Stopped module", "module", p.Name())
+		p.agent.moduleStopped(p.Name())
 	}(p)
 
 	return p.stop()
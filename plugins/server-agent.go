@@ -1,6 +1,7 @@
 package plugins
 
 import (
+	"context"
 	"errors"
 	"strings"
 	"sync"
@@ -58,13 +59,15 @@ func NewServerAgent(ModuleFolder, URLRoot string, Server *server.SimpleServer) (
 
 	// Create the new generic component of the agent.
 	OtherServerActive := false
-	A.Agent, err = NewAgent(ModuleFolder, A.server.Logger())
+	A.Agent, err = newAgent(ModuleFolder, A.server.StructuredLogger())
 	if err == ErrOtherServerActive {
 		OtherServerActive = true
 	} else if err != nil {
 		return nil, err
 	}
 	A.Agent.version = ServerFrameworkVersion
+	A.AddExpiryMonitor(A.server.ExpiryMonitor())
+	A.RegisterHealthChecks(A.server.Health())
 
 	// Load the modules from disk, putting this agent into a position where it could be started.
 	if err := A.loadModules(); err != nil {
@@ -75,6 +78,11 @@ func NewServerAgent(ModuleFolder, URLRoot string, Server *server.SimpleServer) (
 		return A, ErrOtherServerActive
 	}
 
+	// Stop the modules once the server has finished serving their requests.
+	A.server.OnShutdown("plugin-agent", func(context.Context) error {
+		return A.Close()
+	})
+
 	return A, nil
 }
 
@@ -30,6 +30,21 @@ type ServerPlugin struct {
 	// Init fields
 	initHandlers  ServerInitHandlersFunc
 	initSubrouter ServerInitSubrouterFunc
+
+	// The routes registered by the most recent Start, protected by the
+	// routerLock of the agent.
+	routes []*server.RouteHandle
+}
+
+// Stop will stop a running module. The routes of the module respond with a
+// 503 status from the moment it begins stopping, until it is started again.
+func (p *ServerPlugin) Stop() error {
+
+	p.agent.routerLock.Lock()
+	server.DisableRoutes(p.routes...)
+	p.agent.routerLock.Unlock()
+
+	return p.GenericPlugin.Stop()
 }
 
 // Reload will fully reload a module.
@@ -39,14 +54,14 @@ func (p *ServerPlugin) Reload() error {
 	var err error
 
 	if err = p.Stop(); err != nil {
-		p.agent.Logger().Printf("plugin reload error: Error stopping plugin [ %s ] for reload - %s", p.Name(), err)
+		p.agent.StructuredLogger().Error("plugin reload error: Error stopping plugin for reload", "module", p.Name(), "error", err)
 		return err
 	}
 
 	p.unloadSymbols()
 
 	if err = p.Load(); err != nil {
-		p.agent.Logger().Printf("plugin reload error: Error loading plugin [ %s ] for reload - %s", p.Name(), err)
+		p.agent.StructuredLogger().Error("plugin reload error: Error loading plugin for reload", "module", p.Name(), "error", err)
 		return err
 	}
 
@@ -62,28 +77,28 @@ func (p *ServerPlugin) Load() error {
 	switch p.state {
 	case stateNotLoaded:
 	case stateLoaded:
-		p.agent.Logger().Printf("Cannot Load() module [ %s ], symbols already loaded", p.Name())
+		p.agent.StructuredLogger().Warn("Cannot Load() module, symbols already loaded", "module", p.Name())
 		return nil
 	case stateActive:
-		p.agent.Logger().Printf("Cannot Load() module [ %s ], already running", p.Name())
+		p.agent.StructuredLogger().Warn("Cannot Load() module, already running", "module", p.Name())
 		return nil
 	}
 
 	// Load the default symbols.
 	if err := p.loadDefaultSymbols(); err != nil {
-		p.agent.Logger().Printf("plugin load error: Failed to load default symbols from file [ %s ]", p.filename)
+		p.agent.StructuredLogger().Error("plugin load error: Failed to load default symbols from file", "file", p.filename)
 		return err
 	}
 
 	// Load the type-specific symbols.
 	if err := p.loadServerSymbols(); err != nil {
-		p.agent.Logger().Printf("plugin load error: Failed to load server symbols from file [ %s ]", p.filename)
+		p.agent.StructuredLogger().Error("plugin load error: Failed to load server symbols from file", "file", p.filename)
 		return err
 	}
 
 	p.state = stateLoaded
 
-	p.agent.Logger().Printf("Loaded all server symbols for module [ %s ]", p.Name())
+	p.agent.StructuredLogger().Info("Loaded all server symbols for module", "module", p.Name())
 
 	return nil
 }
@@ -102,7 +117,7 @@ func (p *ServerPlugin) Start(Args ...interface{}) error {
 		}
 	case stateLoaded:
 	case stateActive:
-		p.agent.Logger().Printf("Cannot Start() module [ %s ], already running", p.Name())
+		p.agent.StructuredLogger().Warn("Cannot Start() module, already running", "module", p.Name())
 		return nil
 	}
 
@@ -124,31 +139,58 @@ func (p *ServerPlugin) Start(Args ...interface{}) error {
 			if err != nil {
 				p.stop()
 				p.state = stateLoaded
+				p.removeRoutes()
 				return err
 			}
 			routes = server.AddPrefixToRoutes(p.agent.urlRoot, routes...)
-			p.agent.server.AddHandlers(p.agent.router, routes...)
+			routes = p.recoverRoutes(routes)
+			p.routes = p.agent.server.ReplaceRoutes(p.routes, routes...)
 		}
 	case p.initSubrouter != nil:
 		{
-			routes, prefix, err := p.initSubrouter(Args...)
+			// As with SimpleServer.AddSubrouter, the routes are matched by
+			// their own paths, so the prefix is not needed to register them.
+			routes, _, err := p.initSubrouter(Args...)
 			if err != nil {
 				p.stop()
 				p.state = stateLoaded
+				p.removeRoutes()
 				return err
 			}
 			routes = server.AddPrefixToRoutes(p.agent.urlRoot, routes...)
-			p.agent.server.AddSubrouter(p.agent.router, prefix, routes...)
+			routes = p.recoverRoutes(routes)
+			p.routes = p.agent.server.ReplaceRoutes(p.routes, routes...)
 		}
 	default:
 		return fmt.Errorf("plugin error: No Init() function loaded for module [ %s ]", p.Name())
 	}
 
-	p.agent.Logger().Printf("Started module [ %s ]", p.Name())
+	p.agent.StructuredLogger().Info("Started module", "module", p.Name())
+	p.agent.moduleStarted(p)
 
 	return nil
 }
 
+// removeRoutes removes any routes left disabled by a previous Stop.
+func (p *ServerPlugin) removeRoutes() {
+	server.RemoveRoutes(p.routes...)
+	p.routes = nil
+}
+
+// recoverRoutes wraps the handlers of the module, so a panic in one of them
+// is logged against the module and answered with a 500 response, rather than
+// dropping the connection.
+func (p *ServerPlugin) recoverRoutes(Routes []server.SimpleHandler) []server.SimpleHandler {
+
+	Recovery := server.MiddlewareRecovery(p.agent.StructuredLogger().With("module", p.Name()), server.RecoveryOptions{})
+
+	for i := range Routes {
+		Routes[i].Handler = Recovery(Routes[i].Handler)
+	}
+
+	return Routes
+}
+
 func (p *ServerPlugin) loadServerSymbols() error {
 
 	raw, err := plugin.Open(p.filename)
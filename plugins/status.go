@@ -2,7 +2,11 @@ package plugins
 
 import (
 	"fmt"
+	"net/http"
 	"sync"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+	"github.com/Bearnie-H/easy-tls/server"
 )
 
 // PluginStatus represents a single status message from a given EasyTLS-compliant plugin.
@@ -32,6 +36,19 @@ func (S *PluginStatus) String() string {
 	}
 }
 
+// writeTo will write the status message to the logger, at a level matching
+// its severity.
+func (S *PluginStatus) writeTo(logger easytls.Logger) {
+	switch {
+	case S.fatal:
+		logger.Error(S.message, "error", S.err, "fatal", true)
+	case S.err != nil:
+		logger.Warn(S.message, "error", S.err)
+	default:
+		logger.Info(S.message)
+	}
+}
+
 func (S *PluginStatus) Error() string {
 	switch {
 	case S.fatal:
@@ -134,6 +151,30 @@ func (W *StatusWriter) Out(S PluginStatus) {
 	)
 }
 
+// ReportPanics will wrap the handlers of a server plugin, writing a status
+// message whenever one of them panics so the Agent knows which module it
+// was. The panic is passed on to be recovered by the framework, which logs
+// its stack and responds with a 500 status.
+func (W *StatusWriter) ReportPanics(Handlers ...server.SimpleHandler) []server.SimpleHandler {
+
+	for i := range Handlers {
+		next := Handlers[i].Handler
+		Handlers[i].Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if Recovered := recover(); Recovered != nil {
+					if Recovered != http.ErrAbortHandler {
+						W.Printf("Recovered from panic serving [ %s %s ]", fmt.Errorf("panic: %v", Recovered), r.Method, r.URL.Path)
+					}
+					panic(Recovered)
+				}
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	return Handlers
+}
+
 // Close will safely close and lock the channel, preventing all other access.
 func (W *StatusWriter) Close(err error) {
 	W.lock.Lock()
@@ -0,0 +1,25 @@
+package plugins
+
+import (
+	"context"
+
+	"github.com/Bearnie-H/easy-tls/tracing"
+)
+
+// traceModule performs a single operation on a module, such as "start",
+// within a span of the tracing.Default Tracer named for it. The span is a
+// child of any span carried by ctx, such as that of a command server request.
+func traceModule(ctx context.Context, Operation string, M Module, Action func() error) error {
+
+	_, Span := tracing.Default().Start(ctx, "plugin."+Operation, tracing.SpanKindInternal)
+	defer Span.End()
+
+	Span.SetAttributes(tracing.AttrPluginModule, M.Name())
+
+	err := Action()
+	if err != nil {
+		Span.RecordError(err)
+	}
+
+	return err
+}
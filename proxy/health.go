@@ -0,0 +1,37 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/Bearnie-H/easy-tls/server"
+)
+
+// HealthCheck returns a health check for the upstream of the rule, which
+// passes if a TCP connection can be opened to the destination host and port.
+func (R *ReverseProxyRoutingRule) HealthCheck() server.HealthCheck {
+
+	Address := net.JoinHostPort(R.DestinationHost, fmt.Sprintf("%d", R.DestinationPort))
+
+	return server.HealthCheck{
+		Name: "proxy:" + R.PathPrefix,
+		Check: func(ctx context.Context) error {
+			Conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", Address)
+			if err != nil {
+				return fmt.Errorf("easytls proxy error - Upstream [ %s ] unreachable - %s", Address, err)
+			}
+			return Conn.Close()
+		},
+	}
+}
+
+// RegisterHealthChecks will register a health check with the HealthChecker
+// for the upstream of every rule in the set which forwards requests.
+func (a ReverseProxyRuleSet) RegisterHealthChecks(H *server.HealthChecker) {
+	for i := range a {
+		if !a[i].ForbidRoute {
+			H.AddCheck(a[i].HealthCheck())
+		}
+	}
+}
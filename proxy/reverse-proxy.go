@@ -1,28 +1,36 @@
 package proxy
 
 import (
+	"errors"
 	"fmt"
 	"html"
 	"io"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
+	easytls "github.com/Bearnie-H/easy-tls"
 	"github.com/Bearnie-H/easy-tls/client"
 	"github.com/Bearnie-H/easy-tls/header"
 	"github.com/Bearnie-H/easy-tls/server"
+	"github.com/Bearnie-H/easy-tls/tracing"
 )
 
 // NotFoundHandlerProxyOverride will override the NotFound handler of the
 // Server with a reverse proxy lookup function. This will allow the server
 // to attempt to re-route requests it doesn't have a defined route for, while
 // still falling back to a "NotFound" 404 response if there is
-// no defined place to route to.
+// no defined place to route to. A generated Client has circuit breakers enabled,
+// and their state is served by a BreakerStatusHandler added to the Server.
 func NotFoundHandlerProxyOverride(S *server.SimpleServer, c *client.SimpleClient, RouteMatcher ReverseProxyRouterFunc, logger *log.Logger) {
 
 	var err error
 
-	if logger == nil {
-		logger = S.Logger()
+	Logger := S.StructuredLogger()
+	if logger != nil {
+		Logger = easytls.AsLogger(logger)
 	}
 
 	if c == nil {
@@ -30,10 +38,12 @@ func NotFoundHandlerProxyOverride(S *server.SimpleServer, c *client.SimpleClient
 		if err != nil {
 			panic(err)
 		}
-		c.SetLogger(logger)
+		c.SetStructuredLogger(Logger)
+		c.EnableCircuitBreakers(client.BreakerOptions{})
+		S.AddHandlers(S.Router(), BreakerStatusHandler(c))
 	}
 
-	S.Router().NotFoundHandler = DoReverseProxy(c, RouteMatcher, logger)
+	S.Router().NotFoundHandler = doReverseProxy(c, RouteMatcher, Logger)
 }
 
 // ConfigureReverseProxy will convert a freshly created SimpleServer
@@ -44,6 +54,13 @@ func NotFoundHandlerProxyOverride(S *server.SimpleServer, c *client.SimpleClient
 // The PathPrefix defines the base path to proxy from, with a default of "/"
 // indicating that ALL incoming requests should be proxied.
 // If No Server or Client are provided, default instances will be generated.
+//
+// A generated Client shares the TLSBundle of the Server, so upstream servers
+// are verified against the ServerAuthorities of the bundle, while incoming
+// clients are verified against the ClientAuthorities.
+// It also has circuit breakers enabled, so requests to an upstream which is
+// failing are rejected with a 503 until it recovers. The state of these
+// breakers is served by a BreakerStatusHandler added to the Server.
 func ConfigureReverseProxy(S *server.SimpleServer, Client *client.SimpleClient, logger *log.Logger, RouteMatcher ReverseProxyRouterFunc, PathPrefix string) *server.SimpleServer {
 
 	// If No server is provided, create a default HTTP Server.
@@ -58,8 +75,9 @@ func ConfigureReverseProxy(S *server.SimpleServer, Client *client.SimpleClient,
 	}
 
 	// If there's no logger provided, use the one from the server
-	if logger == nil {
-		logger = S.Logger()
+	Logger := S.StructuredLogger()
+	if logger != nil {
+		Logger = easytls.AsLogger(logger)
 	}
 
 	// If no client is given, attempt to create one, using any TLS resources the potential server had.
@@ -68,17 +86,19 @@ func ConfigureReverseProxy(S *server.SimpleServer, Client *client.SimpleClient,
 		if err != nil {
 			panic(err)
 		}
-		Client.SetLogger(logger)
+		Client.SetStructuredLogger(Logger)
+		Client.EnableCircuitBreakers(client.BreakerOptions{})
+		S.AddHandlers(S.Router(), BreakerStatusHandler(Client))
 	}
 
 	S.AddSubrouter(
 		S.Router(),
 		PathPrefix,
 		server.NewSimpleHandler(
-			DoReverseProxy(
+			doReverseProxy(
 				Client,
 				RouteMatcher,
-				logger,
+				Logger,
 			),
 			PathPrefix,
 		),
@@ -87,6 +107,48 @@ func ConfigureReverseProxy(S *server.SimpleServer, Client *client.SimpleClient,
 	return S
 }
 
+// BreakerStatusHandler will return a SimpleHandler serving the state of the
+// circuit breakers of the client at "/about/breakers". This is added
+// automatically for a Client generated by the reverse proxy. For a Client
+// passed in, with a reverse proxy serving every path, this must be added
+// before ConfigureReverseProxy.
+func BreakerStatusHandler(C *client.SimpleClient) server.SimpleHandler {
+
+	H := server.NewSimpleHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Breakers := C.CircuitBreakers()
+		if Breakers == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		Breakers.ServeHTTP(w, r)
+	}), "/about/breakers", http.MethodGet)
+	H.AddDescription("The state of the circuit breaker of each upstream host.")
+
+	return H
+}
+
+// MetricUpstreamDuration is the name of the metric recording the latency of
+// the requests forwarded to upstream hosts.
+const MetricUpstreamDuration = "easytls_proxy_upstream_duration_seconds"
+
+// observeUpstream records the latency of a single forwarded request, in the
+// Registry of the client, if metrics are enabled on it.
+func observeUpstream(C *client.SimpleClient, Upstream string, resp *http.Response, Duration time.Duration) {
+
+	Registry := C.Metrics()
+	if Registry == nil {
+		return
+	}
+
+	Status := "error"
+	if resp != nil {
+		Status = strconv.Itoa(resp.StatusCode)
+	}
+
+	Registry.Histogram(MetricUpstreamDuration, "Latency of the requests forwarded to upstream hosts, by upstream and status code.", nil, "upstream", "status").
+		Observe(Duration.Seconds(), Upstream, Status)
+}
+
 // DoReverseProxy is the backbone of this package, and the reverse
 // Proxy behaviour in general.
 //
@@ -100,32 +162,61 @@ func ConfigureReverseProxy(S *server.SimpleServer, Client *client.SimpleClient,
 //
 func DoReverseProxy(C *client.SimpleClient, Matcher ReverseProxyRouterFunc, logger *log.Logger) http.HandlerFunc {
 
+	// If there's no logger provided, use the one from the client
+	if logger == nil {
+		return doReverseProxy(C, Matcher, C.StructuredLogger())
+	}
+
+	return doReverseProxy(C, Matcher, easytls.AsLogger(logger))
+}
+
+// doReverseProxy is DoReverseProxy, writing to a leveled, structured logger.
+func doReverseProxy(C *client.SimpleClient, Matcher ReverseProxyRouterFunc, logger easytls.Logger) http.HandlerFunc {
+
 	// Anonymous function to be returned, and is what is actually called when requests come in.
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer r.Body.Close()
 
+		// Attach the ID of the request to every message about it.
+		Logger := easytls.RequestLogger(r.Context(), logger)
+
+		// Continue the trace of the incoming request, whether or not the
+		// server has already started a span for it.
+		ctx := r.Context()
+		if !tracing.SpanContextFromContext(ctx).IsValid() {
+			ctx = tracing.Extract(ctx, r.Header)
+		}
+		ctx, Span := C.Tracer().Start(ctx, "proxy.forward", tracing.SpanKindInternal)
+		defer Span.End()
+		Span.SetAttributes(tracing.AttrHTTPMethod, r.Method, tracing.AttrURLPath, r.URL.Path)
+
 		// Create the new URL to use, based on the TLS settings of the Client, and the incoming request.
 		proxyURL, err := Matcher(r)
+		if err != nil {
+			Span.RecordError(err)
+		}
 		switch err {
 		case nil:
 		case ErrRouteNotFound:
-			logger.Printf("Failed to find destination host:port for URL [ %s ] from %s - %s", r.URL.String(), r.RemoteAddr, err)
+			Logger.Warn("Failed to find destination host:port for request", "url", r.URL.String(), "remote_addr", r.RemoteAddr, "error", err)
 			w.WriteHeader(http.StatusNotFound)
 			return
 		case ErrForbiddenRoute:
-			logger.Printf("Cannot forward request for URL [ %s ] from %s - %s", r.URL.String(), r.RemoteAddr, err)
+			Logger.Warn("Cannot forward request", "url", r.URL.String(), "remote_addr", r.RemoteAddr, "error", err)
 			w.WriteHeader(http.StatusForbidden)
 			return
 		default:
-			logger.Printf("Failed to format proxy forwarding for URL [ %s ] from %s - %s", r.URL.String(), r.RemoteAddr, err)
+			Logger.Error("Failed to format proxy forwarding", "url", r.URL.String(), "remote_addr", r.RemoteAddr, "error", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
-		// Create the new Request to send
-		proxyReq, err := client.NewRequest(r.Method, proxyURL.String(), r.Header, r.Body)
+		// Create the new Request to send, with the context of the original so
+		// its request ID and trace are forwarded, and it is cancelled along with it.
+		proxyReq, err := client.NewRequestWithContext(ctx, r.Method, proxyURL.String(), r.Header, r.Body)
 		if err != nil {
-			logger.Printf("Failed to create proxy forwarding request for URL [ %s ] from %s - %s", r.URL.String(), r.RemoteAddr, err)
+			Span.RecordError(err)
+			Logger.Error("Failed to create proxy forwarding request", "url", r.URL.String(), "remote_addr", r.RemoteAddr, "error", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -137,12 +228,25 @@ func DoReverseProxy(C *client.SimpleClient, Matcher ReverseProxyRouterFunc, logg
 		}
 		header.Merge(&(proxyReq.Header), &proxyHeaders)
 
-		logger.Printf("Forwarding [ %s [ %s ] ] from [ %s ] to [ %s ]", r.URL.String(), r.Method, r.RemoteAddr, proxyURL.String())
+		Span.SetAttributes(tracing.AttrServerAddress, proxyURL.Host)
+		Logger.Info("Forwarding request", "url", r.URL.String(), "method", r.Method, "remote_addr", r.RemoteAddr, "destination", proxyURL.String())
 
 		// Perform the full proxy request
+		Start := time.Now()
 		proxyResp, err := C.Do(proxyReq)
+		observeUpstream(C, proxyURL.Host, proxyResp, time.Since(Start))
+		if Open := (*client.CircuitOpenError)(nil); errors.As(err, &Open) {
+			Span.RecordError(err)
+			Logger.Warn("Rejected request to upstream with open circuit breaker", "url", r.URL.String(), "remote_addr", r.RemoteAddr, "destination", proxyURL.String())
+			if Open.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(Open.RetryAfter.Seconds()))))
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
 		if err != nil {
-			logger.Printf("Failed to perform proxy request for URL [ %s ] from %s - %s", r.URL.String(), r.RemoteAddr, err)
+			Span.RecordError(err)
+			Logger.Error("Failed to perform proxy request", "url", r.URL.String(), "remote_addr", r.RemoteAddr, "error", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(html.EscapeString(fmt.Sprintf("Failed to perform proxy request for URL [ %s ] - %s.\n", r.URL.String(), err))))
 			return
@@ -153,11 +257,12 @@ func DoReverseProxy(C *client.SimpleClient, Matcher ReverseProxyRouterFunc, logg
 		header.Merge(&responseHeader, &(proxyResp.Header))
 
 		// Write back the status code
+		Span.SetAttributes(tracing.AttrHTTPStatusCode, proxyResp.StatusCode)
 		w.WriteHeader(proxyResp.StatusCode)
 
 		// Write back the response body
 		if _, err := io.Copy(w, proxyResp.Body); err != nil {
-			logger.Printf("Failed to write back proxy response for URL [ %s ] from %s - %s", r.URL.String(), r.RemoteAddr, err)
+			Logger.Error("Failed to write back proxy response", "url", r.URL.String(), "remote_addr", r.RemoteAddr, "error", err)
 			return
 		}
 	})
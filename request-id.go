@@ -0,0 +1,47 @@
+package easytls
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// RequestIDHeader is the header carrying the ID of a request between the
+// servers, clients and reverse proxies of this project.
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the context key of the ID of a request.
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying the given request ID.
+// Requests made by a SimpleClient with this context forward the ID to the
+// server in the RequestIDHeader.
+func ContextWithRequestID(ctx context.Context, ID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, ID)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, if any.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	ID, ok := ctx.Value(requestIDKey{}).(string)
+	return ID, ok && ID != ""
+}
+
+// NewRequestID generates a new random request ID.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// RequestLogger returns a Logger which attaches the request ID carried by ctx
+// to all messages it writes, as the "request_id" field. The logger is returned
+// unchanged if ctx carries no request ID.
+func RequestLogger(ctx context.Context, logger Logger) Logger {
+	if logger == nil {
+		return nil
+	}
+	if ID, ok := RequestIDFromContext(ctx); ok {
+		return logger.With("request_id", ID)
+	}
+	return logger
+}
@@ -0,0 +1,401 @@
+package easytls
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultRevocationInterval is the minimum time between refreshes of the
+// revocation information of a TLSBundle, if no explicit interval is provided.
+const DefaultRevocationInterval = time.Hour
+
+// ocspRetryInterval is the minimum time between attempts to fetch an OCSP
+// response after a failure.
+const ocspRetryInterval = time.Minute
+
+// RevocationPolicy defines how the certificates presented by peers are checked
+// for revocation, and whether the status of our own certificates is stapled
+// to the handshake.
+type RevocationPolicy struct {
+
+	// CRLFiles is a set of filenames of PEM or DER encoded Certificate
+	// Revocation Lists. Any verified peer certificate listed as revoked by a
+	// CRL from its issuer is rejected during the handshake.
+	//
+	// These are read from the FS of the TLSBundle, if one is set.
+	CRLFiles []string
+
+	// RefreshInterval is the minimum time between re-reading the CRLFiles, and
+	// between fetching new OCSP responses to staple.
+	// Defaults to DefaultRevocationInterval if not set.
+	RefreshInterval time.Duration
+
+	// OCSPStapling turns on fetching the OCSP status of the certificates
+	// presented by a server, and attaching it to the handshake.
+	//
+	// The issuer of each certificate must be either included in its chain, or
+	// listed in the Authorities of the TLSBundle.
+	OCSPStapling bool
+
+	// OCSPResponder overrides the OCSP Responder URL listed in the certificates.
+	OCSPResponder string
+
+	// OCSPClient is the client used to contact the OCSP Responder.
+	// Defaults to a client with a 10 second timeout if not set.
+	OCSPClient *http.Client
+
+	// Logger is where revocation messages will be written.
+	// Defaults to NewDefaultStructuredLogger() if not set.
+	Logger Logger
+}
+
+// enabled returns whether the policy performs any revocation checks.
+func (R *RevocationPolicy) enabled() bool {
+	return len(R.CRLFiles) > 0 || R.OCSPStapling
+}
+
+// ocspStaple is the most recent OCSP response for one of our certificates.
+type ocspStaple struct {
+	status    *ocspStatus
+	fetched   time.Time
+	attempted time.Time
+	fetching  bool
+}
+
+// revocationChecker holds the most recently loaded revocation information for
+// a TLSBundle, and refreshes it as it ages.
+type revocationChecker struct {
+	bundle TLSBundle
+	logger Logger
+	client *http.Client
+
+	mu          *sync.RWMutex
+	lastRefresh time.Time
+	crls        []*x509.RevocationList
+	staples     map[[sha256.Size]byte]*ocspStaple
+}
+
+func newRevocationChecker(TLS *TLSBundle, Certificates []tls.Certificate) (*revocationChecker, error) {
+
+	r := &revocationChecker{
+		bundle:      *TLS,
+		logger:      TLS.Revocation.Logger,
+		client:      TLS.Revocation.OCSPClient,
+		mu:          &sync.RWMutex{},
+		lastRefresh: time.Now(),
+		staples:     make(map[[sha256.Size]byte]*ocspStaple),
+	}
+
+	if r.bundle.Revocation.RefreshInterval <= 0 {
+		r.bundle.Revocation.RefreshInterval = DefaultRevocationInterval
+	}
+
+	if r.logger == nil {
+		r.logger = NewDefaultStructuredLogger()
+	}
+
+	if r.client == nil {
+		r.client = &http.Client{Timeout: time.Second * 10}
+	}
+
+	CRLs, err := r.loadCRLs()
+	if err != nil {
+		return nil, err
+	}
+	r.crls = CRLs
+
+	// Start fetching the initial staples straight away, so they are ready as
+	// soon as possible. This happens in the background, as an unavailable
+	// Responder must not hold up building the tls.Config; stapling is
+	// optional, and handshakes simply go without until a response arrives.
+	if r.bundle.Revocation.OCSPStapling {
+		for i := range Certificates {
+			if len(Certificates[i].Certificate) == 0 {
+				continue
+			}
+			Cert := Certificates[i]
+			r.staples[sha256.Sum256(Cert.Certificate[0])] = &ocspStaple{fetching: true}
+			go r.fetchStaple(&Cert)
+		}
+	}
+
+	return r, nil
+}
+
+// configure will add the revocation checks and OCSP stapling to the tls.Config.
+// This must be applied after any other hooks selecting certificates.
+func (r *revocationChecker) configure(Config *tls.Config) {
+
+	// Any existing verification is kept, and only consulted for chains which
+	// are not revoked.
+	if len(r.bundle.Revocation.CRLFiles) > 0 {
+		VerifyPeer := Config.VerifyPeerCertificate
+		Config.VerifyPeerCertificate = func(Raw [][]byte, Chains [][]*x509.Certificate) error {
+			if err := r.verifyPeerCertificate(Raw, Chains); err != nil {
+				return err
+			}
+			if VerifyPeer != nil {
+				return VerifyPeer(Raw, Chains)
+			}
+			return nil
+		}
+	}
+
+	if !r.bundle.Revocation.OCSPStapling {
+		return
+	}
+
+	// GetCertificate is only consulted if there are no static Certificates,
+	// so move them behind the same selection the reloader performs.
+	GetCertificate := Config.GetCertificate
+	if GetCertificate == nil {
+		Certificates := Config.Certificates
+		GetCertificate = func(Hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return selectCertificate(Hello, Certificates)
+		}
+		if Config.GetClientCertificate == nil {
+			Config.GetClientCertificate = func(Request *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				return selectClientCertificate(Request, Certificates)
+			}
+		}
+	}
+
+	Config.Certificates = nil
+	Config.GetCertificate = func(Hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		Cert, err := GetCertificate(Hello)
+		if err != nil || Cert == nil {
+			return Cert, err
+		}
+		return r.staple(Cert), nil
+	}
+}
+
+// verifyPeerCertificate rejects any verified chain containing a certificate
+// revoked by a CRL from its issuer.
+func (r *revocationChecker) verifyPeerCertificate(_ [][]byte, Chains [][]*x509.Certificate) error {
+	r.refresh()
+
+	r.mu.RLock()
+	CRLs := r.crls
+	r.mu.RUnlock()
+
+	for _, Chain := range Chains {
+		for i := 0; i+1 < len(Chain); i++ {
+			Cert, Issuer := Chain[i], Chain[i+1]
+			for _, CRL := range CRLs {
+				if !bytes.Equal(CRL.RawIssuer, Cert.RawIssuer) || CRL.CheckSignatureFrom(Issuer) != nil {
+					continue
+				}
+				for _, Revoked := range CRL.RevokedCertificates {
+					if Revoked.SerialNumber.Cmp(Cert.SerialNumber) == 0 {
+						return fmt.Errorf("easytls error: Certificate [ %s ] with serial [ %s ] has been revoked", Cert.Subject.CommonName, Cert.SerialNumber)
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// refresh will, at most once per RefreshInterval, re-read the CRL files. If
+// any fail to load, the previous set remains in use.
+func (r *revocationChecker) refresh() {
+
+	r.mu.Lock()
+	if time.Since(r.lastRefresh) < r.bundle.Revocation.RefreshInterval {
+		r.mu.Unlock()
+		return
+	}
+	r.lastRefresh = time.Now()
+	r.mu.Unlock()
+
+	CRLs, err := r.loadCRLs()
+	if err != nil {
+		r.logger.Error("Rejected changed Certificate Revocation Lists", "error", err)
+		return
+	}
+
+	r.mu.Lock()
+	r.crls = CRLs
+	r.mu.Unlock()
+}
+
+// loadCRLs will read and parse all of the CRL files of the bundle.
+func (r *revocationChecker) loadCRLs() ([]*x509.RevocationList, error) {
+
+	CRLs := []*x509.RevocationList{}
+
+	for _, Filename := range r.bundle.Revocation.CRLFiles {
+
+		Contents, err := r.bundle.readFile(Filename)
+		if err != nil {
+			return nil, err
+		}
+
+		Blocks := [][]byte{}
+		for Rest := Contents; ; {
+			var Block *pem.Block
+			if Block, Rest = pem.Decode(Rest); Block == nil {
+				break
+			}
+			if Block.Type == "X509 CRL" {
+				Blocks = append(Blocks, Block.Bytes)
+			}
+		}
+
+		// Anything not in PEM form is assumed to be a single DER encoded CRL.
+		if len(Blocks) == 0 {
+			Blocks = append(Blocks, Contents)
+		}
+
+		for _, Block := range Blocks {
+			CRL, err := x509.ParseRevocationList(Block)
+			if err != nil {
+				return nil, fmt.Errorf("easytls error: Failed to parse Certificate Revocation List [ %s ] - %s", Filename, err)
+			}
+			if !CRL.NextUpdate.IsZero() && time.Now().After(CRL.NextUpdate) {
+				r.logger.Warn("Certificate Revocation List is past its next update time", "file", Filename, "next_update", CRL.NextUpdate)
+			}
+			CRLs = append(CRLs, CRL)
+		}
+	}
+
+	return CRLs, nil
+}
+
+// staple returns the certificate with its current OCSP response attached,
+// starting a refresh of the response in the background if it is due.
+func (r *revocationChecker) staple(Cert *tls.Certificate) *tls.Certificate {
+
+	if len(Cert.Certificate) == 0 {
+		return Cert
+	}
+
+	Key := sha256.Sum256(Cert.Certificate[0])
+	Now := time.Now()
+
+	r.mu.Lock()
+	Staple, ok := r.staples[Key]
+	if !ok {
+		Staple = &ocspStaple{}
+		r.staples[Key] = Staple
+	}
+	if !Staple.fetching && r.due(Staple, Now) {
+		Staple.fetching = true
+		go r.fetchStaple(Cert)
+	}
+	Status := Staple.status
+	r.mu.Unlock()
+
+	// Never staple a response which has expired.
+	if Status == nil || (!Status.NextUpdate.IsZero() && Now.After(Status.NextUpdate)) {
+		return Cert
+	}
+
+	Stapled := *Cert
+	Stapled.OCSPStaple = Status.Raw
+	return &Stapled
+}
+
+// due determines whether a new OCSP response should be fetched, either because
+// the RefreshInterval has passed or the current response is half-way to expiring.
+func (r *revocationChecker) due(Staple *ocspStaple, Now time.Time) bool {
+
+	if Now.Sub(Staple.attempted) < ocspRetryInterval {
+		return false
+	}
+
+	if Staple.status == nil || Now.Sub(Staple.fetched) >= r.bundle.Revocation.RefreshInterval {
+		return true
+	}
+
+	if NextUpdate := Staple.status.NextUpdate; !NextUpdate.IsZero() {
+		return Now.After(NextUpdate.Add(-NextUpdate.Sub(Staple.status.ThisUpdate) / 2))
+	}
+
+	return false
+}
+
+// fetchStaple will request the current OCSP response for the certificate,
+// and store it for stapling if the certificate is not revoked.
+func (r *revocationChecker) fetchStaple(Cert *tls.Certificate) {
+
+	Key := sha256.Sum256(Cert.Certificate[0])
+	Status, err := r.fetchStatus(Cert)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	Staple, ok := r.staples[Key]
+	if !ok {
+		Staple = &ocspStaple{}
+		r.staples[Key] = Staple
+	}
+	Staple.fetching = false
+	Staple.attempted = time.Now()
+
+	switch {
+	case err != nil:
+		r.logger.Error("Failed to fetch OCSP response", "error", err)
+	case Status.Revoked:
+		Staple.status = nil
+		r.logger.Error("OCSP Responder reports our certificate as revoked, no longer stapling")
+	default:
+		Staple.status = Status
+		Staple.fetched = Staple.attempted
+	}
+}
+
+func (r *revocationChecker) fetchStatus(Cert *tls.Certificate) (*ocspStatus, error) {
+
+	Leaf, err := x509.ParseCertificate(Cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+
+	URL := r.bundle.Revocation.OCSPResponder
+	if URL == "" {
+		if len(Leaf.OCSPServer) == 0 {
+			return nil, fmt.Errorf("easytls ocsp error: Certificate [ %s ] does not list an OCSP Responder", Leaf.Subject.CommonName)
+		}
+		URL = Leaf.OCSPServer[0]
+	}
+
+	Issuer, err := r.findIssuer(Leaf, Cert.Certificate[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	return fetchOCSPStatus(r.client, URL, Leaf, Issuer)
+}
+
+// findIssuer will look for the certificate which issued Leaf, first in its
+// chain and then in the Authorities of the bundle.
+func (r *revocationChecker) findIssuer(Leaf *x509.Certificate, Chain [][]byte) (*x509.Certificate, error) {
+
+	Candidates := []*x509.Certificate{}
+	for _, Raw := range Chain {
+		if Cert, err := x509.ParseCertificate(Raw); err == nil {
+			Candidates = append(Candidates, Cert)
+		}
+	}
+
+	Candidates = append(Candidates, r.bundle.authorityCertificates()...)
+
+	for _, Candidate := range Candidates {
+		if bytes.Equal(Candidate.RawSubject, Leaf.RawIssuer) && Leaf.CheckSignatureFrom(Candidate) == nil {
+			return Candidate, nil
+		}
+	}
+
+	return nil, errors.New("easytls ocsp error: Failed to find the issuer of the certificate")
+}
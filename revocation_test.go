@@ -0,0 +1,182 @@
+package easytls_test
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+	"github.com/Bearnie-H/easy-tls/client"
+)
+
+func TestCRLRevocation(T *testing.T) {
+
+	CA, ServerBundle, ClientBundle := newTestAuthority(T)
+
+	Revoked, err := CA.IssueClientCertificate(easytls.CertificateOptions{CommonName: "revoked"})
+	if err != nil {
+		T.Fatalf("Failed to issue client certificate - %s", err)
+	}
+
+	CRL, err := CA.CreateCRL(time.Hour, Revoked.Certificate)
+	if err != nil {
+		T.Fatalf("Failed to create CRL - %s", err)
+	}
+
+	CRLFile := filepath.Join(T.TempDir(), "ca.crl")
+	if err := ioutil.WriteFile(CRLFile, CRL, 0644); err != nil {
+		T.Fatalf("Failed to write CRL - %s", err)
+	}
+
+	ServerBundle.Revocation = easytls.RevocationPolicy{CRLFiles: []string{CRLFile}, Logger: testLogger()}
+	URL := startTestServer(T, ServerBundle)
+
+	C, err := client.NewClientHTTPS(ClientBundle)
+	if err != nil {
+		T.Fatalf("Failed to create client - %s", err)
+	}
+
+	resp, err := C.Get(URL+"/hello", nil)
+	if err != nil {
+		T.Fatalf("Expected unrevoked client certificate to be accepted - %s", err)
+	}
+	resp.Body.Close()
+
+	ClientBundle.KeyPair = easytls.KeyPair{}
+	ClientBundle.Certificates = []tls.Certificate{Revoked.TLSCertificate()}
+
+	C, err = client.NewClientHTTPS(ClientBundle)
+	if err != nil {
+		T.Fatalf("Failed to create client - %s", err)
+	}
+
+	if resp, err := C.Get(URL+"/hello", nil); err == nil {
+		resp.Body.Close()
+		T.Fatalf("Expected revoked client certificate to be rejected")
+	}
+}
+
+func TestOCSPStapling(T *testing.T) {
+
+	CA, ServerBundle, ClientBundle := newTestAuthority(T)
+
+	Responder := httptest.NewServer(CA.OCSPResponder(time.Hour))
+	defer Responder.Close()
+
+	ServerBundle.Revocation = easytls.RevocationPolicy{
+		OCSPStapling:  true,
+		OCSPResponder: Responder.URL,
+		Logger:        testLogger(),
+	}
+	URL := startTestServer(T, ServerBundle)
+
+	C, err := client.NewClientHTTPS(ClientBundle)
+	if err != nil {
+		T.Fatalf("Failed to create client - %s", err)
+	}
+
+	// The first response is fetched in the background, so may not be ready
+	// for the very first handshakes.
+	Stapled := false
+	for Deadline := time.Now().Add(time.Second * 5); !Stapled && time.Now().Before(Deadline); time.Sleep(time.Millisecond * 10) {
+		resp, err := C.Get(URL+"/hello", nil)
+		if err != nil {
+			T.Fatalf("Failed to perform request - %s", err)
+		}
+		resp.Body.Close()
+		C.Client.CloseIdleConnections()
+		Stapled = len(resp.TLS.OCSPResponse) > 0
+	}
+
+	if !Stapled {
+		T.Fatalf("Expected an OCSP response to be stapled")
+	}
+
+	// A server whose certificate is revoked must not staple the response.
+	Server, err := CA.IssueServerCertificate(easytls.CertificateOptions{CommonName: "server", DNSNames: []string{"localhost"}})
+	if err != nil {
+		T.Fatalf("Failed to issue server certificate - %s", err)
+	}
+
+	Answered := make(chan struct{}, 1)
+	Revoker := CA.OCSPResponder(time.Hour, Server.Certificate)
+	RevokedResponder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Revoker.ServeHTTP(w, r)
+		Answered <- struct{}{}
+	}))
+	defer RevokedResponder.Close()
+
+	Chain := Server.TLSCertificate()
+	Chain.Certificate = append(Chain.Certificate, CA.Certificate.Raw)
+
+	Config, err := easytls.NewTLSConfig(&easytls.TLSBundle{
+		Certificates: []tls.Certificate{Chain},
+		Revocation: easytls.RevocationPolicy{
+			OCSPStapling:  true,
+			OCSPResponder: RevokedResponder.URL,
+			Logger:        testLogger(),
+		},
+		Enabled: true,
+	})
+	if err != nil {
+		T.Fatalf("Failed to create TLS config - %s", err)
+	}
+
+	select {
+	case <-Answered:
+	case <-time.After(time.Second * 5):
+		T.Fatalf("Expected the OCSP Responder to be asked for the status of the certificate")
+	}
+
+	for i := 0; i < 10; i++ {
+		Cert, err := Config.GetCertificate(&tls.ClientHelloInfo{ServerName: "localhost"})
+		if err != nil {
+			T.Fatalf("Failed to get certificate - %s", err)
+		}
+		if len(Cert.OCSPStaple) != 0 {
+			T.Fatalf("Expected no OCSP response to be stapled for a revoked certificate")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestOCSPUnavailableResponder(T *testing.T) {
+
+	_, ServerBundle, _ := newTestAuthority(T)
+
+	// The Responder never answers, until the test is over.
+	Done := make(chan struct{})
+	Responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-Done
+	}))
+	defer Responder.Close()
+	defer close(Done)
+
+	ServerBundle.Revocation = easytls.RevocationPolicy{
+		OCSPStapling:  true,
+		OCSPResponder: Responder.URL,
+		Logger:        testLogger(),
+	}
+
+	Start := time.Now()
+	Config, err := easytls.NewTLSConfig(ServerBundle)
+	if err != nil {
+		T.Fatalf("Failed to create TLS config - %s", err)
+	}
+	if Elapsed := time.Since(Start); Elapsed > time.Second {
+		T.Fatalf("Expected building the TLS config to not wait for the OCSP Responder, took %s", Elapsed)
+	}
+
+	// Handshakes go ahead without a staple in the meantime.
+	Cert, err := Config.GetCertificate(&tls.ClientHelloInfo{ServerName: "localhost"})
+	if err != nil {
+		T.Fatalf("Failed to get certificate - %s", err)
+	}
+	if len(Cert.OCSPStaple) != 0 {
+		T.Fatalf("Expected the certificate to be served without a staple")
+	}
+}
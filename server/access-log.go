@@ -0,0 +1,431 @@
+package server
+
+import (
+	"bufio"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+)
+
+// DefaultRequestIDHeader is the header used to carry the ID of a request,
+// if no other header is configured.
+const DefaultRequestIDHeader = easytls.RequestIDHeader
+
+// AccessLogFormat defines how each completed request is written to the log.
+type AccessLogFormat int
+
+const (
+	// AccessLogStructured writes each request as a single message, with
+	// the configured Fields attached as key/value pairs. With a JSON logger,
+	// such as easytls.NewSlogLogger with a slog.JSONHandler, this produces
+	// one JSON object per request.
+	AccessLogStructured AccessLogFormat = iota
+
+	// AccessLogCommon writes each request as a line in the Common Log Format.
+	AccessLogCommon
+
+	// AccessLogCombined writes each request as a line in the Combined Log
+	// Format, which extends the Common Log Format with the Referer and
+	// User-Agent of the request.
+	AccessLogCombined
+)
+
+// The set of fields available to structured access logs.
+const (
+	AccessFieldRequestID  = "request_id"
+	AccessFieldRemoteAddr = "remote_addr"
+	AccessFieldUser       = "user"
+	AccessFieldMethod     = "method"
+	AccessFieldURL        = "url"
+	AccessFieldProto      = "proto"
+	AccessFieldHost       = "host"
+	AccessFieldStatus     = "status"
+	AccessFieldBytes      = "bytes"
+	AccessFieldDuration   = "duration"
+	AccessFieldReferer    = "referer"
+	AccessFieldUserAgent  = "user_agent"
+)
+
+// DefaultAccessLogFields is the set of fields written by structured access
+// logs, if none are explicitly given.
+var DefaultAccessLogFields = []string{
+	AccessFieldRequestID,
+	AccessFieldRemoteAddr,
+	AccessFieldUser,
+	AccessFieldMethod,
+	AccessFieldURL,
+	AccessFieldProto,
+	AccessFieldStatus,
+	AccessFieldBytes,
+	AccessFieldDuration,
+}
+
+// AccessLogOptions configures the access logs written by MiddlewareAccessLog.
+type AccessLogOptions struct {
+
+	// Format defines how each request is written to the log.
+	Format AccessLogFormat
+
+	// Fields is the ordered set of fields attached to structured access logs.
+	// Defaults to DefaultAccessLogFields if not set.
+	Fields []string
+
+	// SampleRate is the fraction of requests, between 0 and 1, which are
+	// logged. Requests resulting in a server error are always logged.
+	// A value of 0 (or 1) logs all requests.
+	SampleRate float64
+
+	// RequestIDHeader is the header the ID of a request is read from, or
+	// written to if the request has none. The ID is also echoed back in the
+	// response, and available to handlers with RequestIDFromContext.
+	// Defaults to DefaultRequestIDHeader if not set.
+	RequestIDHeader string
+}
+
+// AccessLogEntry is the full set of properties recorded for a single request.
+type AccessLogEntry struct {
+	RequestID  string
+	RemoteAddr string
+	User       string
+	Method     string
+	URL        string
+	Proto      string
+	Host       string
+	Status     int
+	Bytes      int64
+	Duration   time.Duration
+	Referer    string
+	UserAgent  string
+	Time       time.Time
+}
+
+// MiddlewareDefaultLogger provides a simple logging middleware, to view
+// requests as they complete and print a basic set of properties of the
+// request and response. This is MiddlewareAccessLog with the default options.
+func MiddlewareDefaultLogger(logger *log.Logger) func(http.Handler) http.Handler {
+	return MiddlewareAccessLog(easytls.AsLogger(logger), AccessLogOptions{})
+}
+
+// MiddlewareAccessLog provides an access log middleware, which writes one
+// message per request once the response has completed, including the status
+// code, response size, and latency of the request.
+//
+// The http.ResponseWriter given to later handlers supports http.Flusher and
+// http.Hijacker exactly when the underlying one does, so streaming and
+// protocol upgrades are unaffected.
+//
+// If a later handler panics, the request is logged with a 500 status and the
+// panic continues, to be handled by the http.Server or MiddlewareRecovery.
+//
+// Lines in the Common and Combined Log Formats are written as they are, with
+// no level or fields, by a Logger created by easytls.NewStdLogger.
+func MiddlewareAccessLog(logger easytls.Logger, Options AccessLogOptions) MiddlewareHandler {
+
+	if logger == nil {
+		logger = easytls.NewDefaultStructuredLogger()
+	}
+
+	if len(Options.Fields) == 0 {
+		Options.Fields = DefaultAccessLogFields
+	}
+
+	if Options.RequestIDHeader == "" {
+		Options.RequestIDHeader = DefaultRequestIDHeader
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			Start := time.Now()
+
+			r, RequestID := withRequestID(w, r, Options.RequestIDHeader)
+
+			Recorder, Writer := newResponseRecorder(w)
+
+			// The entry is written even if a later handler panics, as a
+			// server error, before the panic continues up the stack.
+			defer func() {
+				Recovered := recover()
+
+				Status := Recorder.Status()
+				if Recovered != nil {
+					Status = http.StatusInternalServerError
+				}
+
+				logAccess(logger, Options, AccessLogEntry{
+					RequestID:  RequestID,
+					RemoteAddr: r.RemoteAddr,
+					User:       requestUser(r),
+					Method:     r.Method,
+					URL:        r.URL.RequestURI(),
+					Proto:      r.Proto,
+					Host:       r.Host,
+					Status:     Status,
+					Bytes:      Recorder.bytes,
+					Duration:   time.Since(Start),
+					Referer:    r.Referer(),
+					UserAgent:  r.UserAgent(),
+					Time:       Start,
+				})
+
+				if Recovered != nil {
+					panic(Recovered)
+				}
+			}()
+
+			next.ServeHTTP(Writer, r)
+		})
+	}
+}
+
+// logAccess will write the entry, unless it is not sampled. Server errors
+// are always written.
+func logAccess(logger easytls.Logger, Options AccessLogOptions, Entry AccessLogEntry) {
+	if Entry.Status < http.StatusInternalServerError && !sampled(Options.SampleRate) {
+		return
+	}
+	writeAccessLog(logger, Options, Entry)
+}
+
+// CommonLogFormat formats the entry as a line in the Common Log Format.
+func (E *AccessLogEntry) CommonLogFormat() string {
+
+	Host, _, err := net.SplitHostPort(E.RemoteAddr)
+	if err != nil {
+		Host = E.RemoteAddr
+	}
+
+	return fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %d",
+		dashIfEmpty(Host),
+		dashIfEmpty(E.User),
+		E.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		E.Method,
+		E.URL,
+		E.Proto,
+		E.Status,
+		E.Bytes,
+	)
+}
+
+// CombinedLogFormat formats the entry as a line in the Combined Log Format.
+func (E *AccessLogEntry) CombinedLogFormat() string {
+	return fmt.Sprintf("%s %s %s", E.CommonLogFormat(), strconv.Quote(E.Referer), strconv.Quote(E.UserAgent))
+}
+
+// field returns the value of the named field of the entry.
+func (E *AccessLogEntry) field(Name string) (interface{}, bool) {
+	switch Name {
+	case AccessFieldRequestID:
+		return E.RequestID, true
+	case AccessFieldRemoteAddr:
+		return E.RemoteAddr, true
+	case AccessFieldUser:
+		return E.User, true
+	case AccessFieldMethod:
+		return E.Method, true
+	case AccessFieldURL:
+		return E.URL, true
+	case AccessFieldProto:
+		return E.Proto, true
+	case AccessFieldHost:
+		return E.Host, true
+	case AccessFieldStatus:
+		return E.Status, true
+	case AccessFieldBytes:
+		return E.Bytes, true
+	case AccessFieldDuration:
+		return E.Duration, true
+	case AccessFieldReferer:
+		return E.Referer, true
+	case AccessFieldUserAgent:
+		return E.UserAgent, true
+	default:
+		return nil, false
+	}
+}
+
+func writeAccessLog(logger easytls.Logger, Options AccessLogOptions, Entry AccessLogEntry) {
+
+	Level := easytls.LevelInfo
+	if Entry.Status >= http.StatusInternalServerError {
+		Level = easytls.LevelError
+	}
+
+	switch Options.Format {
+	case AccessLogCommon:
+		easytls.WriteLine(logger, Level, Entry.CommonLogFormat())
+	case AccessLogCombined:
+		easytls.WriteLine(logger, Level, Entry.CombinedLogFormat())
+	default:
+		Fields := []interface{}{}
+		for _, Name := range Options.Fields {
+			if Value, ok := Entry.field(Name); ok {
+				Fields = append(Fields, Name, Value)
+			}
+		}
+		if Level == easytls.LevelError {
+			logger.Error("Request completed", Fields...)
+		} else {
+			logger.Info("Request completed", Fields...)
+		}
+	}
+}
+
+// requestUser returns the name of the user making the request, either from
+// HTTP Basic Authentication or the verified client certificate.
+func requestUser(r *http.Request) string {
+
+	if User, _, ok := r.BasicAuth(); ok {
+		return User
+	}
+
+	if Identity, ok := PeerIdentityFromRequest(r); ok {
+		return Identity.CommonName
+	}
+
+	return ""
+}
+
+// sampled returns whether a request should be logged, given the SampleRate.
+func sampled(Rate float64) bool {
+
+	if Rate <= 0 || Rate >= 1 {
+		return true
+	}
+
+	const Resolution = 1 << 20
+	N, err := rand.Int(rand.Reader, big.NewInt(Resolution))
+	if err != nil {
+		return true
+	}
+
+	return float64(N.Int64()) < Rate*Resolution
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// responseRecorder wraps a http.ResponseWriter, recording the status code
+// and number of bytes written.
+type responseRecorder struct {
+	http.ResponseWriter
+
+	status   int
+	bytes    int64
+	hijacked bool
+}
+
+// newResponseRecorder will wrap the ResponseWriter in a responseRecorder,
+// returning both the recorder and the ResponseWriter to pass on to later
+// handlers. This implements http.Flusher and http.Hijacker only if w does, so
+// handlers checking for them with a type assertion see the same as without
+// the recorder.
+func newResponseRecorder(w http.ResponseWriter) (*responseRecorder, http.ResponseWriter) {
+
+	R := &responseRecorder{ResponseWriter: w}
+
+	_, Flusher := w.(http.Flusher)
+	_, Hijacker := w.(http.Hijacker)
+
+	switch {
+	case Flusher && Hijacker:
+		return R, &flushHijackRecorder{R}
+	case Flusher:
+		return R, &flushRecorder{R}
+	case Hijacker:
+		return R, &hijackRecorder{R}
+	default:
+		return R, R
+	}
+}
+
+// Status returns the status code written to the response.
+func (R *responseRecorder) Status() int {
+	switch {
+	case R.hijacked:
+		return http.StatusSwitchingProtocols
+	case R.status == 0:
+		return http.StatusOK
+	default:
+		return R.status
+	}
+}
+
+func (R *responseRecorder) WriteHeader(StatusCode int) {
+	if R.status == 0 {
+		R.status = StatusCode
+	}
+	R.ResponseWriter.WriteHeader(StatusCode)
+}
+
+func (R *responseRecorder) Write(b []byte) (int, error) {
+	if R.status == 0 {
+		R.status = http.StatusOK
+	}
+	n, err := R.ResponseWriter.Write(b)
+	R.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap returns the underlying ResponseWriter, for use by http.ResponseController.
+func (R *responseRecorder) Unwrap() http.ResponseWriter {
+	return R.ResponseWriter
+}
+
+func (R *responseRecorder) flush() {
+	if R.status == 0 {
+		R.status = http.StatusOK
+	}
+	R.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (R *responseRecorder) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	Conn, RW, err := R.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		R.hijacked = true
+	}
+	return Conn, RW, err
+}
+
+// flushRecorder is a responseRecorder around a http.Flusher.
+type flushRecorder struct {
+	*responseRecorder
+}
+
+func (R *flushRecorder) Flush() {
+	R.flush()
+}
+
+// hijackRecorder is a responseRecorder around a http.Hijacker.
+type hijackRecorder struct {
+	*responseRecorder
+}
+
+func (R *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return R.hijack()
+}
+
+// flushHijackRecorder is a responseRecorder around a http.ResponseWriter
+// which is both a http.Flusher and a http.Hijacker.
+type flushHijackRecorder struct {
+	*responseRecorder
+}
+
+func (R *flushHijackRecorder) Flush() {
+	R.flush()
+}
+
+func (R *flushHijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return R.hijack()
+}
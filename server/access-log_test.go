@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+)
+
+func TestMiddlewareAccessLog(T *testing.T) {
+
+	Handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); !ok {
+			T.Errorf("Expected the wrapped ResponseWriter to implement http.Flusher")
+		}
+		if ID, ok := RequestIDFromContext(r.Context()); !ok || ID != "abc123" {
+			T.Errorf("Expected request ID [ abc123 ] in the request context, got [ %s ]", ID)
+		}
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	})
+
+	for _, Case := range []struct {
+		Name     string
+		Options  AccessLogOptions
+		Expected *regexp.Regexp
+	}{
+		{
+			"Structured",
+			AccessLogOptions{Fields: []string{AccessFieldRequestID, AccessFieldMethod, AccessFieldStatus, AccessFieldBytes}},
+			regexp.MustCompile(`^INFO Request completed request_id=abc123 method=POST status=418 bytes=15\n$`),
+		},
+		{
+			"Combined Log Format",
+			AccessLogOptions{Format: AccessLogCombined},
+			regexp.MustCompile(`^192\.0\.2\.1 - - \[.+\] "POST /teapot\?brew=1 HTTP/1\.1" 418 15 "" "tester"\n$`),
+		},
+	} {
+		Output := &bytes.Buffer{}
+		Logger := easytls.NewStdLogger(log.New(Output, "", 0), easytls.LevelInfo)
+
+		r := httptest.NewRequest(http.MethodPost, "/teapot?brew=1", nil)
+		r.Header.Set(DefaultRequestIDHeader, "abc123")
+		r.Header.Set("User-Agent", "tester")
+
+		w := httptest.NewRecorder()
+		MiddlewareAccessLog(Logger, Case.Options)(Handler).ServeHTTP(w, r)
+
+		if !Case.Expected.MatchString(Output.String()) {
+			T.Errorf("[ %s ] Unexpected access log [ %s ]", Case.Name, Output.String())
+		}
+		if w.Header().Get(DefaultRequestIDHeader) != "abc123" {
+			T.Errorf("[ %s ] Expected the request ID to be echoed in the response", Case.Name)
+		}
+	}
+}
+
+func TestMiddlewareAccessLogGeneratesRequestID(T *testing.T) {
+
+	w := httptest.NewRecorder()
+	MiddlewareAccessLog(easytls.NewDiscardLogger(), AccessLogOptions{})(http.NotFoundHandler()).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if ID := w.Header().Get(DefaultRequestIDHeader); len(ID) != 32 || strings.Trim(ID, "0123456789abcdef") != "" {
+		T.Fatalf("Expected a generated request ID, got [ %s ]", ID)
+	}
+}
+
+func TestMiddlewareAccessLogPanic(T *testing.T) {
+
+	Output := &bytes.Buffer{}
+	Logger := easytls.NewStdLogger(log.New(Output, "", 0), easytls.LevelInfo)
+
+	Handler := MiddlewareAccessLog(Logger, AccessLogOptions{Fields: []string{AccessFieldStatus}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("handler failed")
+	}))
+
+	func() {
+		defer func() {
+			if Recovered := recover(); Recovered != "handler failed" {
+				T.Errorf("Expected the panic to continue past the access log, got [ %v ]", Recovered)
+			}
+		}()
+		Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+
+	if Expected := "ERROR Request completed status=500\n"; Output.String() != Expected {
+		T.Fatalf("Expected access log [ %s ], got [ %s ]", Expected, Output.String())
+	}
+}
+
+// plainWriter is a http.ResponseWriter which is neither a http.Flusher nor a http.Hijacker.
+type plainWriter struct {
+	http.ResponseWriter
+}
+
+func TestResponseRecorderInterfaces(T *testing.T) {
+
+	_, Writer := newResponseRecorder(plainWriter{httptest.NewRecorder()})
+	if _, ok := Writer.(http.Flusher); ok {
+		T.Fatalf("Expected no http.Flusher around a ResponseWriter which is not one")
+	}
+	if _, ok := Writer.(http.Hijacker); ok {
+		T.Fatalf("Expected no http.Hijacker around a ResponseWriter which is not one")
+	}
+
+	Recorder, Writer := newResponseRecorder(httptest.NewRecorder())
+	if _, ok := Writer.(http.Hijacker); ok {
+		T.Fatalf("Expected no http.Hijacker around a ResponseWriter which is not one")
+	}
+	if err := http.NewResponseController(Writer).Flush(); err != nil {
+		T.Fatalf("Expected the response to be flushed - %s", err)
+	}
+	if Recorder.Status() != http.StatusOK {
+		T.Fatalf("Expected status %d after a flush, got %d", http.StatusOK, Recorder.Status())
+	}
+}
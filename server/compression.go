@@ -0,0 +1,548 @@
+package server
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultCompressionMinSize is the smallest response body compressed, if no
+// other size is given. Smaller bodies rarely benefit from compression.
+const DefaultCompressionMinSize = 1024
+
+// DefaultMaxDecompressedSize is the largest a compressed request body may be
+// once decompressed, if no other size is given.
+const DefaultMaxDecompressedSize = 32 << 20
+
+// DefaultSkipContentTypes are the media types, or prefixes of them, which
+// are already compressed and so are not compressed again, if no others are given.
+var DefaultSkipContentTypes = []string{
+	"image/jpeg",
+	"image/png",
+	"image/gif",
+	"image/webp",
+	"image/avif",
+	"video/",
+	"audio/",
+	"font/woff",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/zstd",
+	"application/x-bzip2",
+	"application/x-xz",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+}
+
+// CompressionCodec implements a single HTTP content-coding. Additional codings,
+// such as zstd or brotli, can be supported by implementing this interface
+// around the corresponding library. If the writers returned have a
+// Flush() error method, it is used to support streaming responses.
+type CompressionCodec interface {
+
+	// Encoding returns the name of the content-coding, such as "gzip".
+	Encoding() string
+
+	// NewWriter returns a writer compressing to w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+
+	// NewReader returns a reader decompressing from r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+type gzipCodec struct{ level int }
+
+// GzipCodec returns the CompressionCodec for the "gzip" content-coding, at the
+// given compression level. A level of 0 uses the default level.
+func GzipCodec(Level int) CompressionCodec {
+	if Level == 0 {
+		Level = gzip.DefaultCompression
+	}
+	return gzipCodec{level: Level}
+}
+
+func (C gzipCodec) Encoding() string { return "gzip" }
+
+func (C gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, C.level)
+}
+
+func (C gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+type deflateCodec struct{ level int }
+
+// DeflateCodec returns the CompressionCodec for the "deflate" content-coding,
+// which HTTP defines as the zlib format, at the given compression level. A
+// level of 0 uses the default level.
+func DeflateCodec(Level int) CompressionCodec {
+	if Level == 0 {
+		Level = zlib.DefaultCompression
+	}
+	return deflateCodec{level: Level}
+}
+
+func (C deflateCodec) Encoding() string { return "deflate" }
+
+func (C deflateCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriterLevel(w, C.level)
+}
+
+func (C deflateCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
+// CompressionOptions configures the middleware created by MiddlewareCompression.
+type CompressionOptions struct {
+
+	// Codecs are the content-codings supported, in order of preference.
+	// Defaults to gzip, then deflate, if not set.
+	Codecs []CompressionCodec
+
+	// MinSize is the smallest response body compressed. Defaults to
+	// DefaultCompressionMinSize if 0, while a negative value compresses
+	// every response.
+	MinSize int
+
+	// SkipContentTypes are the media types, or prefixes of them, which are
+	// never compressed. Defaults to DefaultSkipContentTypes if not set.
+	SkipContentTypes []string
+
+	// MaxDecompressedSize limits the size of compressed request bodies once
+	// decompressed, to protect against decompression bombs. Requests which
+	// exceed it fail to read their body. Defaults to
+	// DefaultMaxDecompressedSize if 0, while a negative value sets no limit.
+	MaxDecompressedSize int64
+}
+
+// MiddlewareCompression provides a middleware which compresses response
+// bodies with the content-coding negotiated from the Accept-Encoding header
+// of the request, and decompresses request bodies sent with a
+// Content-Encoding. Requests with an unsupported Content-Encoding are
+// rejected with a 415 status, and those with a body which cannot be decoded
+// with a 400 status.
+//
+// Responses which are already encoded, are partial, have an already
+// compressed Content-Type, or are smaller than MinSize are sent unchanged.
+// Streaming responses are supported through http.Flusher. The
+// http.ResponseWriter given to later handlers supports http.Flusher and
+// http.Hijacker exactly when the underlying one does.
+func MiddlewareCompression(Options CompressionOptions) MiddlewareHandler {
+
+	if len(Options.Codecs) == 0 {
+		Options.Codecs = []CompressionCodec{GzipCodec(0), DeflateCodec(0)}
+	}
+
+	if Options.MinSize == 0 {
+		Options.MinSize = DefaultCompressionMinSize
+	}
+
+	if Options.SkipContentTypes == nil {
+		Options.SkipContentTypes = DefaultSkipContentTypes
+	}
+
+	if Options.MaxDecompressedSize == 0 {
+		Options.MaxDecompressedSize = DefaultMaxDecompressedSize
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			if StatusCode, err := decodeRequestBody(w, r, Options); err != nil {
+				w.WriteHeader(StatusCode)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// The response differs by Accept-Encoding, whether or not this
+			// particular one is compressed.
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			Codec := negotiateEncoding(r.Header.Get("Accept-Encoding"), Options.Codecs)
+			if Codec == nil || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			Compressor, Writer := newCompressWriter(w, Codec, &Options)
+			defer Compressor.close()
+
+			next.ServeHTTP(Writer, r)
+		})
+	}
+}
+
+// decodeRequestBody replaces the body of the request with one decoding each
+// of its content-codings, in reverse of the order they were applied. If the
+// body cannot be decoded, the status code to reject the request with is
+// returned along with the error.
+func decodeRequestBody(w http.ResponseWriter, r *http.Request, Options CompressionOptions) (int, error) {
+
+	Encoding := r.Header.Get("Content-Encoding")
+	if Encoding == "" || r.Body == nil || r.Body == http.NoBody {
+		return 0, nil
+	}
+
+	Codings := strings.Split(Encoding, ",")
+	Body := r.Body
+
+	for i := len(Codings) - 1; i >= 0; i-- {
+
+		Name := strings.ToLower(strings.TrimSpace(Codings[i]))
+		if Name == "identity" || Name == "" {
+			continue
+		}
+
+		Codec := findCodec(Name, Options.Codecs)
+		if Codec == nil {
+			return http.StatusUnsupportedMediaType, errors.New("server error: Unsupported Content-Encoding [ " + Name + " ]")
+		}
+
+		Decoded, err := Codec.NewReader(Body)
+		if err != nil {
+			return http.StatusBadRequest, errors.New("server error: Invalid " + Name + " request body")
+		}
+		Body = &decodedBody{Reader: Decoded, decoder: Decoded, source: Body}
+	}
+
+	if Options.MaxDecompressedSize > 0 {
+		Body = http.MaxBytesReader(w, Body, Options.MaxDecompressedSize)
+	}
+
+	r.Body = Body
+	r.ContentLength = -1
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+
+	return 0, nil
+}
+
+// decodedBody is a decompressed request body, closing both the decompressor
+// and the original body.
+type decodedBody struct {
+	io.Reader
+	decoder io.Closer
+	source  io.Closer
+}
+
+func (B *decodedBody) Close() error {
+	B.decoder.Close()
+	return B.source.Close()
+}
+
+func findCodec(Name string, Codecs []CompressionCodec) CompressionCodec {
+	if Name == "x-gzip" {
+		Name = "gzip"
+	}
+	for _, C := range Codecs {
+		if C.Encoding() == Name {
+			return C
+		}
+	}
+	return nil
+}
+
+// negotiateEncoding returns the codec with the highest quality value in the
+// Accept-Encoding header, preferring earlier codecs on ties, or nil if none
+// are acceptable.
+func negotiateEncoding(Header string, Codecs []CompressionCodec) CompressionCodec {
+
+	if Header == "" {
+		return nil
+	}
+
+	Qualities := make(map[string]float64)
+	Wildcard := -1.0
+
+	for _, Part := range strings.Split(Header, ",") {
+
+		Name, Params, _ := strings.Cut(strings.TrimSpace(Part), ";")
+		Name = strings.ToLower(strings.TrimSpace(Name))
+		if Name == "x-gzip" {
+			Name = "gzip"
+		}
+
+		Q := 1.0
+		for _, Param := range strings.Split(Params, ";") {
+			Key, Value, _ := strings.Cut(strings.TrimSpace(Param), "=")
+			if strings.EqualFold(Key, "q") {
+				if V, err := strconv.ParseFloat(Value, 64); err == nil {
+					Q = V
+				}
+			}
+		}
+
+		if Name == "*" {
+			Wildcard = Q
+		} else {
+			Qualities[Name] = Q
+		}
+	}
+
+	var Best CompressionCodec
+	BestQ := 0.0
+
+	for _, C := range Codecs {
+		Q, ok := Qualities[C.Encoding()]
+		if !ok {
+			if Wildcard < 0 {
+				continue
+			}
+			Q = Wildcard
+		}
+		if Q > BestQ {
+			Best, BestQ = C, Q
+		}
+	}
+
+	return Best
+}
+
+// compressWriter buffers the start of a response, until it can decide
+// whether to compress it.
+type compressWriter struct {
+	http.ResponseWriter
+
+	codec   CompressionCodec
+	options *CompressionOptions
+
+	status  int
+	buf     []byte
+	decided bool
+	encoder io.WriteCloser
+}
+
+// newCompressWriter will wrap the ResponseWriter in a compressWriter,
+// returning both the compressWriter and the ResponseWriter to pass on to
+// later handlers. As with newResponseRecorder, this implements http.Flusher
+// and http.Hijacker only if w does.
+func newCompressWriter(w http.ResponseWriter, Codec CompressionCodec, Options *CompressionOptions) (*compressWriter, http.ResponseWriter) {
+
+	W := &compressWriter{ResponseWriter: w, codec: Codec, options: Options}
+
+	_, Flusher := w.(http.Flusher)
+	_, Hijacker := w.(http.Hijacker)
+
+	switch {
+	case Flusher && Hijacker:
+		return W, &flushHijackCompressWriter{W}
+	case Flusher:
+		return W, &flushCompressWriter{W}
+	case Hijacker:
+		return W, &hijackCompressWriter{W}
+	default:
+		return W, W
+	}
+}
+
+func (W *compressWriter) WriteHeader(StatusCode int) {
+
+	// Informational responses are sent immediately, and don't end the headers.
+	if StatusCode >= 100 && StatusCode < 200 && StatusCode != http.StatusSwitchingProtocols {
+		W.ResponseWriter.WriteHeader(StatusCode)
+		return
+	}
+
+	if W.status == 0 {
+		W.status = StatusCode
+	}
+}
+
+func (W *compressWriter) Write(b []byte) (int, error) {
+
+	if W.status == 0 {
+		W.status = http.StatusOK
+	}
+
+	if !W.decided {
+		W.buf = append(W.buf, b...)
+		if len(W.buf) < W.options.MinSize {
+			return len(b), nil
+		}
+		if err := W.decide(false); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if W.encoder != nil {
+		return W.encoder.Write(b)
+	}
+
+	return W.ResponseWriter.Write(b)
+}
+
+// decide determines whether the response is compressed, writes the headers,
+// and flushes the buffered start of the body. Final indicates the whole body
+// has been buffered.
+func (W *compressWriter) decide(Final bool) error {
+
+	W.decided = true
+
+	if W.status == 0 {
+		W.status = http.StatusOK
+	}
+
+	H := W.Header()
+
+	// Sniff the type as the server would have, before it is hidden by the
+	// compression, so the skipped types apply to it too.
+	if _, ok := H["Content-Type"]; !ok && len(W.buf) > 0 && H.Get("Content-Encoding") == "" {
+		H.Set("Content-Type", http.DetectContentType(W.buf))
+	}
+
+	if W.compressible(Final) {
+		Encoder, err := W.codec.NewWriter(W.ResponseWriter)
+		if err == nil {
+			W.encoder = Encoder
+			H.Set("Content-Encoding", W.codec.Encoding())
+			H.Del("Content-Length")
+
+			// The compressed representation is not byte-for-byte identical.
+			if ETag := H.Get("ETag"); ETag != "" && !strings.HasPrefix(ETag, "W/") {
+				H.Set("ETag", "W/"+ETag)
+			}
+		}
+	}
+
+	W.ResponseWriter.WriteHeader(W.status)
+
+	Buffered := W.buf
+	W.buf = nil
+
+	if len(Buffered) == 0 {
+		return nil
+	}
+
+	var err error
+	if W.encoder != nil {
+		_, err = W.encoder.Write(Buffered)
+	} else {
+		_, err = W.ResponseWriter.Write(Buffered)
+	}
+
+	return err
+}
+
+func (W *compressWriter) compressible(Final bool) bool {
+
+	switch {
+	case W.status < http.StatusOK,
+		W.status == http.StatusNoContent,
+		W.status == http.StatusNotModified,
+		W.status == http.StatusPartialContent:
+		return false
+	}
+
+	H := W.Header()
+
+	if H.Get("Content-Encoding") != "" || H.Get("Content-Range") != "" {
+		return false
+	}
+
+	if Final && len(W.buf) < W.options.MinSize {
+		return false
+	}
+
+	if Length, err := strconv.Atoi(H.Get("Content-Length")); err == nil && Length < W.options.MinSize {
+		return false
+	}
+
+	if MediaType, _, err := mime.ParseMediaType(H.Get("Content-Type")); err == nil {
+		for _, Skip := range W.options.SkipContentTypes {
+			if strings.HasPrefix(MediaType, Skip) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// close completes the response once the handler has returned.
+func (W *compressWriter) close() error {
+
+	if !W.decided {
+		if W.status == 0 && len(W.buf) == 0 {
+			// Nothing was written, so leave the response to the server.
+			return nil
+		}
+		if err := W.decide(true); err != nil {
+			return err
+		}
+	}
+
+	if W.encoder != nil {
+		return W.encoder.Close()
+	}
+
+	return nil
+}
+
+// flush sends everything written so far. A response is compressed
+// regardless of its size once flushed, as it is likely to be streamed.
+func (W *compressWriter) flush() {
+
+	if !W.decided {
+		if W.decide(false) != nil {
+			return
+		}
+	}
+
+	if F, ok := W.encoder.(interface{ Flush() error }); ok {
+		F.Flush()
+	}
+
+	W.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (W *compressWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	W.decided = true
+	return W.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// Unwrap returns the underlying ResponseWriter, for use by http.ResponseController.
+func (W *compressWriter) Unwrap() http.ResponseWriter {
+	return W.ResponseWriter
+}
+
+// flushCompressWriter is a compressWriter around a http.Flusher.
+type flushCompressWriter struct {
+	*compressWriter
+}
+
+func (W *flushCompressWriter) Flush() {
+	W.flush()
+}
+
+// hijackCompressWriter is a compressWriter around a http.Hijacker.
+type hijackCompressWriter struct {
+	*compressWriter
+}
+
+func (W *hijackCompressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return W.hijack()
+}
+
+// flushHijackCompressWriter is a compressWriter around a http.ResponseWriter
+// which is both a http.Flusher and a http.Hijacker.
+type flushHijackCompressWriter struct {
+	*compressWriter
+}
+
+func (W *flushHijackCompressWriter) Flush() {
+	W.flush()
+}
+
+func (W *flushHijackCompressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return W.hijack()
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressionNegotiation(T *testing.T) {
+
+	Codecs := []CompressionCodec{GzipCodec(0), DeflateCodec(0)}
+
+	Cases := map[string]string{
+		"":                        "",
+		"gzip":                    "gzip",
+		"deflate, gzip":           "gzip",
+		"gzip;q=0.5, deflate":     "deflate",
+		"gzip;q=0, deflate;q=0":   "",
+		"br, *;q=0.1":             "gzip",
+		"identity":                "",
+		"x-gzip":                  "gzip",
+		"GZIP;q=0.2, deflate;q=0": "gzip",
+	}
+
+	for Header, Expected := range Cases {
+		Codec := negotiateEncoding(Header, Codecs)
+		Got := ""
+		if Codec != nil {
+			Got = Codec.Encoding()
+		}
+		if Got != Expected {
+			T.Fatalf("Accept-Encoding [ %s ]: expected [ %s ], got [ %s ]", Header, Expected, Got)
+		}
+	}
+}
+
+func TestMiddlewareCompression(T *testing.T) {
+
+	Body := strings.Repeat("compressible ", 200)
+
+	Serve := func(Handler http.HandlerFunc, Accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if Accept != "" {
+			r.Header.Set("Accept-Encoding", Accept)
+		}
+		w := httptest.NewRecorder()
+		MiddlewareCompression(CompressionOptions{})(Handler).ServeHTTP(w, r)
+		return w
+	}
+
+	Text := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "2600")
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, Body)
+	}
+
+	w := Serve(Text, "gzip")
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Content-Length") != "" {
+		T.Fatalf("Expected a gzip response without a Content-Length, got %v", w.Header())
+	}
+	if w.Header().Get("Vary") != "Accept-Encoding" || w.Header().Get("ETag") != `W/"v1"` {
+		T.Fatalf("Expected Vary to be set and the ETag weakened, got %v", w.Header())
+	}
+
+	Reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		T.Fatalf("Failed to read gzip response: %v", err)
+	}
+	if Decoded, _ := io.ReadAll(Reader); string(Decoded) != Body {
+		T.Fatalf("Decompressed body does not match the original")
+	}
+
+	if w := Serve(Text, ""); w.Header().Get("Content-Encoding") != "" || w.Body.String() != Body {
+		T.Fatalf("Expected an uncompressed response without Accept-Encoding")
+	}
+
+	Small := func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "small") }
+	if w := Serve(Small, "gzip"); w.Header().Get("Content-Encoding") != "" || w.Body.String() != "small" {
+		T.Fatalf("Expected small responses to be sent uncompressed")
+	}
+
+	Image := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		io.WriteString(w, Body)
+	}
+	if w := Serve(Image, "gzip"); w.Header().Get("Content-Encoding") != "" {
+		T.Fatalf("Expected already compressed content types to be skipped")
+	}
+
+	// Without a Content-Type, the sniffed type is skipped as well.
+	PNG := "\x89PNG\x0D\x0A\x1A\x0A" + Body
+	Sniffed := func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, PNG) }
+	if w := Serve(Sniffed, "gzip"); w.Header().Get("Content-Encoding") != "" || w.Header().Get("Content-Type") != "image/png" || w.Body.String() != PNG {
+		T.Fatalf("Expected a sniffed, already compressed content type to be skipped, got %v", w.Header())
+	}
+
+	NotModified := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotModified) }
+	if w := Serve(NotModified, "gzip"); w.Code != http.StatusNotModified || w.Header().Get("Content-Encoding") != "" {
+		T.Fatalf("Expected 304 responses to be sent unchanged, got %d %v", w.Code, w.Header())
+	}
+
+	Streamed := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "event")
+		w.(http.Flusher).Flush()
+	}
+	if w := Serve(Streamed, "gzip"); w.Header().Get("Content-Encoding") != "gzip" || !w.Flushed {
+		T.Fatalf("Expected flushed responses to be compressed and flushed")
+	}
+}
+
+func TestMiddlewareCompressionRequestBody(T *testing.T) {
+
+	Echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	})
+	Handler := MiddlewareCompression(CompressionOptions{MaxDecompressedSize: 64})(Echo)
+
+	Compress := func(Body string) *bytes.Buffer {
+		B := &bytes.Buffer{}
+		Writer := gzip.NewWriter(B)
+		io.WriteString(Writer, Body)
+		Writer.Close()
+		return B
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/", Compress("hello"))
+	r.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	Handler.ServeHTTP(w, r)
+	if w.Body.String() != "hello" {
+		T.Fatalf("Expected the request body to be decompressed, got [ %s ]", w.Body.String())
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/", Compress(strings.Repeat("a", 1024)))
+	r.Header.Set("Content-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	Handler.ServeHTTP(w, r)
+	if w.Body.Len() > 64 {
+		T.Fatalf("Expected the decompressed body to be limited, read %d bytes", w.Body.Len())
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("data"))
+	r.Header.Set("Content-Encoding", "compress")
+	w = httptest.NewRecorder()
+	Handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnsupportedMediaType {
+		T.Fatalf("Expected status %d for an unknown encoding, got %d", http.StatusUnsupportedMediaType, w.Code)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("not gzip"))
+	r.Header.Set("Content-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	Handler.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		T.Fatalf("Expected status %d for a malformed body, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestCompressWriterInterfaces(T *testing.T) {
+
+	Options := &CompressionOptions{MinSize: DefaultCompressionMinSize}
+
+	_, Writer := newCompressWriter(plainWriter{httptest.NewRecorder()}, GzipCodec(0), Options)
+	if _, ok := Writer.(http.Flusher); ok {
+		T.Fatalf("Expected no http.Flusher around a ResponseWriter which is not one")
+	}
+	if _, ok := Writer.(http.Hijacker); ok {
+		T.Fatalf("Expected no http.Hijacker around a ResponseWriter which is not one")
+	}
+
+	w := httptest.NewRecorder()
+	Compressor, Writer := newCompressWriter(w, GzipCodec(0), Options)
+	if _, ok := Writer.(http.Hijacker); ok {
+		T.Fatalf("Expected no http.Hijacker around a ResponseWriter which is not one")
+	}
+	io.WriteString(Writer, "streamed")
+	if err := http.NewResponseController(Writer).Flush(); err != nil {
+		T.Fatalf("Expected the response to be flushed - %s", err)
+	}
+	if !w.Flushed || w.Header().Get("Content-Encoding") != "gzip" {
+		T.Fatalf("Expected the flushed response to be compressed")
+	}
+	Compressor.close()
+}
+
+func TestMiddlewareCompressionDefaultLimit(T *testing.T) {
+
+	Read := make(chan int64, 1)
+	Handler := MiddlewareCompression(CompressionOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		N, _ := io.Copy(io.Discard, r.Body)
+		Read <- N
+	}))
+
+	// A small request which decompresses to more than the default limit.
+	B := &bytes.Buffer{}
+	Writer := gzip.NewWriter(B)
+	io.CopyN(Writer, zeroReader{}, DefaultMaxDecompressedSize+1)
+	Writer.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/", B)
+	r.Header.Set("Content-Encoding", "gzip")
+	Handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if N := <-Read; N > DefaultMaxDecompressedSize {
+		T.Fatalf("Expected the decompressed body to be limited by default, read %d bytes", N)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 0
+	}
+	return len(b), nil
+}
@@ -0,0 +1,332 @@
+package server
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// CORSPolicy defines which cross-origin requests are allowed, and what the
+// browser is permitted to do with the responses to them.
+type CORSPolicy struct {
+
+	// AllowedOrigins are the origins allowed to make requests, either exact
+	// origins such as "https://example.com", wildcards such as
+	// "https://*.example.com", or "*" to allow any origin.
+	AllowedOrigins []string `json:",omitempty"`
+
+	// AllowedOriginPatterns are regular expressions, matched against the
+	// full origin, allowing any origin which matches one of them.
+	AllowedOriginPatterns []*regexp.Regexp `json:"-"`
+
+	// AllowedMethods are the methods allowed in preflight requests.
+	// Defaults to the methods of the matched route if not set.
+	AllowedMethods []string `json:",omitempty"`
+
+	// AllowedHeaders are the request headers allowed in preflight requests,
+	// or "*" to allow any header. Only CORS-safelisted headers are allowed if
+	// not set.
+	AllowedHeaders []string `json:",omitempty"`
+
+	// ExposedHeaders are the response headers the browser exposes to the
+	// calling script, beyond the CORS-safelisted ones.
+	ExposedHeaders []string `json:",omitempty"`
+
+	// AllowCredentials allows requests to include cookies and client
+	// certificates, and their responses to be read. This only applies to
+	// origins allowed by name or pattern; origins allowed only by "*" are
+	// always answered with a literal "*" and no credentials, so any site
+	// cannot read responses with the credentials of the user.
+	AllowCredentials bool `json:",omitempty"`
+
+	// MaxAge is how long the browser may cache the result of a preflight
+	// request. The browser default is used if not set.
+	MaxAge time.Duration `json:",omitempty"`
+}
+
+// corsPolicy is a CORSPolicy prepared for matching requests against.
+type corsPolicy struct {
+	anyOrigin  bool
+	origins    map[string]bool
+	patterns   []*regexp.Regexp
+	methods    map[string]bool
+	anyHeader  bool
+	headers    map[string]bool
+	exposed    string
+	credential bool
+	maxAge     string
+}
+
+func newCORSPolicy(Policy CORSPolicy) *corsPolicy {
+
+	P := &corsPolicy{
+		origins:    make(map[string]bool),
+		patterns:   append([]*regexp.Regexp{}, Policy.AllowedOriginPatterns...),
+		methods:    make(map[string]bool),
+		headers:    make(map[string]bool),
+		exposed:    strings.Join(Policy.ExposedHeaders, ", "),
+		credential: Policy.AllowCredentials,
+	}
+
+	for _, Origin := range Policy.AllowedOrigins {
+		switch {
+		case Origin == "*":
+			P.anyOrigin = true
+		case strings.Contains(Origin, "*"):
+			Pattern := strings.ReplaceAll(regexp.QuoteMeta(strings.ToLower(Origin)), `\*`, `[^/]+`)
+			P.patterns = append(P.patterns, regexp.MustCompile("^"+Pattern+"$"))
+		default:
+			P.origins[strings.ToLower(Origin)] = true
+		}
+	}
+
+	for _, Method := range Policy.AllowedMethods {
+		P.methods[strings.ToUpper(Method)] = true
+	}
+
+	for _, Header := range Policy.AllowedHeaders {
+		if Header == "*" {
+			P.anyHeader = true
+		}
+		P.headers[http.CanonicalHeaderKey(Header)] = true
+	}
+
+	if Policy.MaxAge > 0 {
+		P.maxAge = strconv.Itoa(int(Policy.MaxAge.Seconds()))
+	}
+
+	return P
+}
+
+// SetCORS will set the server-wide CORS policy, applied to every route
+// without a policy of its own. Preflight requests are answered before
+// routing, so they succeed even on routes which do not register OPTIONS.
+func (S *SimpleServer) SetCORS(Policy CORSPolicy) {
+	S.cors.Store(newCORSPolicy(Policy))
+}
+
+// SetCORS will set the CORS policy of the handler, overriding the
+// server-wide policy.
+func (H *SimpleHandler) SetCORS(Policy CORSPolicy) {
+	H.CORS = &Policy
+}
+
+// MiddlewareCORS provides a middleware applying the CORS policy to every
+// request. Preflight requests only reach this middleware on routes which
+// match the OPTIONS method; use SimpleServer.SetCORS to answer preflight
+// requests for any route.
+func MiddlewareCORS(Policy CORSPolicy) MiddlewareHandler {
+
+	P := newCORSPolicy(Policy)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			P.serve(w, r, next, nil)
+		})
+	}
+}
+
+// corsHandler wraps the router of the server, answering preflight requests
+// and applying the CORS policy of the matched route, or of the server.
+type corsHandler struct {
+	server *SimpleServer
+	next   http.Handler
+}
+
+func (C *corsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	// Same-origin and non-browser requests are unaffected, as are all
+	// requests if no policy has been set.
+	if r.Header.Get("Origin") == "" || (C.server.cors.Load() == nil && !C.server.corsRoutes.Load()) {
+		C.next.ServeHTTP(w, r)
+		return
+	}
+
+	Method := r.Method
+	if isPreflight(r) {
+		Method = r.Header.Get("Access-Control-Request-Method")
+	}
+
+	Policy, Methods := C.server.corsPolicyFor(r, Method)
+	if Policy == nil {
+		C.next.ServeHTTP(w, r)
+		return
+	}
+
+	Policy.serve(w, r, C.next, Methods)
+}
+
+// corsPolicyFor returns the CORS policy which applies to the request if it
+// were made with the given method, along with the methods of the matched route.
+func (S *SimpleServer) corsPolicyFor(r *http.Request, Method string) (*corsPolicy, []string) {
+
+	Probe := r.Clone(r.Context())
+	Probe.Method = Method
+
+	Match := &mux.RouteMatch{}
+	if !S.router.Match(Probe, Match) || Match.MatchErr != nil {
+		return nil, nil
+	}
+
+	for _, H := range S.Routes() {
+		if H.muxRoute == Match.Route {
+			if H.cors != nil {
+				return H.cors, H.route.Methods
+			}
+			return S.cors.Load(), H.route.Methods
+		}
+	}
+
+	return S.cors.Load(), nil
+}
+
+// serve applies the policy to the request, answering it directly if it is a
+// preflight request.
+func (P *corsPolicy) serve(w http.ResponseWriter, r *http.Request, next http.Handler, RouteMethods []string) {
+
+	Origin := r.Header.Get("Origin")
+	H := w.Header()
+	H.Add("Vary", "Origin")
+
+	if !isPreflight(r) {
+		if Origin != "" && P.allowOrigin(Origin) {
+			P.writeCommon(H, Origin)
+			if P.exposed != "" {
+				H.Set("Access-Control-Expose-Headers", P.exposed)
+			}
+		}
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	H.Add("Vary", "Access-Control-Request-Method")
+	H.Add("Vary", "Access-Control-Request-Headers")
+
+	Method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	Headers := requestedHeaders(r)
+
+	if !P.allowOrigin(Origin) || !P.allowMethod(Method, RouteMethods) || !P.allowHeaders(Headers) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	P.writeCommon(H, Origin)
+	H.Set("Access-Control-Allow-Methods", Method)
+	if len(Headers) > 0 {
+		H.Set("Access-Control-Allow-Headers", strings.Join(Headers, ", "))
+	}
+	if P.maxAge != "" {
+		H.Set("Access-Control-Max-Age", P.maxAge)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeCommon writes the headers shared by preflight and actual responses.
+// Origins allowed by name or pattern are echoed, with credentials if allowed,
+// while any other origin gets "*", which browsers never send credentials to.
+func (P *corsPolicy) writeCommon(H http.Header, Origin string) {
+
+	if !P.namedOrigin(Origin) {
+		H.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+
+	H.Set("Access-Control-Allow-Origin", Origin)
+	if P.credential {
+		H.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (P *corsPolicy) allowOrigin(Origin string) bool {
+	return P.anyOrigin || P.namedOrigin(Origin)
+}
+
+// namedOrigin returns whether the origin is allowed by name or pattern,
+// rather than only by "*".
+func (P *corsPolicy) namedOrigin(Origin string) bool {
+
+	Origin = strings.ToLower(Origin)
+	if P.origins[Origin] {
+		return true
+	}
+
+	for _, Pattern := range P.patterns {
+		if Pattern.MatchString(Origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (P *corsPolicy) allowMethod(Method string, RouteMethods []string) bool {
+
+	if Method == "" {
+		return false
+	}
+
+	if len(P.methods) > 0 {
+		return P.methods[Method]
+	}
+
+	// Without an explicit set, allow whatever the matched route allows.
+	if len(RouteMethods) == 0 {
+		return true
+	}
+
+	for _, M := range RouteMethods {
+		if strings.EqualFold(M, Method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (P *corsPolicy) allowHeaders(Headers []string) bool {
+
+	if P.anyHeader {
+		return true
+	}
+
+	for _, Header := range Headers {
+		if !P.headers[Header] && !corsSafelistedHeaders[Header] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// corsSafelistedHeaders are the request headers which are always allowed.
+var corsSafelistedHeaders = map[string]bool{
+	"Accept":           true,
+	"Accept-Language":  true,
+	"Content-Language": true,
+	"Content-Type":     true,
+}
+
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// requestedHeaders returns the canonical names of the headers requested by a
+// preflight request.
+func requestedHeaders(r *http.Request) []string {
+
+	Headers := []string{}
+	for _, Value := range r.Header.Values("Access-Control-Request-Headers") {
+		for _, Header := range strings.Split(Value, ",") {
+			if Header = strings.TrimSpace(Header); Header != "" {
+				Headers = append(Headers, http.CanonicalHeaderKey(Header))
+			}
+		}
+	}
+
+	return Headers
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+)
+
+func TestCORSPreflight(T *testing.T) {
+
+	S := NewServerHTTP()
+	S.SetStructuredLogger(easytls.NewDiscardLogger())
+	S.SetCORS(CORSPolicy{
+		AllowedOrigins:        []string{"https://app.example.com", "https://*.example.org"},
+		AllowedOriginPatterns: []*regexp.Regexp{regexp.MustCompile(`^http://localhost:\d+$`)},
+		AllowedHeaders:        []string{"Authorization"},
+		AllowCredentials:      true,
+		MaxAge:                time.Minute,
+	})
+
+	Public := NewSimpleHandler(statusHandler(http.StatusOK), "/public", http.MethodPut)
+	Public.SetCORS(CORSPolicy{AllowedOrigins: []string{"*"}})
+
+	S.AddHandlers(S.Router(),
+		NewSimpleHandler(statusHandler(http.StatusOK), "/items", http.MethodGet, http.MethodPost),
+		Public,
+	)
+
+	Preflight := func(Path, Origin, Method, Headers string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodOptions, Path, nil)
+		r.Header.Set("Origin", Origin)
+		r.Header.Set("Access-Control-Request-Method", Method)
+		if Headers != "" {
+			r.Header.Set("Access-Control-Request-Headers", Headers)
+		}
+		w := httptest.NewRecorder()
+		S.Server.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	Cases := []struct {
+		Path, Origin, Method, Headers string
+		Status                        int
+	}{
+		{"/items", "https://app.example.com", http.MethodPost, "authorization", http.StatusNoContent},
+		{"/items", "https://a.example.org", http.MethodGet, "", http.StatusNoContent},
+		{"/items", "http://localhost:3000", http.MethodGet, "", http.StatusNoContent},
+		{"/items", "https://evil.example.com", http.MethodGet, "", http.StatusForbidden},
+		{"/items", "https://app.example.com", http.MethodGet, "X-Custom", http.StatusForbidden},
+		{"/items", "https://app.example.com", http.MethodDelete, "", http.StatusMethodNotAllowed},
+		{"/public", "https://anyone.test", http.MethodPut, "", http.StatusNoContent},
+	}
+
+	for _, C := range Cases {
+		w := Preflight(C.Path, C.Origin, C.Method, C.Headers)
+		if w.Code != C.Status {
+			T.Fatalf("Preflight of %s %s from %s: expected status %d, got %d", C.Method, C.Path, C.Origin, C.Status, w.Code)
+		}
+	}
+
+	w := Preflight("/items", "https://app.example.com", http.MethodPost, "Authorization")
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		w.Header().Get("Access-Control-Allow-Credentials") != "true" ||
+		w.Header().Get("Access-Control-Allow-Headers") != "Authorization" ||
+		w.Header().Get("Access-Control-Max-Age") != "60" {
+		T.Fatalf("Unexpected preflight response headers: %v", w.Header())
+	}
+
+	// The route policy overrides the server-wide one.
+	if w := Preflight("/public", "https://anyone.test", http.MethodPut, ""); w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		T.Fatalf("Expected the route policy to not allow credentials")
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/items", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	w = httptest.NewRecorder()
+	S.Server.Handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		T.Fatalf("Expected the actual request to be served with CORS headers, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestCORSAnyOriginWithCredentials(T *testing.T) {
+
+	S := NewServerHTTP()
+	S.SetStructuredLogger(easytls.NewDiscardLogger())
+	S.SetCORS(CORSPolicy{
+		AllowedOrigins:   []string{"*", "https://app.example.com"},
+		AllowCredentials: true,
+	})
+	S.AddHandlers(S.Router(), NewSimpleHandler(statusHandler(http.StatusOK), "/items", http.MethodGet))
+
+	Request := func(Origin string, Preflight bool) http.Header {
+		r := httptest.NewRequest(http.MethodGet, "/items", nil)
+		if Preflight {
+			r.Method = http.MethodOptions
+			r.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		}
+		r.Header.Set("Origin", Origin)
+		w := httptest.NewRecorder()
+		S.Server.Handler.ServeHTTP(w, r)
+		return w.Header()
+	}
+
+	for _, Preflight := range []bool{false, true} {
+
+		// Any other origin must never be allowed to read credentialed responses.
+		H := Request("https://evil.example.com", Preflight)
+		if H.Get("Access-Control-Allow-Origin") != "*" || H.Get("Access-Control-Allow-Credentials") != "" {
+			T.Fatalf("Expected a literal \"*\" without credentials for an origin allowed by \"*\", got %v", H)
+		}
+
+		// Origins allowed by name still get credentials.
+		H = Request("https://app.example.com", Preflight)
+		if H.Get("Access-Control-Allow-Origin") != "https://app.example.com" || H.Get("Access-Control-Allow-Credentials") != "true" {
+			T.Fatalf("Expected the named origin to be echoed with credentials, got %v", H)
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"strings"
 	"time"
 
+	easytls "github.com/Bearnie-H/easy-tls"
 	"github.com/Bearnie-H/easy-tls/header"
 	"github.com/Bearnie-H/easy-tls/server"
 )
@@ -25,6 +26,15 @@ const ModifiedTimeFormat = time.Stamp
 // HandlerLogger is the reference to the default logger to use for the FileServer handlers
 var HandlerLogger *log.Logger
 
+// handlerLogger returns HandlerLogger as a leveled, structured Logger, which
+// discards everything if there is no HandlerLogger.
+func handlerLogger() easytls.Logger {
+	if HandlerLogger == nil {
+		return easytls.NewDiscardLogger()
+	}
+	return easytls.AsLogger(HandlerLogger)
+}
+
 // fileDetails is the set of file properties returned as headers during GET
 // and HEAD calls. This is only a slightly modified copy of os.FileInfo, to
 // assert HTTP Header encoding as specific types and with a clear naming
@@ -49,7 +59,8 @@ type fileDetails struct {
 //	PATCH:	Append the request body to the existing file on disk.
 //	DELETE:	Delete the file from disk.
 //
-// The server will be based out of the given ServeBase folder.
+// The server will be based out of the given ServeBase folder. If no Logger
+// is given, nothing will be logged.
 func Handlers(URLBase, ServeBase string, ShowHidden bool, Logger *log.Logger) ([]server.SimpleHandler, error) {
 	HandlerLogger = Logger
 
@@ -85,16 +96,30 @@ func Handlers(URLBase, ServeBase string, ShowHidden bool, Logger *log.Logger) ([
 //	}
 //
 // This will write the status code to the response, as well as the result of
-// fmt.Sprintf(Message, args...) to the response, and to the logger
+// fmt.Sprintf(Message, args...) to the response, and to the logger. Server
+// errors are logged at LevelError, client errors at LevelWarn, and all others
+// at LevelInfo.
 func ExitHandler(w http.ResponseWriter, StatusCode int, Message string, err error, args ...interface{}) {
 	w.WriteHeader(StatusCode)
 	w.Write([]byte(html.EscapeString(fmt.Sprintf(Message, args...))))
-	if HandlerLogger != nil {
-		if err == nil {
-			HandlerLogger.Printf(Message, args...)
-		} else {
-			HandlerLogger.Print(fmt.Sprintf(Message, args...) + " - " + err.Error())
-		}
+
+	if HandlerLogger == nil {
+		return
+	}
+
+	Logger := handlerLogger()
+	Fields := []interface{}{"status", StatusCode}
+	if err != nil {
+		Fields = append(Fields, "error", err)
+	}
+
+	switch {
+	case StatusCode >= http.StatusInternalServerError:
+		Logger.Error(fmt.Sprintf(Message, args...), Fields...)
+	case StatusCode >= http.StatusBadRequest:
+		Logger.Warn(fmt.Sprintf(Message, args...), Fields...)
+	default:
+		Logger.Info(fmt.Sprintf(Message, args...), Fields...)
 	}
 }
 
@@ -181,13 +206,13 @@ func Get(URLBase, ServeBase string, ShowHidden bool) server.SimpleHandler {
 					name = fmt.Sprintf("<a href=\"%s%s\">%s</a><br/>\n", html.EscapeString(r.URL.Path), html.EscapeString(name), html.EscapeString(name))
 					w.Write([]byte(name))
 				}
-				HandlerLogger.Printf("Successfully served directory [ %s ]", Filename)
+				handlerLogger().Info("Successfully served directory", "file", Filename)
 			} else {
 				if _, err := io.Copy(w, f); err != nil {
 					ExitHandler(w, http.StatusInternalServerError, "file-server error: Failed to write file [ %s ] to network", err, Filename)
 					return
 				}
-				HandlerLogger.Printf("Successfully served file [ %s ]", Filename)
+				handlerLogger().Info("Successfully served file", "file", Filename)
 			}
 		}),
 	}
@@ -232,7 +257,7 @@ func Head(URLBase, ServeBase string) server.SimpleHandler {
 			}
 
 			header.Merge(&RespHeader, &H)
-			HandlerLogger.Printf("Successfully served HTTP Headers for file [ %s ]", Filename)
+			handlerLogger().Info("Successfully served HTTP Headers for file", "file", Filename)
 			w.WriteHeader(http.StatusOK)
 		}),
 	}
@@ -0,0 +1,298 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultHealthCheckTimeout is how long a single health check may run for,
+// if no explicit timeout is given.
+const DefaultHealthCheckTimeout = time.Second * 5
+
+// The status values reported by health checks.
+const (
+	HealthStatusOK       = "ok"
+	HealthStatusFailed   = "failed"
+	HealthStatusDraining = "draining"
+)
+
+// ErrHealthCheckTimeout indicates a health check did not complete within its timeout.
+var ErrHealthCheckTimeout = errors.New("health check error: Check did not complete before timeout")
+
+// HealthCheckFunc is the function called to perform a single health check.
+// A nil error indicates the check passed.
+type HealthCheckFunc func(ctx context.Context) error
+
+// HealthCheck is a single named check, registered with a HealthChecker.
+type HealthCheck struct {
+
+	// Name uniquely identifies the check. Registering a check with the name
+	// of an existing one replaces it.
+	Name string
+
+	// Check is the function performing the check.
+	Check HealthCheckFunc
+
+	// Timeout is how long Check may run for before the check is failed.
+	// Defaults to DefaultHealthCheckTimeout if not set.
+	Timeout time.Duration
+
+	// CacheFor is how long the result of Check is reused for, to avoid
+	// repeating expensive checks on every request. Defaults to 0, checking
+	// on every request.
+	CacheFor time.Duration
+
+	// Liveness marks the check as indicating whether the process is alive,
+	// rather than only whether it is ready to serve. Liveness checks are
+	// included in all reports, while other checks are excluded from the
+	// liveness report.
+	Liveness bool
+}
+
+// HealthCheckResult is the outcome of a single health check.
+type HealthCheckResult struct {
+	Status   string        `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Cached   bool          `json:"cached,omitempty"`
+	Checked  time.Time     `json:"checked"`
+}
+
+// HealthReport is the aggregated result of a set of health checks.
+type HealthReport struct {
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks"`
+}
+
+// OK returns whether the report indicates a healthy state.
+func (R *HealthReport) OK() bool {
+	return R.Status == HealthStatusOK
+}
+
+// registeredCheck is a HealthCheck, along with its most recent result.
+type registeredCheck struct {
+	HealthCheck
+
+	mu     *sync.Mutex
+	last   HealthCheckResult
+	hasRun bool
+}
+
+// HealthChecker holds the set of health checks registered by the components
+// of a server, and aggregates them into liveness, readiness and overall
+// health reports.
+type HealthChecker struct {
+	mu       *sync.Mutex
+	checks   map[string]*registeredCheck
+	draining bool
+}
+
+// NewHealthChecker will create a new HealthChecker with no checks registered.
+func NewHealthChecker() *HealthChecker {
+	return &HealthChecker{
+		mu:     &sync.Mutex{},
+		checks: make(map[string]*registeredCheck),
+	}
+}
+
+// AddCheck will register the given checks, replacing any existing checks with
+// the same names.
+func (H *HealthChecker) AddCheck(Checks ...HealthCheck) {
+
+	H.mu.Lock()
+	defer H.mu.Unlock()
+
+	for _, Check := range Checks {
+		if Check.Timeout <= 0 {
+			Check.Timeout = DefaultHealthCheckTimeout
+		}
+		H.checks[Check.Name] = &registeredCheck{HealthCheck: Check, mu: &sync.Mutex{}}
+	}
+}
+
+// RemoveCheck will remove the checks with the given names, if they exist.
+func (H *HealthChecker) RemoveCheck(Names ...string) {
+
+	H.mu.Lock()
+	defer H.mu.Unlock()
+
+	for _, Name := range Names {
+		delete(H.checks, Name)
+	}
+}
+
+// SetDraining will mark whether the server is draining, during which it
+// reports as not ready regardless of the result of its checks.
+func (H *HealthChecker) SetDraining(Draining bool) {
+	H.mu.Lock()
+	H.draining = Draining
+	H.mu.Unlock()
+}
+
+// Draining returns whether the server is currently draining.
+func (H *HealthChecker) Draining() bool {
+	H.mu.Lock()
+	defer H.mu.Unlock()
+	return H.draining
+}
+
+// Health will run all of the registered checks.
+func (H *HealthChecker) Health(ctx context.Context) HealthReport {
+	return H.report(ctx, false, false)
+}
+
+// Liveness will run only the registered liveness checks.
+func (H *HealthChecker) Liveness(ctx context.Context) HealthReport {
+	return H.report(ctx, true, false)
+}
+
+// Readiness will run all of the registered checks, additionally failing if
+// the server is draining.
+func (H *HealthChecker) Readiness(ctx context.Context) HealthReport {
+	return H.report(ctx, false, true)
+}
+
+func (H *HealthChecker) report(ctx context.Context, LivenessOnly, Readiness bool) HealthReport {
+
+	H.mu.Lock()
+	Checks := make([]*registeredCheck, 0, len(H.checks))
+	for _, C := range H.checks {
+		if !LivenessOnly || C.Liveness {
+			Checks = append(Checks, C)
+		}
+	}
+	Draining := H.draining
+	H.mu.Unlock()
+
+	sort.Slice(Checks, func(i, j int) bool { return Checks[i].Name < Checks[j].Name })
+
+	Report := HealthReport{
+		Status: HealthStatusOK,
+		Checks: make(map[string]HealthCheckResult, len(Checks)),
+	}
+
+	Results := make([]HealthCheckResult, len(Checks))
+	wg := &sync.WaitGroup{}
+	for i, C := range Checks {
+		wg.Add(1)
+		go func(i int, C *registeredCheck) {
+			defer wg.Done()
+			Results[i] = C.run(ctx)
+		}(i, C)
+	}
+	wg.Wait()
+
+	for i, C := range Checks {
+		Report.Checks[C.Name] = Results[i]
+		if Results[i].Status != HealthStatusOK {
+			Report.Status = HealthStatusFailed
+		}
+	}
+
+	if Readiness && Draining {
+		Report.Status = HealthStatusDraining
+	}
+
+	return Report
+}
+
+// run performs the check, or returns the cached result if it is still fresh.
+func (C *registeredCheck) run(ctx context.Context) HealthCheckResult {
+
+	C.mu.Lock()
+	defer C.mu.Unlock()
+
+	if C.hasRun && C.CacheFor > 0 && time.Since(C.last.Checked) < C.CacheFor {
+		Result := C.last
+		Result.Cached = true
+		return Result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, C.Timeout)
+	defer cancel()
+
+	Start := time.Now()
+	Done := make(chan error, 1)
+	go func() { Done <- C.Check(ctx) }()
+
+	var err error
+	select {
+	case err = <-Done:
+	case <-ctx.Done():
+		err = ErrHealthCheckTimeout
+	}
+
+	Result := HealthCheckResult{
+		Status:   HealthStatusOK,
+		Duration: time.Since(Start),
+		Checked:  Start,
+	}
+	if err != nil {
+		Result.Status = HealthStatusFailed
+		Result.Error = err.Error()
+	}
+
+	C.last = Result
+	C.hasRun = true
+
+	return Result
+}
+
+// HealthHandlers will return the set of SimpleHandlers exposing the reports
+// of the HealthChecker as JSON. A failing report is served with a 503 status.
+//
+//	/healthz:	All registered checks.
+//	/livez:		Only the liveness checks.
+//	/readyz:	All registered checks, failing while the server is draining.
+func HealthHandlers(H *HealthChecker) []SimpleHandler {
+	return []SimpleHandler{
+		{
+			Handler:     healthReportHandler(H.Health),
+			Path:        "/healthz",
+			Methods:     []string{http.MethodGet, http.MethodHead},
+			Description: "Report the result of all registered health checks.",
+		},
+		{
+			Handler:     healthReportHandler(H.Liveness),
+			Path:        "/livez",
+			Methods:     []string{http.MethodGet, http.MethodHead},
+			Description: "Report the result of the registered liveness checks.",
+		},
+		{
+			Handler:     healthReportHandler(H.Readiness),
+			Path:        "/readyz",
+			Methods:     []string{http.MethodGet, http.MethodHead},
+			Description: "Report whether the server is ready to serve requests.",
+		},
+	}
+}
+
+func healthReportHandler(Report func(context.Context) HealthReport) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		R := Report(r.Context())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+
+		if R.OK() {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		if r.Method == http.MethodHead {
+			return
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		enc.SetEscapeHTML(true)
+		enc.Encode(R)
+	})
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthChecker(T *testing.T) {
+
+	H := NewHealthChecker()
+
+	Calls := 0
+	H.AddCheck(
+		HealthCheck{
+			Name:     "cached",
+			Liveness: true,
+			CacheFor: time.Minute,
+			Check: func(context.Context) error {
+				Calls++
+				return nil
+			},
+		},
+		HealthCheck{
+			Name:    "slow",
+			Timeout: time.Millisecond * 10,
+			Check: func(ctx context.Context) error {
+				<-ctx.Done()
+				return errors.New("cancelled")
+			},
+		},
+	)
+
+	Report := H.Health(context.Background())
+	if Report.OK() || Report.Checks["slow"].Error != ErrHealthCheckTimeout.Error() {
+		T.Fatalf("Expected the slow check to time out, got %+v", Report)
+	}
+
+	if Report := H.Liveness(context.Background()); !Report.OK() || len(Report.Checks) != 1 || !Report.Checks["cached"].Cached {
+		T.Fatalf("Expected only the cached liveness check to be reported, got %+v", Report)
+	}
+	if Calls != 1 {
+		T.Fatalf("Expected the cached check to be called once, got %d", Calls)
+	}
+
+	H.RemoveCheck("slow")
+	if Report := H.Readiness(context.Background()); !Report.OK() {
+		T.Fatalf("Expected the server to be ready, got %+v", Report)
+	}
+
+	H.SetDraining(true)
+	if Report := H.Readiness(context.Background()); Report.Status != HealthStatusDraining {
+		T.Fatalf("Expected the server to report draining, got %+v", Report)
+	}
+}
+
+func TestHealthHandlers(T *testing.T) {
+
+	S := NewServerHTTP()
+	S.enableHealthHandlers()
+
+	S.Health().AddCheck(HealthCheck{Name: "database", Check: func(context.Context) error { return errors.New("connection refused") }})
+
+	for _, Case := range []struct {
+		Path     string
+		Expected int
+	}{
+		{"/livez", http.StatusOK},
+		{"/readyz", http.StatusServiceUnavailable},
+		{"/healthz", http.StatusServiceUnavailable},
+	} {
+		w := httptest.NewRecorder()
+		S.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, Case.Path, nil))
+
+		if w.Code != Case.Expected {
+			T.Errorf("[ %s ] Expected status [ %d ], got [ %d ]", Case.Path, Case.Expected, w.Code)
+		}
+
+		Report := HealthReport{}
+		if err := json.NewDecoder(w.Body).Decode(&Report); err != nil {
+			T.Errorf("[ %s ] Failed to decode health report - %s", Case.Path, err)
+		}
+	}
+}
@@ -0,0 +1,506 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EnvInheritedListeners is the environment variable used to pass the
+// listening sockets of a server to the new process started by Restart. It
+// holds a ";" separated list of "FD=Address" pairs.
+const EnvInheritedListeners = "EASYTLS_INHERITED_LISTENERS"
+
+// EnvRestartReady is the environment variable used to pass the new process
+// started by Restart the file descriptor it reports being ready on.
+const EnvRestartReady = "EASYTLS_RESTART_READY"
+
+// The default timeouts of the phases of shutting down a server.
+const (
+	DefaultDrainTimeout   = time.Second * 30
+	DefaultHookTimeout    = time.Second * 10
+	DefaultRestartTimeout = time.Second * 30
+)
+
+// ErrShutdownHookTimeout indicates a shutdown hook did not complete within its timeout.
+var ErrShutdownHookTimeout = errors.New("server error: Shutdown hook did not complete before timeout")
+
+// ShutdownPhase is a single step of shutting down a SimpleServer. The phases
+// are performed in the order they are declared.
+type ShutdownPhase int
+
+const (
+	// PhaseStopAccepting closes the listeners of the server, so no new
+	// connections are accepted.
+	PhaseStopAccepting ShutdownPhase = iota
+
+	// PhaseNotReady marks the server as draining, failing the readiness check.
+	PhaseNotReady
+
+	// PhaseDrain waits for the in-flight requests to complete, closing idle
+	// connections as they finish.
+	PhaseDrain
+
+	// PhaseHooks runs the registered shutdown hooks, in order of registration.
+	PhaseHooks
+
+	// PhaseForceClose closes any connections still open after draining.
+	PhaseForceClose
+)
+
+func (P ShutdownPhase) String() string {
+	switch P {
+	case PhaseStopAccepting:
+		return "stop-accepting"
+	case PhaseNotReady:
+		return "not-ready"
+	case PhaseDrain:
+		return "drain"
+	case PhaseHooks:
+		return "hooks"
+	case PhaseForceClose:
+		return "force-close"
+	default:
+		return "unknown"
+	}
+}
+
+// ShutdownOptions configures the timeouts of the phases of shutting down a
+// SimpleServer.
+type ShutdownOptions struct {
+
+	// DrainTimeout is how long to wait for in-flight requests to complete
+	// before the remaining connections are force-closed.
+	// Defaults to DefaultDrainTimeout if not set.
+	DrainTimeout time.Duration
+
+	// HookTimeout is how long each shutdown hook may run for.
+	// Defaults to DefaultHookTimeout if not set.
+	HookTimeout time.Duration
+
+	// RestartTimeout is how long Restart waits for the new process to be
+	// ready before abandoning it.
+	// Defaults to DefaultRestartTimeout if not set.
+	RestartTimeout time.Duration
+}
+
+// ShutdownHook is a function called while the server is shutting down, after
+// the in-flight requests have been drained. The context expires once the
+// HookTimeout has passed.
+type ShutdownHook func(ctx context.Context) error
+
+type namedShutdownHook struct {
+	name string
+	hook ShutdownHook
+}
+
+// serverListener is a listener the server is accepting connections on.
+type serverListener struct {
+
+	// The configuration the listener was opened with.
+	config ListenerConfig
+
+	// The listener, before any TLS wrapping, which can be passed to a new
+	// process on Restart.
+	raw net.Listener
+
+	// The http.Server serving the listener, and the function to start it.
+	srv   *http.Server
+	serve func() error
+
+	// Whether the listener was passed from a parent process by Restart.
+	inherited bool
+}
+
+// SetShutdownOptions will set the timeouts of the phases of shutting down the
+// server. Set 0 to use the default.
+func (S *SimpleServer) SetShutdownOptions(Options ShutdownOptions) {
+
+	if Options.DrainTimeout <= 0 {
+		Options.DrainTimeout = DefaultDrainTimeout
+	}
+
+	if Options.HookTimeout <= 0 {
+		Options.HookTimeout = DefaultHookTimeout
+	}
+
+	if Options.RestartTimeout <= 0 {
+		Options.RestartTimeout = DefaultRestartTimeout
+	}
+
+	S.mu.Lock()
+	S.shutdownOptions = Options
+	S.mu.Unlock()
+}
+
+// OnShutdown will register a hook to be run while the server is shutting
+// down. Hooks are run in the order they are registered, after the in-flight
+// requests have been drained.
+func (S *SimpleServer) OnShutdown(Name string, Hook ShutdownHook) {
+	S.mu.Lock()
+	S.shutdownHooks = append(S.shutdownHooks, namedShutdownHook{name: Name, hook: Hook})
+	S.mu.Unlock()
+}
+
+// Shutdown will safely shut down the SimpleServer, returning any errors.
+// See ShutdownContext for the phases performed.
+func (S *SimpleServer) Shutdown() error {
+	return S.ShutdownContext(context.Background())
+}
+
+// ShutdownContext will safely shut down the SimpleServer, performing each
+// ShutdownPhase in order. The context bounds the entire shutdown, in addition
+// to the timeouts of the individual phases.
+//
+// This may be called before the server has started, in which case the server
+// will not start. Concurrent or repeated calls wait for the first to complete,
+// and return its result.
+func (S *SimpleServer) ShutdownContext(ctx context.Context) error {
+
+	S.mu.Lock()
+	if S.stopping {
+		S.mu.Unlock()
+		<-S.done
+		return S.shutdownErr
+	}
+	S.stopping = true
+	Listeners := append(append([]serverListener{}, S.listeners...), S.opened...)
+	S.opened = nil
+	Hooks := append([]namedShutdownHook{}, S.shutdownHooks...)
+	Options := S.shutdownOptions
+	S.mu.Unlock()
+
+	S.StructuredLogger().Info("Shutting down server", "addr", S.Addr())
+
+	var Errors []error
+
+	S.logPhase(PhaseStopAccepting)
+	for _, L := range Listeners {
+		if err := L.raw.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			S.StructuredLogger().Warn("Failed to close listener", "addr", L.config.Addr, "error", err)
+		}
+	}
+
+	S.logPhase(PhaseNotReady)
+	S.health.SetDraining(true)
+
+	// The embedded http.Server is always shut down, even if it never
+	// started, so that it refuses to start later.
+	Servers := []*http.Server{S.Server}
+	for _, L := range Listeners {
+		if L.srv != S.Server {
+			Servers = append(Servers, L.srv)
+		}
+	}
+
+	S.logPhase(PhaseDrain)
+	DrainCtx, cancel := context.WithTimeout(ctx, Options.DrainTimeout)
+	DrainErrs := make([]error, len(Servers))
+	wg := &sync.WaitGroup{}
+	for i, srv := range Servers {
+		wg.Add(1)
+		go func(i int, srv *http.Server) {
+			defer wg.Done()
+			DrainErrs[i] = srv.Shutdown(DrainCtx)
+		}(i, srv)
+	}
+	wg.Wait()
+	cancel()
+
+	Undrained := []*http.Server{}
+	for i, err := range DrainErrs {
+		if err != nil {
+			S.StructuredLogger().Warn("Failed to drain server before timeout", "addr", S.Addr(), "error", err)
+			Errors = append(Errors, err)
+			Undrained = append(Undrained, Servers[i])
+		}
+	}
+
+	S.logPhase(PhaseHooks)
+	for _, H := range Hooks {
+		if err := runShutdownHook(ctx, H.hook, Options.HookTimeout); err != nil {
+			S.StructuredLogger().Error("Shutdown hook failed", "addr", S.Addr(), "hook", H.name, "error", err)
+			Errors = append(Errors, fmt.Errorf("shutdown hook %s: %w", H.name, err))
+		}
+	}
+
+	if len(Undrained) > 0 {
+		S.logPhase(PhaseForceClose)
+		for _, srv := range Undrained {
+			if err := srv.Close(); err != nil {
+				S.StructuredLogger().Error("Error while force closing server", "addr", S.Addr(), "error", err)
+				Errors = append(Errors, err)
+			}
+		}
+	}
+
+	S.mu.Lock()
+	S.shutdownErr = errors.Join(Errors...)
+	S.mu.Unlock()
+	close(S.done)
+
+	S.StructuredLogger().Info("Finished shutting down server", "addr", S.Addr())
+
+	return S.shutdownErr
+}
+
+func (S *SimpleServer) logPhase(Phase ShutdownPhase) {
+	S.StructuredLogger().Debug("Shutdown phase", "addr", S.Addr(), "phase", Phase.String())
+}
+
+// runShutdownHook runs the hook, abandoning it if it does not return before
+// the timeout.
+func runShutdownHook(ctx context.Context, Hook ShutdownHook, Timeout time.Duration) error {
+
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	Done := make(chan error, 1)
+	go func() { Done <- Hook(ctx) }()
+
+	select {
+	case err := <-Done:
+		return err
+	case <-ctx.Done():
+		return ErrShutdownHookTimeout
+	}
+}
+
+// Restart will perform a zero-downtime restart of the server. The current
+// executable is re-executed with the same arguments and environment, and
+// passed the listening sockets of the server, which the new process picks up
+// in ListenAndServe. Once the new process reports it is serving every one of
+// them, across any number of its servers, this server is gracefully shut
+// down, so connections are served throughout by one process or the other.
+//
+// If the new process exits, or is not ready within the RestartTimeout, it is
+// killed and this server continues serving, returning the error.
+//
+// Only listeners opened by ListenAndServe can be passed on.
+func (S *SimpleServer) Restart() (*os.Process, error) {
+
+	Executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	S.mu.Lock()
+	Listeners := append([]serverListener{}, S.listeners...)
+	Timeout := S.shutdownOptions.RestartTimeout
+	S.mu.Unlock()
+
+	Files := []*os.File{}
+	defer func() {
+		for _, F := range Files {
+			F.Close()
+		}
+	}()
+
+	Inherited := []string{}
+	for _, L := range Listeners {
+		Filer, ok := L.raw.(interface{ File() (*os.File, error) })
+		if !ok {
+			S.StructuredLogger().Warn("Listener cannot be passed to the new process", "addr", L.config.Addr)
+			continue
+		}
+
+		// The socket file must outlive this process, for the new one to use.
+		if U, ok := L.raw.(*net.UnixListener); ok {
+			U.SetUnlinkOnClose(false)
+		}
+
+		F, err := Filer.File()
+		if err != nil {
+			return nil, err
+		}
+
+		// ExtraFiles are numbered from 3, after stdin, stdout and stderr.
+		Inherited = append(Inherited, fmt.Sprintf("%d=%s", 3+len(Files), L.config.Addr))
+		Files = append(Files, F)
+	}
+
+	// The new process writes to the pipe once it is serving, or closes it by
+	// exiting.
+	Ready, Notify, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer Ready.Close()
+	ReadyFD := 3 + len(Files)
+	Files = append(Files, Notify)
+
+	Env := []string{}
+	for _, V := range os.Environ() {
+		if !strings.HasPrefix(V, EnvInheritedListeners+"=") && !strings.HasPrefix(V, EnvRestartReady+"=") {
+			Env = append(Env, V)
+		}
+	}
+	Env = append(Env, EnvInheritedListeners+"="+strings.Join(Inherited, ";"), EnvRestartReady+"="+strconv.Itoa(ReadyFD))
+
+	Cmd := exec.Command(Executable, restartArgs()...)
+	Cmd.Stdin, Cmd.Stdout, Cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	Cmd.Env = Env
+	Cmd.ExtraFiles = Files
+
+	if err := Cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	// Only the new process may hold the write end, so its exit is seen.
+	Notify.Close()
+
+	S.StructuredLogger().Info("Started new server process, waiting for it to be ready", "addr", S.Addr(), "pid", Cmd.Process.Pid)
+
+	if err := waitReady(Ready, Timeout); err != nil {
+		S.StructuredLogger().Error("New server process failed to become ready, continuing to serve", "addr", S.Addr(), "pid", Cmd.Process.Pid, "error", err)
+		Cmd.Process.Kill()
+		Cmd.Wait()
+		return nil, err
+	}
+
+	S.StructuredLogger().Info("New server process is ready, shutting down", "addr", S.Addr(), "pid", Cmd.Process.Pid)
+
+	return Cmd.Process, S.Shutdown()
+}
+
+// restartArgs returns the arguments the new process is started with by Restart.
+var restartArgs = func() []string {
+	return os.Args[1:]
+}
+
+// waitReady waits for the new process started by Restart to report it is
+// ready on the pipe.
+func waitReady(Ready *os.File, Timeout time.Duration) error {
+
+	if err := Ready.SetReadDeadline(time.Now().Add(Timeout)); err != nil {
+		return err
+	}
+
+	if _, err := Ready.Read(make([]byte, 1)); err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return errors.New("server error: New server process was not ready before timeout")
+		}
+		return errors.New("server error: New server process exited before it was ready")
+	}
+
+	return nil
+}
+
+// notifyReady will record that the given number of inherited listeners are
+// being served and, once every listener passed from the parent process is,
+// report to the parent that this process is ready. This holds however many
+// servers the listeners are split between.
+func notifyReady(Served int) {
+
+	loadInherited()
+
+	inheritedMu.Lock()
+	inheritedUnserved -= Served
+	F := restartReady
+	if inheritedUnserved > 0 {
+		F = nil
+	} else {
+		restartReady = nil
+	}
+	inheritedMu.Unlock()
+
+	if F != nil {
+		F.Write([]byte{1})
+		F.Close()
+	}
+}
+
+// serve will serve on the listener until the server is shut down, waiting for
+// the shutdown to fully complete before returning.
+func (S *SimpleServer) serve(L serverListener) error {
+
+	S.mu.Lock()
+	if S.stopping {
+		S.mu.Unlock()
+		L.raw.Close()
+		<-S.done
+		return nil
+	}
+	S.listeners = append(S.listeners, L)
+	S.mu.Unlock()
+
+	err := L.serve()
+
+	S.mu.Lock()
+	Stopping := S.stopping
+	S.mu.Unlock()
+
+	// Errors from the listener being closed are expected while shutting down.
+	if !Stopping && err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	<-S.done
+	return nil
+}
+
+var (
+	inheritedOnce      = &sync.Once{}
+	inheritedMu        = &sync.Mutex{}
+	inheritedListeners = map[string]*os.File{}
+	inheritedUnserved  int
+	restartReady       *os.File
+)
+
+// loadInherited will take the listeners and readiness pipe passed from a
+// parent process by Restart, if any, from the environment.
+func loadInherited() {
+	inheritedOnce.Do(func() {
+		for _, Spec := range strings.Split(os.Getenv(EnvInheritedListeners), ";") {
+			FD, Address, ok := strings.Cut(Spec, "=")
+			if !ok {
+				continue
+			}
+			N, err := strconv.Atoi(FD)
+			if err != nil {
+				continue
+			}
+			inheritedListeners[Address] = os.NewFile(uintptr(N), Address)
+		}
+		inheritedUnserved = len(inheritedListeners)
+		os.Unsetenv(EnvInheritedListeners)
+
+		if N, err := strconv.Atoi(os.Getenv(EnvRestartReady)); err == nil {
+			restartReady = os.NewFile(uintptr(N), "restart-ready")
+		}
+		os.Unsetenv(EnvRestartReady)
+	})
+}
+
+// listen will create a listener on the address, or take over the listener
+// passed from a parent process by Restart, reporting which was done.
+func listen(Network, Addr string) (net.Listener, bool, error) {
+
+	loadInherited()
+
+	inheritedMu.Lock()
+	F, ok := inheritedListeners[Addr]
+	delete(inheritedListeners, Addr)
+	inheritedMu.Unlock()
+
+	if !ok {
+		if Network == "unix" {
+			l, err := listenUnix(Addr)
+			return l, false, err
+		}
+		l, err := net.Listen(Network, Addr)
+		return l, false, err
+	}
+
+	defer F.Close()
+	l, err := net.FileListener(F)
+	return l, true, err
+}
@@ -0,0 +1,359 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+)
+
+func TestShutdownBeforeStart(T *testing.T) {
+
+	S := NewServerHTTP("127.0.0.1:0")
+	S.SetStructuredLogger(easytls.NewDiscardLogger())
+
+	if err := S.Shutdown(); err != nil {
+		T.Fatalf("Unexpected error shutting down a server which never started: %v", err)
+	}
+
+	Done := make(chan error, 1)
+	go func() { Done <- S.ListenAndServe() }()
+
+	select {
+	case err := <-Done:
+		if err != nil {
+			T.Fatalf("Expected ListenAndServe to return nil after Shutdown, got %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		T.Fatalf("ListenAndServe did not return on a server which was already shut down")
+	}
+}
+
+func TestShutdownDrainsBeforeHooks(T *testing.T) {
+
+	L, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		T.Fatal(err)
+	}
+
+	Started := make(chan struct{})
+	Release := make(chan struct{})
+	Events := make(chan string, 4)
+
+	S := NewServerHTTP()
+	S.SetStructuredLogger(easytls.NewDiscardLogger())
+	S.AddHandlers(S.Router(), NewSimpleHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(Started)
+		<-Release
+		w.WriteHeader(http.StatusOK)
+		Events <- "handled"
+	}), "/slow", http.MethodGet))
+
+	S.OnShutdown("first", func(context.Context) error {
+		Events <- "first"
+		return nil
+	})
+	S.OnShutdown("second", func(context.Context) error {
+		Events <- "second"
+		return errors.New("failed")
+	})
+
+	Served := make(chan error, 1)
+	go func() { Served <- S.Serve(L) }()
+
+	Response := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + L.Addr().String() + "/slow")
+		if err != nil {
+			Response <- 0
+			return
+		}
+		resp.Body.Close()
+		Response <- resp.StatusCode
+	}()
+
+	<-Started
+
+	ShutdownErr := make(chan error, 1)
+	go func() { ShutdownErr <- S.Shutdown() }()
+
+	// The server must report as not ready while the request is in flight.
+	for !S.Health().Draining() {
+		time.Sleep(time.Millisecond)
+	}
+	close(Release)
+
+	if Status := <-Response; Status != http.StatusOK {
+		T.Fatalf("Expected the in-flight request to complete with status 200, got %d", Status)
+	}
+
+	if err := <-ShutdownErr; err == nil {
+		T.Fatalf("Expected the error of the failing hook to be returned")
+	}
+
+	if err := <-Served; err != nil {
+		T.Fatalf("Expected Serve to return nil after Shutdown, got %v", err)
+	}
+
+	for _, Expected := range []string{"handled", "first", "second"} {
+		if Event := <-Events; Event != Expected {
+			T.Fatalf("Expected event [ %s ], got [ %s ]", Expected, Event)
+		}
+	}
+}
+
+// EnvRestartTestChild marks the process started by Restart in TestRestart.
+const EnvRestartTestChild = "EASYTLS_TEST_RESTART_CHILD"
+
+func TestRestart(T *testing.T) {
+
+	if Addr := os.Getenv(EnvRestartTestChild); Addr != "" {
+		runRestartChild(T, Addr)
+		return
+	}
+
+	// Reserve a free port for the server.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		T.Fatal(err)
+	}
+	Addr := l.Addr().String()
+	l.Close()
+
+	S := NewServerHTTP(Addr)
+	S.SetStructuredLogger(easytls.NewDiscardLogger())
+	S.SetShutdownOptions(ShutdownOptions{RestartTimeout: time.Second * 10})
+	S.AddHandlers(S.Router(), NewSimpleHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%d", os.Getpid())
+	}), "/pid", http.MethodGet))
+
+	Served := make(chan error, 1)
+	go func() { Served <- S.ListenAndServe() }()
+
+	URL := "http://" + Addr
+	for Deadline := time.Now().Add(time.Second * 5); time.Now().Before(Deadline); time.Sleep(time.Millisecond * 10) {
+		if _, err = getBody(URL + "/pid"); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		T.Fatalf("Failed to reach the server: %v", err)
+	}
+
+	defer func(Args func() []string) { restartArgs = Args }(restartArgs)
+
+	// A new process which exits without serving leaves this one serving.
+	restartArgs = func() []string { return []string{"-test.list=^$"} }
+	if _, err := S.Restart(); err == nil {
+		T.Fatalf("Expected an error from a new process which never became ready")
+	}
+	if Body, err := getBody(URL + "/pid"); err != nil || Body != strconv.Itoa(os.Getpid()) {
+		T.Fatalf("Expected this process to continue serving, got [ %s ] - %v", Body, err)
+	}
+
+	restartArgs = func() []string { return []string{"-test.run=^TestRestart$"} }
+	T.Setenv(EnvRestartTestChild, Addr)
+
+	Child, err := S.Restart()
+	if err != nil {
+		T.Fatalf("Failed to restart: %v", err)
+	}
+
+	if err := <-Served; err != nil {
+		T.Fatalf("Expected ListenAndServe to return nil after Restart, got %v", err)
+	}
+
+	// The new process is ready as soon as Restart returns, so no retry is needed.
+	Body, err := getBody(URL + "/pid")
+	if err != nil {
+		Child.Kill()
+		T.Fatalf("Expected the new process to be serving once Restart returns: %v", err)
+	}
+	if Body != strconv.Itoa(Child.Pid)+" inherited" {
+		Child.Kill()
+		T.Fatalf("Expected the new process to serve the inherited listener, got [ %s ]", Body)
+	}
+
+	if _, err := getBody(URL + "/stop"); err != nil {
+		Child.Kill()
+		T.Fatalf("Failed to stop the new process: %v", err)
+	}
+
+	State, err := Child.Wait()
+	if err != nil || !State.Success() {
+		T.Fatalf("Expected the new process to exit cleanly, got %v - %v", State, err)
+	}
+}
+
+// runRestartChild serves as the process started by Restart in TestRestart,
+// until asked to stop.
+func runRestartChild(T *testing.T, Addr string) {
+
+	Inherited := "new"
+	if strings.Contains(os.Getenv(EnvInheritedListeners), "="+Addr) {
+		Inherited = "inherited"
+	}
+
+	S := NewServerHTTP(Addr)
+	S.SetStructuredLogger(easytls.NewDiscardLogger())
+	S.AddHandlers(S.Router(), NewSimpleHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%d %s", os.Getpid(), Inherited)
+	}), "/pid", http.MethodGet))
+	S.AddHandlers(S.Router(), NewSimpleHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		go S.Shutdown()
+	}), "/stop", http.MethodGet))
+
+	if err := S.ListenAndServe(); err != nil {
+		T.Fatalf("Failed to serve: %v", err)
+	}
+}
+
+func getBody(URL string) (string, error) {
+
+	resp, err := http.Get(URL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	Body, err := io.ReadAll(resp.Body)
+	return string(Body), err
+}
+
+// EnvRestartTestServers marks the process started by Restart in
+// TestRestartMultipleServers, and holds the addresses of its servers.
+const EnvRestartTestServers = "EASYTLS_TEST_RESTART_SERVERS"
+
+func TestRestartMultipleServers(T *testing.T) {
+
+	if Addrs := os.Getenv(EnvRestartTestServers); Addrs != "" {
+		runRestartServers(T, strings.Split(Addrs, ";"))
+		return
+	}
+
+	Addrs := []string{}
+	for i := 0; i < 2; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			T.Fatal(err)
+		}
+		Addrs = append(Addrs, l.Addr().String())
+		l.Close()
+	}
+
+	S := NewServerHTTP(Addrs[0])
+	S.SetStructuredLogger(easytls.NewDiscardLogger())
+	S.SetShutdownOptions(ShutdownOptions{RestartTimeout: time.Second * 10})
+	if err := S.AddListener(ListenerConfig{Addr: Addrs[1]}); err != nil {
+		T.Fatal(err)
+	}
+	S.AddHandlers(S.Router(), NewSimpleHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%d", os.Getpid())
+	}), "/pid", http.MethodGet))
+
+	Served := make(chan error, 1)
+	go func() { Served <- S.ListenAndServe() }()
+
+	var err error
+	for Deadline := time.Now().Add(time.Second * 5); time.Now().Before(Deadline); time.Sleep(time.Millisecond * 10) {
+		if _, err = getBody("http://" + Addrs[1] + "/pid"); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		T.Fatalf("Failed to reach the server: %v", err)
+	}
+
+	defer func(Args func() []string) { restartArgs = Args }(restartArgs)
+	restartArgs = func() []string { return []string{"-test.run=^TestRestartMultipleServers$"} }
+	T.Setenv(EnvRestartTestServers, strings.Join(Addrs, ";"))
+
+	Started := time.Now()
+	Child, err := S.Restart()
+	if err != nil {
+		T.Fatalf("Failed to restart: %v", err)
+	}
+	defer Child.Kill()
+
+	// The second server in the new process only starts after a delay, and the
+	// new process must not report ready until it has.
+	if Waited := time.Since(Started); Waited < restartServerDelay {
+		T.Fatalf("Expected Restart to wait for every server of the new process, returned after %v", Waited)
+	}
+
+	if err := <-Served; err != nil {
+		T.Fatalf("Expected ListenAndServe to return nil after Restart, got %v", err)
+	}
+
+	// The listeners are split between two servers in the new process, and
+	// both must be served once Restart returns.
+	for _, Addr := range Addrs {
+		Body, err := getBody("http://" + Addr + "/pid")
+		if err != nil || Body != strconv.Itoa(Child.Pid) {
+			T.Fatalf("Expected the new process to be serving [ %s ] once Restart returns, got [ %s ] - %v", Addr, Body, err)
+		}
+	}
+
+	if _, err := getBody("http://" + Addrs[0] + "/stop"); err != nil {
+		T.Fatalf("Failed to stop the new process: %v", err)
+	}
+
+	State, err := Child.Wait()
+	if err != nil || !State.Success() {
+		T.Fatalf("Expected the new process to exit cleanly, got %v - %v", State, err)
+	}
+}
+
+// restartServerDelay is how long each server in the process started by
+// Restart in TestRestartMultipleServers waits before starting, after the
+// server before it.
+const restartServerDelay = time.Second
+
+// runRestartServers serves as the process started by Restart in
+// TestRestartMultipleServers, with a separate server for each address, until
+// asked to stop.
+func runRestartServers(T *testing.T, Addrs []string) {
+
+	Servers := []*SimpleServer{}
+	for _, Addr := range Addrs {
+		S := NewServerHTTP(Addr)
+		S.SetStructuredLogger(easytls.NewDiscardLogger())
+		S.AddHandlers(S.Router(), NewSimpleHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "%d", os.Getpid())
+		}), "/pid", http.MethodGet))
+		Servers = append(Servers, S)
+	}
+
+	Servers[0].AddHandlers(Servers[0].Router(), NewSimpleHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		go func() {
+			for _, S := range Servers {
+				S.Shutdown()
+			}
+		}()
+	}), "/stop", http.MethodGet))
+
+	Served := make(chan error, len(Servers))
+	for i, S := range Servers {
+		go func(i int, S *SimpleServer) {
+			time.Sleep(restartServerDelay * time.Duration(i))
+			Served <- S.ListenAndServe()
+		}(i, S)
+	}
+
+	for range Servers {
+		if err := <-Served; err != nil {
+			T.Fatalf("Failed to serve: %v", err)
+		}
+	}
+}
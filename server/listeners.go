@@ -0,0 +1,232 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+)
+
+// ListenerConfig defines an additional address a SimpleServer accepts
+// connections on, alongside its primary Addr.
+type ListenerConfig struct {
+
+	// Network is the network to listen on, either "tcp" or "unix".
+	// Defaults to "tcp" if not set.
+	Network string
+
+	// Addr is the IP:Port address to listen on, or the path of the socket
+	// for a unix listener.
+	Addr string
+
+	// TLS is the (optional) TLS resources to serve this listener with.
+	// Plain HTTP is served if this is nil or not Enabled.
+	TLS *easytls.TLSBundle
+
+	// RedirectHTTPS, if set, makes this listener serve only redirects of
+	// every request to HTTPS on the given port, rather than the router of the
+	// server.
+	RedirectHTTPS string
+}
+
+// ListenerInfo describes a single listener of a SimpleServer, as shown on
+// the "/about/listeners" handler.
+type ListenerInfo struct {
+	Network       string
+	Addr          string
+	TLS           bool
+	RedirectHTTPS string `json:",omitempty"`
+}
+
+// AddListener will add an additional listener to the server, which is opened
+// by ListenAndServe alongside the primary Addr. All listeners share the
+// router, timeouts and shutdown of the server.
+func (S *SimpleServer) AddListener(Config ListenerConfig) error {
+
+	if Config.Network == "" {
+		Config.Network = "tcp"
+	}
+
+	if Config.Network != "tcp" && Config.Network != "unix" {
+		return errors.New("server error: Unsupported listener network [ " + Config.Network + " ]")
+	}
+
+	if Config.Addr == "" {
+		return errors.New("server error: No address given for listener")
+	}
+
+	if Config.TLS != nil && Config.TLS.Enabled {
+		if _, err := easytls.NewTLSConfig(Config.TLS); err != nil {
+			return err
+		}
+		S.expiry.AddBundle(Config.TLS)
+	}
+
+	S.mu.Lock()
+	S.extraListeners = append(S.extraListeners, Config)
+	S.mu.Unlock()
+
+	return nil
+}
+
+// RedirectHTTP will add a listener on the given address which redirects all
+// requests to HTTPS on the port of the primary Addr of the server.
+func (S *SimpleServer) RedirectHTTP(Addr string) error {
+
+	_, Port, err := net.SplitHostPort(S.Addr())
+	if err != nil {
+		return err
+	}
+
+	return S.AddListener(ListenerConfig{Addr: Addr, RedirectHTTPS: Port})
+}
+
+// Listeners will return the description of every listener of the server,
+// starting with the primary Addr, if set.
+func (S *SimpleServer) Listeners() []ListenerInfo {
+
+	Configs := S.listenerConfigs()
+
+	Info := make([]ListenerInfo, 0, len(Configs))
+	for _, C := range Configs {
+		Info = append(Info, ListenerInfo{
+			Network:       C.Network,
+			Addr:          C.Addr,
+			TLS:           C.TLS != nil && C.TLS.Enabled,
+			RedirectHTTPS: C.RedirectHTTPS,
+		})
+	}
+
+	return Info
+}
+
+// listenerConfigs returns the configuration of the primary Addr, if set,
+// followed by any additional listeners.
+func (S *SimpleServer) listenerConfigs() []ListenerConfig {
+
+	S.mu.Lock()
+	defer S.mu.Unlock()
+
+	Configs := []ListenerConfig{}
+	if S.Addr() != "" {
+		Configs = append(Configs, ListenerConfig{Network: "tcp", Addr: S.Addr(), TLS: S.tls})
+	}
+
+	return append(Configs, S.extraListeners...)
+}
+
+// newListener will open the listener described by the config, along with the
+// http.Server to serve it with. The primary Addr is served by the embedded
+// http.Server, while additional listeners share its settings.
+func (S *SimpleServer) newListener(Config ListenerConfig, Primary bool) (serverListener, error) {
+
+	l, Inherited, err := listen(Config.Network, Config.Addr)
+	if err != nil {
+		return serverListener{}, err
+	}
+
+	L := serverListener{config: Config, raw: l, srv: S.Server, inherited: Inherited}
+
+	if !Primary {
+		var Handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			S.Server.Handler.ServeHTTP(w, r)
+		})
+		if Config.RedirectHTTPS != "" {
+			Handler = redirectHTTPSHandler(Config.RedirectHTTPS)
+		}
+
+		L.srv = &http.Server{
+			Handler:           Handler,
+			ReadTimeout:       S.Server.ReadTimeout,
+			ReadHeaderTimeout: S.Server.ReadHeaderTimeout,
+			WriteTimeout:      S.Server.WriteTimeout,
+			IdleTimeout:       S.Server.IdleTimeout,
+			MaxHeaderBytes:    S.Server.MaxHeaderBytes,
+			ErrorLog:          S.Server.ErrorLog,
+			ConnState:         S.Server.ConnState,
+		}
+
+		if Config.TLS != nil && Config.TLS.Enabled {
+			if L.srv.TLSConfig, err = easytls.NewTLSConfig(Config.TLS); err != nil {
+				l.Close()
+				return serverListener{}, err
+			}
+		}
+	}
+
+	if Config.TLS == nil || !Config.TLS.Enabled {
+		L.serve = func() error { return L.srv.Serve(l) }
+	} else {
+		// The certificates are already held by the TLSConfig, possibly
+		// behind a reloader, so don't re-read them by filename here.
+		L.serve = func() error { return L.srv.ServeTLS(l, "", "") }
+	}
+
+	return L, nil
+}
+
+// redirectHTTPSHandler will redirect every request to the same host and URL,
+// using HTTPS on the given port.
+func redirectHTTPSHandler(Port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		Host := r.Host
+		if H, _, err := net.SplitHostPort(Host); err == nil {
+			Host = H
+		}
+
+		if Port != "443" {
+			Host = net.JoinHostPort(strings.Trim(Host, "[]"), Port)
+		}
+
+		Target := url.URL{
+			Scheme:   "https",
+			Host:     Host,
+			Path:     r.URL.Path,
+			RawPath:  r.URL.RawPath,
+			RawQuery: r.URL.RawQuery,
+		}
+
+		http.Redirect(w, r, Target.String(), http.StatusPermanentRedirect)
+	})
+}
+
+// listenersHandler will report the listeners of the server, as JSON.
+func listenersHandler(S *SimpleServer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodHead:
+			w.WriteHeader(http.StatusOK)
+		default:
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "\t")
+			enc.SetEscapeHTML(true)
+			enc.Encode(S.Listeners())
+		}
+	})
+}
+
+// listenUnix will listen on the unix socket at Path, removing a stale socket
+// left behind by a previous process if nothing is listening on it. Anything
+// at Path other than a socket is left alone, and the listen fails.
+func listenUnix(Path string) (net.Listener, error) {
+
+	if Info, err := os.Lstat(Path); err == nil && Info.Mode()&os.ModeSocket != 0 {
+		if Conn, err := net.DialTimeout("unix", Path, time.Second); err == nil {
+			Conn.Close()
+			return nil, errors.New("server error: Unix socket [ " + Path + " ] already in use")
+		}
+		if err := os.Remove(Path); err != nil {
+			return nil, err
+		}
+	}
+
+	return net.Listen("unix", Path)
+}
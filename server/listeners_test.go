@@ -0,0 +1,226 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+)
+
+func TestMultipleListeners(T *testing.T) {
+
+	// Reserve a free port for the primary address.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		T.Fatal(err)
+	}
+	Addr := l.Addr().String()
+	l.Close()
+
+	Socket := filepath.Join(T.TempDir(), "admin.sock")
+
+	S := NewServerHTTP(Addr)
+	S.SetStructuredLogger(easytls.NewDiscardLogger())
+	S.AddHandlers(S.Router(), NewSimpleHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}), "/teapot", http.MethodGet))
+
+	if err := S.AddListener(ListenerConfig{Network: "unix", Addr: Socket}); err != nil {
+		T.Fatal(err)
+	}
+
+	if err := S.AddListener(ListenerConfig{Network: "udp", Addr: Addr}); err == nil {
+		T.Fatalf("Expected an unsupported network to be rejected")
+	}
+
+	Served := make(chan error, 1)
+	go func() { Served <- S.ListenAndServe() }()
+
+	Unix := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", Socket)
+			},
+		},
+	}
+
+	// Wait for both listeners to be up.
+	var resp *http.Response
+	for Deadline := time.Now().Add(time.Second * 5); time.Now().Before(Deadline); time.Sleep(time.Millisecond * 10) {
+		if resp, err = Unix.Get("http://unix/teapot"); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		T.Fatalf("Failed to reach the unix listener: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTeapot {
+		T.Fatalf("Expected the router to be served on the unix listener, got status %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get("http://" + Addr + "/about/listeners")
+	if err != nil {
+		T.Fatal(err)
+	}
+	Info := []ListenerInfo{}
+	json.NewDecoder(resp.Body).Decode(&Info)
+	resp.Body.Close()
+
+	if len(Info) != 2 || Info[0].Addr != Addr || Info[1].Network != "unix" || Info[1].Addr != Socket {
+		T.Fatalf("Unexpected listeners reported: %+v", Info)
+	}
+
+	if err := S.Shutdown(); err != nil {
+		T.Fatal(err)
+	}
+
+	if err := <-Served; err != nil {
+		T.Fatalf("Expected ListenAndServe to return nil after Shutdown, got %v", err)
+	}
+
+	if _, err := Unix.Get("http://unix/teapot"); err == nil {
+		T.Fatalf("Expected the unix listener to be closed by Shutdown")
+	}
+}
+
+func TestRedirectHTTPSHandler(T *testing.T) {
+
+	Cases := []struct {
+		Port     string
+		Host     string
+		Expected string
+	}{
+		{"443", "example.com", "https://example.com/a/b?c=d"},
+		{"443", "example.com:80", "https://example.com/a/b?c=d"},
+		{"8443", "example.com:8080", "https://example.com:8443/a/b?c=d"},
+		{"8443", "[::1]:8080", "https://[::1]:8443/a/b?c=d"},
+	}
+
+	for _, C := range Cases {
+		r := httptest.NewRequest(http.MethodPost, "/a/b?c=d", nil)
+		r.Host = C.Host
+		w := httptest.NewRecorder()
+
+		redirectHTTPSHandler(C.Port).ServeHTTP(w, r)
+
+		if w.Code != http.StatusPermanentRedirect {
+			T.Fatalf("Expected status %d, got %d", http.StatusPermanentRedirect, w.Code)
+		}
+		if Location := w.Header().Get("Location"); Location != C.Expected {
+			T.Fatalf("Expected redirect to [ %s ], got [ %s ]", C.Expected, Location)
+		}
+	}
+}
+
+func TestListenUnixKeepsOtherFiles(T *testing.T) {
+
+	Path := filepath.Join(T.TempDir(), "not-a-socket")
+	if err := os.WriteFile(Path, []byte("keep me"), 0600); err != nil {
+		T.Fatal(err)
+	}
+
+	S := NewServerHTTP("")
+	S.SetStructuredLogger(easytls.NewDiscardLogger())
+	if err := S.AddListener(ListenerConfig{Network: "unix", Addr: Path}); err != nil {
+		T.Fatal(err)
+	}
+
+	if err := S.ListenAndServe(); err == nil {
+		T.Fatalf("Expected listening on a path which is not a socket to fail")
+	}
+
+	if Contents, err := os.ReadFile(Path); err != nil || string(Contents) != "keep me" {
+		T.Fatalf("Expected the file at the path to be left alone, got [ %s ] - %v", Contents, err)
+	}
+
+	// A stale socket, with nothing listening on it, is replaced.
+	Socket := filepath.Join(T.TempDir(), "stale.sock")
+	l, err := net.Listen("unix", Socket)
+	if err != nil {
+		T.Fatal(err)
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+
+	if l, err = listenUnix(Socket); err != nil {
+		T.Fatalf("Expected a stale socket to be replaced: %v", err)
+	}
+	l.Close()
+}
+
+func TestListenAndServeWithoutListeners(T *testing.T) {
+
+	S := NewServerHTTP("")
+	S.SetStructuredLogger(easytls.NewDiscardLogger())
+
+	if err := S.ListenAndServe(); err == nil {
+		T.Fatalf("Expected an error from a server with nothing to serve on")
+	}
+}
+
+func TestListenBeforeServe(T *testing.T) {
+
+	Busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		T.Fatal(err)
+	}
+	defer Busy.Close()
+
+	// An address in use is reported by Listen, before anything is served.
+	S := NewServerHTTP(Busy.Addr().String())
+	S.SetStructuredLogger(easytls.NewDiscardLogger())
+	if err := S.Listen(); err == nil {
+		T.Fatalf("Expected an error listening on an address in use")
+	}
+
+	Path := filepath.Join(T.TempDir(), "server.sock")
+	S = NewServerHTTP("")
+	S.SetStructuredLogger(easytls.NewDiscardLogger())
+	if err := S.AddListener(ListenerConfig{Network: "unix", Addr: Path}); err != nil {
+		T.Fatal(err)
+	}
+
+	if err := S.Listen(); err != nil {
+		T.Fatalf("Failed to listen: %v", err)
+	}
+
+	// The socket accepts connections as soon as Listen returns.
+	Conn, err := net.Dial("unix", Path)
+	if err != nil {
+		T.Fatalf("Expected the socket to exist once Listen returns: %v", err)
+	}
+	Conn.Close()
+
+	if err := S.Listen(); err == nil {
+		T.Fatalf("Expected an error from listening twice")
+	}
+
+	Served := make(chan error, 1)
+	go func() { Served <- S.ListenAndServe() }()
+
+	C := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", Path)
+		},
+	}}
+	resp, err := C.Get("http://unix/healthz")
+	if err != nil {
+		T.Fatalf("Failed to reach the server over the opened socket: %v", err)
+	}
+	resp.Body.Close()
+
+	if err := S.Shutdown(); err != nil {
+		T.Fatalf("Unexpected error shutting down: %v", err)
+	}
+	if err := <-Served; err != nil {
+		T.Fatalf("Expected ListenAndServe to return nil after Shutdown, got %v", err)
+	}
+}
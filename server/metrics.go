@@ -0,0 +1,85 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Bearnie-H/easy-tls/metrics"
+	"github.com/gorilla/mux"
+)
+
+// The names of the metrics recorded by this package.
+const (
+	MetricRequestDuration       = "easytls_server_request_duration_seconds"
+	MetricRequestsInFlight      = "easytls_server_requests_in_flight"
+	MetricLimitedInFlight       = "easytls_server_limited_requests_in_flight"
+	MetricLimitedRequestTimeout = "easytls_server_limited_request_timeouts_total"
+)
+
+// MiddlewareMetrics provides a middleware to record the latency of every
+// request in the given Registry, by method, route template and status code.
+// The metrics.Default Registry is used if none is given.
+func MiddlewareMetrics(Registry *metrics.Registry) MiddlewareHandler {
+
+	if Registry == nil {
+		Registry = metrics.Default
+	}
+
+	Duration := Registry.Histogram(MetricRequestDuration, "Latency of the requests served, by method, route template and status code.", nil, "method", "route", "status")
+	InFlight := Registry.Gauge(MetricRequestsInFlight, "Number of requests currently being served.")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			Start := time.Now()
+			InFlight.Inc()
+			defer InFlight.Dec()
+
+			Recorder, Writer := newResponseRecorder(w)
+			next.ServeHTTP(Writer, r)
+
+			Duration.Observe(time.Since(Start).Seconds(), r.Method, routeTemplate(r), strconv.Itoa(Recorder.Status()))
+		})
+	}
+}
+
+// MetricsHandler will return a SimpleHandler serving the given Registries at
+// "/metrics" in the text exposition format. The metrics.Default Registry is
+// served if none are given.
+func MetricsHandler(Registries ...*metrics.Registry) SimpleHandler {
+
+	if len(Registries) == 0 {
+		Registries = []*metrics.Registry{metrics.Default}
+	}
+
+	H := NewSimpleHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", metrics.ContentType)
+		for _, R := range Registries {
+			R.WriteText(w)
+		}
+	}), "/metrics", http.MethodGet)
+	H.AddDescription("Serve the metrics of the server in the Prometheus text exposition format.")
+
+	return H
+}
+
+// routeTemplate returns the path template of the route matching the request,
+// to keep the number of distinct label values bounded.
+func routeTemplate(r *http.Request) string {
+
+	Route := mux.CurrentRoute(r)
+	if Route == nil {
+		return "unmatched"
+	}
+
+	if Template, err := Route.GetPathTemplate(); err == nil {
+		return Template
+	}
+
+	if Template, err := Route.GetPathRegexp(); err == nil {
+		return Template
+	}
+
+	return "unmatched"
+}
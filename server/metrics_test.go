@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Bearnie-H/easy-tls/metrics"
+)
+
+func TestMiddlewareMetrics(T *testing.T) {
+
+	Registry := metrics.NewRegistry()
+
+	S := NewServerHTTP()
+	S.AddMiddlewares(MiddlewareMetrics(Registry))
+	S.AddHandlers(S.Router(),
+		NewSimpleHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		}), "/items/{ID}", http.MethodPost),
+		MetricsHandler(Registry),
+	)
+
+	for _, ID := range []string{"1", "2"} {
+		S.Router().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/items/"+ID, nil))
+	}
+
+	w := httptest.NewRecorder()
+	S.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if w.Header().Get("Content-Type") != metrics.ContentType {
+		T.Fatalf("Expected the text exposition Content-Type, got [ %s ]", w.Header().Get("Content-Type"))
+	}
+
+	Expected := MetricRequestDuration + `_count{method="POST",route="/items/{ID}",status="201"} 2`
+	if !strings.Contains(w.Body.String(), Expected) {
+		T.Fatalf("Expected [ %s ] in the metrics output:\n%s", Expected, w.Body.String())
+	}
+
+	if !strings.Contains(w.Body.String(), MetricRequestsInFlight+" 1") {
+		T.Fatalf("Expected the /metrics request itself to be in flight:\n%s", w.Body.String())
+	}
+}
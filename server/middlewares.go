@@ -5,34 +5,32 @@ import (
 	"net/http"
 	"sync/atomic"
 	"time"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+	"github.com/Bearnie-H/easy-tls/metrics"
 )
 
 // MiddlewareHandler represents the Type which must be satisfied by any
 // function to be used as a middleware function in the Server chain.
 type MiddlewareHandler = func(http.Handler) http.Handler
 
-// MiddlewareDefaultLogger provides a simple logging middleware, to view
-// incoming connections as they arrive and print a basic set of
-// properties of the request.
-func MiddlewareDefaultLogger(logger *log.Logger) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			logger.Printf("[MiddlewareDefaultLogger] Received [ %s ] [ %s ] Request for URL \"%s\" from Address: [ %s ].\n", r.Proto, r.Method, r.URL.String(), r.RemoteAddr)
-			next.ServeHTTP(w, r)
-		})
-	}
-}
-
 // MiddlewareLimitMaxConnections will provide a mechanism to strictly limit the
 // maximum number of concurrent requests served. Verbose mode includes a log
 // message when a request begins processing through this function. If the
 // request is not processed within Timeout, a failed statusCode will
 // be generated and sent back.
-func MiddlewareLimitMaxConnections(ConnectionLimit int, Timeout time.Duration, logger *log.Logger) func(http.Handler) http.Handler {
+//
+// The number of requests in flight, and the number which timed out, are
+// recorded in the metrics.Default Registry.
+func MiddlewareLimitMaxConnections(ConnectionLimit int, Timeout time.Duration, l *log.Logger) func(http.Handler) http.Handler {
+	logger := optionalLogger(l)
 	semaphore := make(chan struct{}, ConnectionLimit)
 	var count = new(int32)
 	*count = 0
 
+	InFlight := metrics.Default.Gauge(MetricLimitedInFlight, "Number of requests currently being processed by MiddlewareLimitMaxConnections.")
+	Timeouts := metrics.Default.Counter(MetricLimitedRequestTimeout, "Number of requests which timed out waiting in MiddlewareLimitMaxConnections.")
+
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
@@ -51,21 +49,24 @@ func MiddlewareLimitMaxConnections(ConnectionLimit int, Timeout time.Duration, l
 					<-timer.C
 				}
 				atomic.AddInt32(count, 1)
+				InFlight.Inc()
 				defer func() {
 					atomic.AddInt32(count, -1)
+					InFlight.Dec()
 					<-semaphore
 				}()
 				if logger != nil {
-					logger.Printf("[MiddlewareLimitMaxConnections] Started processing request [ %d / %d ] from [ %s ].", *count, ConnectionLimit, r.RemoteAddr)
+					easytls.RequestLogger(r.Context(), logger).Debug("[MiddlewareLimitMaxConnections] Started processing request", "active", atomic.LoadInt32(count), "limit", ConnectionLimit, "remote_addr", r.RemoteAddr)
 				}
 				h.ServeHTTP(w, r)
 
 				// If the timer expires, write a timeout response and exit
 			case <-timer.C:
 				timer.Stop()
+				Timeouts.Inc()
 				w.WriteHeader(http.StatusRequestTimeout)
 				if logger != nil {
-					logger.Printf("[MiddlewareLimitMaxConnections] [ %s ] [ %s ] Request for URL \"%s\" from Address: [ %s ] - Timeout\n", r.Proto, r.Method, r.URL.String(), r.RemoteAddr)
+					easytls.RequestLogger(r.Context(), logger).Warn("[MiddlewareLimitMaxConnections] Request timed out", "proto", r.Proto, "method", r.Method, "url", r.URL.String(), "remote_addr", r.RemoteAddr)
 				}
 			}
 		})
@@ -75,11 +76,18 @@ func MiddlewareLimitMaxConnections(ConnectionLimit int, Timeout time.Duration, l
 
 // MiddlewareLimitConnectionRate will limit the rate at which the Server will
 // process incoming requests. This will process no more than 1 request per
-// OncePer. Verbose mode includes a log message when a request begins
-// processing through this function. If the request is not processed within
-// Timeout, a failed statusCode will be generated and sent back.
-func MiddlewareLimitConnectionRate(OncePer time.Duration, Timeout time.Duration, logger *log.Logger) func(http.Handler) http.Handler {
-	ticker := time.NewTicker(OncePer)
+// OncePer, shared between all clients. Verbose mode includes a log message
+// when a request begins processing through this function. If the request is
+// not processed within Timeout, a failed statusCode will be generated and
+// sent back.
+//
+// Deprecated: Use MiddlewareRateLimit, which allows bursts, limits each client
+// separately, and rejects excess requests immediately rather than queueing them.
+func MiddlewareLimitConnectionRate(OncePer time.Duration, Timeout time.Duration, l *log.Logger) func(http.Handler) http.Handler {
+	logger := optionalLogger(l)
+
+	Store := NewMemoryRateLimitStore()
+	Limit := RateLimit{Requests: 1, Per: OncePer, Burst: 1}
 
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -88,29 +96,44 @@ func MiddlewareLimitConnectionRate(OncePer time.Duration, Timeout time.Duration,
 			if Timeout == 0 {
 				Timeout = time.Hour * 6
 			}
-			timer := time.NewTimer(Timeout)
-
-			// Block on one of two channels...
-			select {
-
-			// If there is room for a request, stop the timer and process it.
-			case <-ticker.C:
-				if !timer.Stop() {
-					<-timer.C
+			Deadline := time.Now().Add(Timeout)
+
+			// Wait for a token to become available, or the timeout to expire.
+			// A non-positive OncePer is not a valid limit, and is not limited.
+			for {
+				Result, err := Store.Take("", Limit)
+				if err != nil || Result.Allowed {
+					break
 				}
-				if logger != nil {
-					logger.Printf("[MiddlewareLimitConnectionRate] Allowing processing of next request from [ %s ]", r.RemoteAddr)
+
+				if time.Now().Add(Result.RetryAfter).After(Deadline) {
+					w.WriteHeader(http.StatusRequestTimeout)
+					if logger != nil {
+						easytls.RequestLogger(r.Context(), logger).Warn("[MiddlewareLimitConnectionRate] Request timed out", "proto", r.Proto, "method", r.Method, "url", r.URL.String(), "remote_addr", r.RemoteAddr)
+					}
+					return
 				}
-				h.ServeHTTP(w, r)
 
-				// If the timer expires, write a timeout response and exit
-			case <-timer.C:
-				w.WriteHeader(http.StatusRequestTimeout)
-				if logger != nil {
-					logger.Printf("[MiddlewareLimitConnectionRate] [ %s ] [ %s ] Request for URL \"%s\" from Address: [ %s ] - Timeout\n", r.Proto, r.Method, r.URL.String(), r.RemoteAddr)
+				select {
+				case <-time.After(Result.RetryAfter):
+				case <-r.Context().Done():
+					return
 				}
-				timer.Stop()
 			}
+
+			if logger != nil {
+				easytls.RequestLogger(r.Context(), logger).Debug("[MiddlewareLimitConnectionRate] Allowing processing of next request", "remote_addr", r.RemoteAddr)
+			}
+			h.ServeHTTP(w, r)
 		})
 	}
 }
+
+// optionalLogger converts the logger given to a middleware, leaving it nil
+// if the middleware should not log at all.
+func optionalLogger(l *log.Logger) easytls.Logger {
+	if l == nil {
+		return nil
+	}
+	return easytls.AsLogger(l)
+}
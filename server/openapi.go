@@ -0,0 +1,582 @@
+package server
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPIVersion is the version of the OpenAPI specification the generated
+// documents conform to.
+const OpenAPIVersion = "3.1.0"
+
+// The default paths the OpenAPI document and viewer are served at.
+const (
+	DefaultOpenAPIPath       = "/openapi.json"
+	DefaultOpenAPIViewerPath = "/openapi"
+)
+
+// securitySchemeMutualTLS is the name of the security scheme applied to routes
+// with an IdentityPolicy.
+const securitySchemeMutualTLS = "mutualTLS"
+
+// Schema is a JSON Schema, as used by OpenAPI to describe parameters and bodies.
+type Schema map[string]interface{}
+
+// APIParameter describes a single path, query, header or cookie parameter
+// of an operation.
+type APIParameter struct {
+	Name        string
+	In          string
+	Description string
+	Required    bool
+	Schema      Schema
+}
+
+// APIBody describes the body of a request or response.
+type APIBody struct {
+	Description string
+
+	// ContentType of the body. Defaults to "application/json" if not set.
+	ContentType string
+
+	Schema   Schema
+	Required bool
+}
+
+// APIOperation describes a route, as published in the OpenAPI document of
+// the server. All fields are optional.
+type APIOperation struct {
+	Summary     string
+	OperationID string
+	Tags        []string
+	Deprecated  bool
+
+	// Parameters of the operation. Path parameters of the route, and its
+	// Queries, are included automatically if not declared here.
+	Parameters []APIParameter
+
+	RequestBody *APIBody
+
+	// Responses of the operation, by status code.
+	Responses map[int]APIBody
+
+	// Security is the set of names of the security schemes, any one of which
+	// must be satisfied to call the operation. Routes with an IdentityPolicy
+	// require mutual TLS regardless.
+	Security []string
+}
+
+// SecurityScheme describes a mechanism for authenticating requests.
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// OpenAPIOptions configures the OpenAPI document of a server.
+type OpenAPIOptions struct {
+	Title       string
+	Version     string
+	Description string
+
+	// Servers are the base URLs the API is available at.
+	Servers []string
+
+	// SecuritySchemes available to operations, by name.
+	SecuritySchemes map[string]SecurityScheme
+
+	// Path to serve the document at. Defaults to DefaultOpenAPIPath if not set.
+	Path string
+
+	// Viewer enables serving a self-contained HTML viewer of the document.
+	Viewer bool
+
+	// ViewerPath to serve the viewer at. Defaults to DefaultOpenAPIViewerPath
+	// if not set.
+	ViewerPath string
+}
+
+// OpenAPIDocument is an OpenAPI document describing the routes of a server.
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Servers    []openAPIServer                         `json:"servers,omitempty"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components *openAPIComponents                      `json:"components,omitempty"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+type openAPIComponents struct {
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type openAPIOperation struct {
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	OperationID string                     `json:"operationId,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Deprecated  bool                       `json:"deprecated,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+	Security    []map[string][]string      `json:"security,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Schema      Schema `json:"schema,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema Schema `json:"schema,omitempty"`
+}
+
+type openAPIRequestBody struct {
+	Description string                      `json:"description,omitempty"`
+	Required    bool                        `json:"required,omitempty"`
+	Content     map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+// allMethods are the methods documented for routes which match any method.
+var allMethods = []string{
+	http.MethodGet,
+	http.MethodPut,
+	http.MethodPost,
+	http.MethodDelete,
+	http.MethodOptions,
+	http.MethodHead,
+	http.MethodPatch,
+}
+
+// pathVariable matches a gorilla/mux path variable, with an optional pattern.
+var pathVariable = regexp.MustCompile(`\{([^{}:]+)(?::[^{}]*)?\}`)
+
+// OpenAPI will build the OpenAPI document describing the routes currently
+// registered with the server.
+func (S *SimpleServer) OpenAPI(Options OpenAPIOptions) *OpenAPIDocument {
+
+	Doc := &OpenAPIDocument{
+		OpenAPI: OpenAPIVersion,
+		Info: openAPIInfo{
+			Title:       Options.Title,
+			Version:     Options.Version,
+			Description: Options.Description,
+		},
+		Paths: make(map[string]map[string]*openAPIOperation),
+	}
+
+	if Doc.Info.Title == "" {
+		Doc.Info.Title = "API"
+	}
+
+	if Doc.Info.Version == "" {
+		Doc.Info.Version = "0.0.0"
+	}
+
+	for _, URL := range Options.Servers {
+		Doc.Servers = append(Doc.Servers, openAPIServer{URL: URL})
+	}
+
+	Schemes := make(map[string]SecurityScheme)
+	for Name, Scheme := range Options.SecuritySchemes {
+		Schemes[Name] = Scheme
+	}
+
+	for _, H := range S.Routes() {
+
+		Route := H.Route()
+		if Route.Path == "" {
+			continue
+		}
+
+		Path, Variables := openAPIPath(Route.Path)
+
+		Methods := Route.Methods
+		if len(Methods) == 0 {
+			Methods = allMethods
+		}
+
+		Operation := newOpenAPIOperation(Route, Variables)
+		if Route.Identity != nil {
+			Schemes[securitySchemeMutualTLS] = SecurityScheme{
+				Type:        securitySchemeMutualTLS,
+				Description: "Requires a verified client certificate.",
+			}
+		}
+
+		if _, exists := Doc.Paths[Path]; !exists {
+			Doc.Paths[Path] = make(map[string]*openAPIOperation)
+		}
+
+		for _, Method := range Methods {
+			Method = strings.ToLower(Method)
+			// Routes are matched in order, so only the first route for a
+			// path and method is ever served.
+			if _, exists := Doc.Paths[Path][Method]; !exists {
+				Doc.Paths[Path][Method] = Operation
+			}
+		}
+	}
+
+	if len(Schemes) > 0 {
+		Doc.Components = &openAPIComponents{SecuritySchemes: Schemes}
+	}
+
+	return Doc
+}
+
+// OpenAPIHandlers will return the SimpleHandlers serving the OpenAPI document
+// of the server, and optionally the HTML viewer of it. The document is built
+// on each request, so it always reflects the current set of routes.
+func (S *SimpleServer) OpenAPIHandlers(Options OpenAPIOptions) []SimpleHandler {
+
+	if Options.Path == "" {
+		Options.Path = DefaultOpenAPIPath
+	}
+
+	if Options.ViewerPath == "" {
+		Options.ViewerPath = DefaultOpenAPIViewerPath
+	}
+
+	Handlers := []SimpleHandler{
+		{
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if r.Method == http.MethodHead {
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+				enc := json.NewEncoder(w)
+				enc.SetIndent("", "\t")
+				enc.SetEscapeHTML(true)
+				enc.Encode(S.OpenAPI(Options))
+			}),
+			Path:        Options.Path,
+			Methods:     []string{http.MethodGet, http.MethodHead},
+			Description: "Serve the OpenAPI document describing the routes of this Server.",
+		},
+	}
+
+	if Options.Viewer {
+		Handlers = append(Handlers, SimpleHandler{
+			Handler:     openAPIViewerHandler(Options),
+			Path:        Options.ViewerPath,
+			Methods:     []string{http.MethodGet, http.MethodHead},
+			Description: "View the OpenAPI document of this Server in a browser.",
+		})
+	}
+
+	return Handlers
+}
+
+// newOpenAPIOperation builds the operation for a route, merging the declared
+// details of the route with those which can be derived from its matchers.
+func newOpenAPIOperation(Route SimpleHandler, PathVariables []string) *openAPIOperation {
+
+	Op := &openAPIOperation{
+		Description: Route.Description,
+		Responses:   make(map[string]openAPIResponse),
+	}
+
+	API := Route.API
+	if API == nil {
+		API = &APIOperation{}
+	}
+
+	Op.Summary = API.Summary
+	Op.OperationID = API.OperationID
+	Op.Tags = API.Tags
+	Op.Deprecated = API.Deprecated
+
+	Declared := make(map[string]bool)
+	for _, P := range API.Parameters {
+		Declared[P.In+":"+P.Name] = true
+		Op.Parameters = append(Op.Parameters, openAPIParameter{
+			Name:        P.Name,
+			In:          P.In,
+			Description: P.Description,
+			Required:    P.Required || P.In == "path",
+			Schema:      P.Schema,
+		})
+	}
+
+	for _, Name := range PathVariables {
+		if !Declared["path:"+Name] {
+			Op.Parameters = append(Op.Parameters, openAPIParameter{
+				Name:     Name,
+				In:       "path",
+				Required: true,
+				Schema:   Schema{"type": "string"},
+			})
+		}
+	}
+
+	for _, Q := range Route.Queries {
+		if Declared["query:"+Q.Key] {
+			continue
+		}
+		P := openAPIParameter{Name: Q.Key, In: "query", Required: true, Schema: Schema{"type": "string"}}
+		if !pathVariable.MatchString(Q.Value) {
+			P.Schema["const"] = Q.Value
+		}
+		Op.Parameters = append(Op.Parameters, P)
+	}
+
+	if API.RequestBody != nil {
+		Op.RequestBody = &openAPIRequestBody{
+			Description: API.RequestBody.Description,
+			Required:    API.RequestBody.Required,
+			Content:     openAPIContent(*API.RequestBody),
+		}
+	}
+
+	for Status, Body := range API.Responses {
+		Response := openAPIResponse{Description: Body.Description}
+		if Response.Description == "" {
+			Response.Description = http.StatusText(Status)
+		}
+		if Body.Schema != nil {
+			Response.Content = openAPIContent(Body)
+		}
+		Op.Responses[strconv.Itoa(Status)] = Response
+	}
+
+	if len(Op.Responses) == 0 {
+		Op.Responses["default"] = openAPIResponse{Description: "Response of the operation."}
+	}
+
+	Security := API.Security
+	if Route.Identity != nil {
+		Security = append(Security, securitySchemeMutualTLS)
+	}
+	for _, Name := range Security {
+		Op.Security = append(Op.Security, map[string][]string{Name: {}})
+	}
+
+	return Op
+}
+
+func openAPIContent(Body APIBody) map[string]openAPIMediaType {
+
+	ContentType := Body.ContentType
+	if ContentType == "" {
+		ContentType = "application/json"
+	}
+
+	return map[string]openAPIMediaType{ContentType: {Schema: Body.Schema}}
+}
+
+// openAPIPath converts a gorilla/mux path template to an OpenAPI path,
+// returning the names of the path variables.
+func openAPIPath(Template string) (string, []string) {
+
+	Variables := []string{}
+	Path := pathVariable.ReplaceAllStringFunc(Template, func(Match string) string {
+		Name := pathVariable.FindStringSubmatch(Match)[1]
+		Variables = append(Variables, Name)
+		return "{" + Name + "}"
+	})
+
+	return Path, Variables
+}
+
+// SchemaOf will generate the JSON Schema of the type of Value, following the
+// encoding/json rules for field names and omitted fields.
+func SchemaOf(Value interface{}) Schema {
+	return schemaOf(reflect.TypeOf(Value), make(map[reflect.Type]bool))
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+)
+
+func schemaOf(T reflect.Type, Seen map[reflect.Type]bool) Schema {
+
+	if T == nil {
+		return Schema{}
+	}
+
+	for T.Kind() == reflect.Ptr {
+		T = T.Elem()
+	}
+
+	switch T {
+	case timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case rawJSONType:
+		return Schema{}
+	}
+
+	switch T.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return Schema{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return Schema{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if T.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "format": "byte"}
+		}
+		return Schema{"type": "array", "items": schemaOf(T.Elem(), Seen)}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": schemaOf(T.Elem(), Seen)}
+	case reflect.Struct:
+		// Recursive types are described only as far as the first repetition.
+		if Seen[T] {
+			return Schema{"type": "object"}
+		}
+		Seen[T] = true
+		defer delete(Seen, T)
+
+		Properties := make(map[string]interface{})
+		Required := []string{}
+		schemaFields(T, Seen, Properties, &Required)
+
+		S := Schema{"type": "object", "properties": Properties}
+		if len(Required) > 0 {
+			sort.Strings(Required)
+			S["required"] = Required
+		}
+		return S
+	default:
+		return Schema{}
+	}
+}
+
+// schemaFields adds the properties of the fields of the struct type, flattening
+// embedded structs as encoding/json does.
+func schemaFields(T reflect.Type, Seen map[reflect.Type]bool, Properties map[string]interface{}, Required *[]string) {
+
+	for i := 0; i < T.NumField(); i++ {
+
+		F := T.Field(i)
+		Tag := F.Tag.Get("json")
+		if Tag == "-" {
+			continue
+		}
+
+		Name, Flags, _ := strings.Cut(Tag, ",")
+
+		if F.Anonymous && Name == "" {
+			FT := F.Type
+			if FT.Kind() == reflect.Ptr {
+				FT = FT.Elem()
+			}
+			if FT.Kind() == reflect.Struct {
+				schemaFields(FT, Seen, Properties, Required)
+				continue
+			}
+		}
+
+		if !F.IsExported() {
+			continue
+		}
+
+		if Name == "" {
+			Name = F.Name
+		}
+
+		Properties[Name] = schemaOf(F.Type, Seen)
+		if !strings.Contains(Flags, "omitempty") && F.Type.Kind() != reflect.Ptr {
+			*Required = append(*Required, Name)
+		}
+	}
+}
+
+// openAPIViewer is a self-contained page rendering the OpenAPI document,
+// requiring no external resources.
+var openAPIViewer = template.Must(template.New("openapi").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+details { border: 1px solid #ccc; border-radius: 4px; margin: 0.5em 0; padding: 0.5em; }
+summary { cursor: pointer; }
+.method { display: inline-block; width: 5em; font-weight: bold; text-transform: uppercase; }
+pre { background: #f6f6f6; padding: 0.5em; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1 id="title">{{.Title}}</h1>
+<p id="description"></p>
+<div id="operations"></div>
+<script>
+fetch({{.Path}}).then(function (r) { return r.json(); }).then(function (doc) {
+	document.getElementById("title").textContent = doc.info.title + " " + doc.info.version;
+	document.getElementById("description").textContent = doc.info.description || "";
+	var root = document.getElementById("operations");
+	Object.keys(doc.paths).sort().forEach(function (path) {
+		Object.keys(doc.paths[path]).forEach(function (method) {
+			var op = doc.paths[path][method];
+			var d = document.createElement("details");
+			var s = document.createElement("summary");
+			var m = document.createElement("span");
+			m.className = "method";
+			m.textContent = method;
+			s.appendChild(m);
+			s.appendChild(document.createTextNode(path + (op.summary ? " - " + op.summary : "")));
+			d.appendChild(s);
+			var p = document.createElement("p");
+			p.textContent = op.description || "";
+			d.appendChild(p);
+			var pre = document.createElement("pre");
+			pre.textContent = JSON.stringify({parameters: op.parameters, requestBody: op.requestBody, responses: op.responses, security: op.security}, null, 2);
+			d.appendChild(pre);
+			root.appendChild(d);
+		});
+	});
+});
+</script>
+</body>
+</html>
+`))
+
+func openAPIViewerHandler(Options OpenAPIOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		Title := Options.Title
+		if Title == "" {
+			Title = "API"
+		}
+		openAPIViewer.Execute(w, struct{ Title, Path string }{Title, Options.Path})
+	})
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+)
+
+type openAPITestItem struct {
+	ID       int               `json:"id"`
+	Name     string            `json:"name,omitempty"`
+	Tags     []string          `json:"tags"`
+	Created  time.Time         `json:"created"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Parent   *openAPITestItem  `json:"parent"`
+	Internal string            `json:"-"`
+}
+
+func TestOpenAPIDocument(T *testing.T) {
+
+	S := NewServerHTTP()
+	S.SetStructuredLogger(easytls.NewDiscardLogger())
+
+	Get := NewSimpleHandler(NotFoundHandler(), "/items/{ID:[0-9]+}", http.MethodGet)
+	Get.AddDescription("Get a single item.")
+	Get.RequireIdentity(IdentityPolicy{Allow: []IdentityRule{{CommonNames: []string{"client"}}}})
+	Get.DescribeAPI(APIOperation{
+		Summary:   "Get item",
+		Responses: map[int]APIBody{http.StatusOK: {Schema: SchemaOf(openAPITestItem{})}},
+	})
+
+	List := NewSimpleHandler(NotFoundHandler(), "/items", http.MethodGet)
+	List.AddQueries("page", "{page}")
+
+	S.AddHandlers(S.Router(), Get, List)
+	S.AddHandlers(S.Router(), S.OpenAPIHandlers(OpenAPIOptions{Title: "Items", Version: "1.0.0", Viewer: true})...)
+
+	w := httptest.NewRecorder()
+	S.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, DefaultOpenAPIPath, nil))
+
+	Doc := map[string]interface{}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &Doc); err != nil {
+		T.Fatalf("Failed to decode OpenAPI document: %v\n%s", err, w.Body.String())
+	}
+
+	Lookup := func(Path ...string) interface{} {
+		var V interface{} = Doc
+		for _, Key := range Path {
+			M, ok := V.(map[string]interface{})
+			if !ok {
+				T.Fatalf("Expected an object at %v in the document:\n%s", Path, w.Body.String())
+			}
+			V = M[Key]
+		}
+		return V
+	}
+
+	if Lookup("openapi") != OpenAPIVersion || Lookup("info", "title") != "Items" {
+		T.Fatalf("Unexpected document header:\n%s", w.Body.String())
+	}
+
+	if Lookup("paths", "/items/{ID}", "get", "summary") != "Get item" {
+		T.Fatalf("Expected the path template to be converted, and the declared summary used:\n%s", w.Body.String())
+	}
+
+	Parameters := Lookup("paths", "/items/{ID}", "get", "parameters").([]interface{})
+	if len(Parameters) != 1 || Parameters[0].(map[string]interface{})["in"] != "path" {
+		T.Fatalf("Expected the path variable as a parameter, got %v", Parameters)
+	}
+
+	if Lookup("paths", "/items/{ID}", "get", "responses", "200", "content", "application/json", "schema", "properties", "created", "format") != "date-time" {
+		T.Fatalf("Expected the response schema to be generated from the type:\n%s", w.Body.String())
+	}
+
+	if Lookup("components", "securitySchemes", "mutualTLS", "type") != "mutualTLS" {
+		T.Fatalf("Expected the identity policy to require mutual TLS:\n%s", w.Body.String())
+	}
+
+	Query := Lookup("paths", "/items", "get", "parameters").([]interface{})[0].(map[string]interface{})
+	if Query["name"] != "page" || Query["in"] != "query" {
+		T.Fatalf("Expected the route query as a parameter, got %v", Query)
+	}
+
+	w = httptest.NewRecorder()
+	S.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, DefaultOpenAPIViewerPath, nil))
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") || !strings.Contains(w.Body.String(), DefaultOpenAPIPath) {
+		T.Fatalf("Expected the viewer to reference the document:\n%s", w.Body.String())
+	}
+}
+
+func TestSchemaOf(T *testing.T) {
+
+	S := SchemaOf(&openAPITestItem{})
+
+	Properties := S["properties"].(map[string]interface{})
+	if _, ok := Properties["Internal"]; ok {
+		T.Fatalf("Expected fields tagged \"-\" to be omitted")
+	}
+
+	if !reflect.DeepEqual(S["required"], []string{"created", "id", "tags"}) {
+		T.Fatalf("Unexpected required fields: %v", S["required"])
+	}
+
+	if Properties["parent"].(Schema)["type"] != "object" {
+		T.Fatalf("Expected the recursive field to be described as an object")
+	}
+
+	if Properties["tags"].(Schema)["items"].(Schema)["type"] != "string" {
+		T.Fatalf("Expected the slice items to be described")
+	}
+}
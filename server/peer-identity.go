@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+)
+
+// contextKey is the type used for all values this package stores in a
+// request context, to prevent collisions with other packages.
+type contextKey int
+
+const (
+	peerIdentityKey contextKey = iota
+)
+
+// PeerIdentity is the verified identity of the client certificate presented
+// alongside a request.
+type PeerIdentity struct {
+	CommonName          string
+	OrganizationalUnits []string
+	DNSNames            []string
+	URIs                []string
+
+	// Issuer is the full Distinguished Name of the issuing authority.
+	Issuer string
+
+	// Fingerprint is the hex-encoded SHA-256 digest of the Subject Public Key Info.
+	Fingerprint string
+
+	// The full certificate the identity was extracted from.
+	Certificate *x509.Certificate `json:"-"`
+}
+
+// IdentityRule describes a set of certificate identities. Within a rule, every
+// non-empty field must match, while matching any one of the values listed
+// for a given field is sufficient.
+type IdentityRule struct {
+	CommonNames         []string `json:",omitempty"`
+	OrganizationalUnits []string `json:",omitempty"`
+	DNSNames            []string `json:",omitempty"`
+	URIs                []string `json:",omitempty"`
+
+	// Issuers may be either the Common Name or full Distinguished Name of the issuer.
+	Issuers []string `json:",omitempty"`
+
+	// Fingerprints are hex-encoded SHA-256 digests of the Subject Public Key
+	// Info, with or without ":" separators.
+	Fingerprints []string `json:",omitempty"`
+}
+
+// IdentityPolicy defines which client certificate identities are authorized.
+//
+// A request is rejected if its identity matches any of the Deny rules. If
+// there are any Allow rules, the identity must then match at least one of
+// them. A request without a verified client certificate is always rejected.
+type IdentityPolicy struct {
+	Allow []IdentityRule `json:",omitempty"`
+	Deny  []IdentityRule `json:",omitempty"`
+}
+
+// NewPeerIdentity extracts the identity of a certificate.
+func NewPeerIdentity(Cert *x509.Certificate) PeerIdentity {
+
+	Fingerprint := sha256.Sum256(Cert.RawSubjectPublicKeyInfo)
+
+	Identity := PeerIdentity{
+		CommonName:          Cert.Subject.CommonName,
+		OrganizationalUnits: Cert.Subject.OrganizationalUnit,
+		DNSNames:            Cert.DNSNames,
+		Issuer:              Cert.Issuer.String(),
+		Fingerprint:         hex.EncodeToString(Fingerprint[:]),
+		Certificate:         Cert,
+	}
+
+	for _, URI := range Cert.URIs {
+		Identity.URIs = append(Identity.URIs, URI.String())
+	}
+
+	return Identity
+}
+
+// PeerIdentityFromContext will return the verified client identity stored in
+// the context by MiddlewareRequireIdentity, if any.
+func PeerIdentityFromContext(ctx context.Context) (PeerIdentity, bool) {
+	Identity, ok := ctx.Value(peerIdentityKey).(PeerIdentity)
+	return Identity, ok
+}
+
+// PeerIdentityFromRequest will return the identity of the verified client
+// certificate presented with the request, if any.
+func PeerIdentityFromRequest(r *http.Request) (PeerIdentity, bool) {
+
+	if Identity, ok := PeerIdentityFromContext(r.Context()); ok {
+		return Identity, true
+	}
+
+	// Only trust certificates which were verified during the handshake.
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return PeerIdentity{}, false
+	}
+
+	return NewPeerIdentity(r.TLS.VerifiedChains[0][0]), true
+}
+
+// Authorizes determines whether the given identity is allowed by the policy.
+func (P *IdentityPolicy) Authorizes(Identity PeerIdentity) bool {
+
+	for _, Rule := range P.Deny {
+		if Rule.Matches(Identity) {
+			return false
+		}
+	}
+
+	if len(P.Allow) == 0 {
+		return true
+	}
+
+	for _, Rule := range P.Allow {
+		if Rule.Matches(Identity) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Matches determines whether the given identity satisfies every field of the rule.
+func (R *IdentityRule) Matches(Identity PeerIdentity) bool {
+
+	IssuerCN := ""
+	if Identity.Certificate != nil {
+		IssuerCN = Identity.Certificate.Issuer.CommonName
+	}
+
+	return matchesAny(R.CommonNames, Identity.CommonName) &&
+		matchesAny(R.OrganizationalUnits, Identity.OrganizationalUnits...) &&
+		matchesAny(R.DNSNames, Identity.DNSNames...) &&
+		matchesAny(R.URIs, Identity.URIs...) &&
+		matchesAny(R.Issuers, IssuerCN, Identity.Issuer) &&
+		matchesAny(normalizeFingerprints(R.Fingerprints), Identity.Fingerprint)
+}
+
+// matchesAny returns true if there are no Allowed values, or if any of
+// the Values is in the Allowed set.
+func matchesAny(Allowed []string, Values ...string) bool {
+
+	if len(Allowed) == 0 {
+		return true
+	}
+
+	for _, A := range Allowed {
+		for _, V := range Values {
+			if V != "" && A == V {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func normalizeFingerprints(Fingerprints []string) []string {
+
+	Normalized := make([]string, 0, len(Fingerprints))
+	for _, F := range Fingerprints {
+		Normalized = append(Normalized, strings.ToLower(strings.ReplaceAll(F, ":", "")))
+	}
+
+	return Normalized
+}
+
+// MiddlewareRequireIdentity provides a middleware to authorize requests based
+// on the identity of the verified client certificate presented with them.
+// Unauthorized requests receive a 403 response, and are logged. The identity
+// of authorized requests is stored in the request context, available with
+// PeerIdentityFromContext.
+//
+// This requires the server TLSBundle to request and verify client certificates.
+func MiddlewareRequireIdentity(Policy IdentityPolicy, logger easytls.Logger) MiddlewareHandler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			Identity, ok := PeerIdentityFromRequest(r)
+			if !ok {
+				if logger != nil {
+					easytls.RequestLogger(r.Context(), logger).Warn("[MiddlewareRequireIdentity] Rejected request without a verified client certificate", "method", r.Method, "url", r.URL.String(), "remote_addr", r.RemoteAddr)
+				}
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			if !Policy.Authorizes(Identity) {
+				if logger != nil {
+					easytls.RequestLogger(r.Context(), logger).Warn("[MiddlewareRequireIdentity] Rejected request from unauthorized identity", "method", r.Method, "url", r.URL.String(), "remote_addr", r.RemoteAddr, "identity", Identity.CommonName)
+				}
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), peerIdentityKey, Identity)))
+		})
+	}
+}
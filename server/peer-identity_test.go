@@ -0,0 +1,81 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+)
+
+func TestMiddlewareRequireIdentity(T *testing.T) {
+
+	CA, err := easytls.NewCertificateAuthority("EasyTLS Test CA", time.Hour)
+	if err != nil {
+		T.Fatalf("Failed to create CA - %s", err)
+	}
+
+	SPIFFE, _ := url.Parse("spiffe://corp/ingest")
+
+	Billing, err := CA.IssueClientCertificate(easytls.CertificateOptions{CommonName: "billing-svc", OrganizationalUnit: []string{"finance"}})
+	if err != nil {
+		T.Fatalf("Failed to issue certificate - %s", err)
+	}
+
+	Ingest, err := CA.IssueClientCertificate(easytls.CertificateOptions{CommonName: "ingest", URIs: []*url.URL{SPIFFE}})
+	if err != nil {
+		T.Fatalf("Failed to issue certificate - %s", err)
+	}
+
+	Other, err := CA.IssueClientCertificate(easytls.CertificateOptions{CommonName: "other-svc", OrganizationalUnit: []string{"finance"}})
+	if err != nil {
+		T.Fatalf("Failed to issue certificate - %s", err)
+	}
+
+	Policy := IdentityPolicy{
+		Allow: []IdentityRule{
+			{CommonNames: []string{"billing-svc"}, Issuers: []string{"EasyTLS Test CA"}},
+			{URIs: []string{"spiffe://corp/ingest"}},
+			{OrganizationalUnits: []string{"finance"}},
+		},
+		Deny: []IdentityRule{
+			{Fingerprints: []string{NewPeerIdentity(Other.Certificate).Fingerprint}},
+		},
+	}
+
+	Handler := MiddlewareRequireIdentity(Policy, easytls.NewDiscardLogger())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Identity, ok := PeerIdentityFromContext(r.Context())
+		if !ok {
+			T.Errorf("Expected identity to be stored in the request context")
+		}
+		w.Write([]byte(Identity.CommonName))
+	}))
+
+	for _, Case := range []struct {
+		Name     string
+		Cert     *x509.Certificate
+		Expected int
+	}{
+		{"Allowed by Common Name", Billing.Certificate, http.StatusOK},
+		{"Allowed by URI SAN", Ingest.Certificate, http.StatusOK},
+		{"Denied by fingerprint", Other.Certificate, http.StatusForbidden},
+		{"No certificate", nil, http.StatusForbidden},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.TLS = &tls.ConnectionState{}
+		if Case.Cert != nil {
+			r.TLS.VerifiedChains = [][]*x509.Certificate{{Case.Cert, CA.Certificate}}
+		}
+
+		w := httptest.NewRecorder()
+		Handler.ServeHTTP(w, r)
+
+		if w.Code != Case.Expected {
+			T.Errorf("[ %s ] Expected status [ %d ], got [ %d ]", Case.Name, Case.Expected, w.Code)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+)
+
+// RateLimit defines a token bucket. The bucket holds up to Burst tokens, and
+// is refilled at a rate of Requests tokens every Per. Each request consumes a
+// single token, and is rejected if the bucket is empty. Requests and Per must
+// both be positive.
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+	Burst    int
+}
+
+// Validate will check that the bucket is refilled at a positive rate.
+func (L RateLimit) Validate() error {
+	if L.Requests <= 0 || L.Per <= 0 {
+		return errors.New("server error: Rate limit must allow a positive number of Requests Per a positive duration")
+	}
+	return nil
+}
+
+// rate returns the refill rate of the bucket, in tokens per second.
+func (L RateLimit) rate() float64 {
+	return float64(L.Requests) / L.Per.Seconds()
+}
+
+// capacity returns the size of the bucket, defaulting to Requests if no
+// explicit Burst is given.
+func (L RateLimit) capacity() int {
+	switch {
+	case L.Burst > 0:
+		return L.Burst
+	default:
+		return L.Requests
+	}
+}
+
+// RateLimitResult is the outcome of attempting to take a token from a bucket.
+type RateLimitResult struct {
+
+	// Allowed indicates a token was taken, and the request may proceed.
+	Allowed bool
+
+	// Remaining is the number of whole tokens left in the bucket.
+	Remaining int
+
+	// RetryAfter is how long until the next token is available, if the
+	// request was not allowed.
+	RetryAfter time.Duration
+
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// RateLimitStore holds the state of the token buckets of a rate limiter.
+// Implementations must be safe for concurrent use, and may share the buckets
+// between multiple server instances.
+type RateLimitStore interface {
+
+	// Take attempts to take a single token from the bucket identified by Key,
+	// creating a full bucket if none exists. An error is returned if Limit
+	// is not valid.
+	Take(Key string, Limit RateLimit) (RateLimitResult, error)
+}
+
+// RateLimitKeyFunc maps a request to the key of the bucket it is limited by.
+type RateLimitKeyFunc func(*http.Request) string
+
+// RateLimitByRemoteIP limits each client IP address separately.
+func RateLimitByRemoteIP() RateLimitKeyFunc {
+	return remoteIP
+}
+
+// RateLimitByIdentity limits each verified client certificate separately,
+// identified by its fingerprint. Requests without a verified certificate are
+// limited by their IP address.
+func RateLimitByIdentity() RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		if Identity, ok := PeerIdentityFromRequest(r); ok {
+			return "identity:" + Identity.Fingerprint
+		}
+		return "ip:" + remoteIP(r)
+	}
+}
+
+// RateLimitByHeader limits each value of the given header separately, such
+// as an API key. Requests without the header are limited by their IP address.
+func RateLimitByHeader(Name string) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		if Value := r.Header.Get(Name); Value != "" {
+			return "header:" + Value
+		}
+		return "ip:" + remoteIP(r)
+	}
+}
+
+// RateLimitByRoute limits each route template separately, shared between all clients.
+func RateLimitByRoute() RateLimitKeyFunc {
+	return routeTemplate
+}
+
+// RateLimitOptions configures a rate limiter created by MiddlewareRateLimit.
+type RateLimitOptions struct {
+
+	// Limit is the token bucket each key is limited by.
+	Limit RateLimit
+
+	// Key maps requests to buckets. Defaults to RateLimitByRemoteIP if not set.
+	Key RateLimitKeyFunc
+
+	// Store holds the state of the buckets. Defaults to a new
+	// MemoryRateLimitStore if not set.
+	Store RateLimitStore
+
+	// Name is prepended to the keys of the buckets, to allow several
+	// limiters to share a single Store.
+	Name string
+}
+
+// MiddlewareRateLimit provides a token-bucket rate limiting middleware.
+// Requests exceeding the limit of their bucket receive a 429 response, with a
+// Retry-After header. All responses include the RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers.
+//
+// If the Store fails, the request is allowed, and the error is logged. This
+// includes a Limit which does not pass RateLimit.Validate.
+func MiddlewareRateLimit(Options RateLimitOptions, logger easytls.Logger) MiddlewareHandler {
+
+	if Options.Key == nil {
+		Options.Key = RateLimitByRemoteIP()
+	}
+
+	if Options.Store == nil {
+		Options.Store = NewMemoryRateLimitStore()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			Key := Options.Name + "|" + Options.Key(r)
+
+			Result, err := Options.Store.Take(Key, Options.Limit)
+			if err != nil {
+				if logger != nil {
+					easytls.RequestLogger(r.Context(), logger).Error("[MiddlewareRateLimit] Failed to check rate limit, allowing request", "key", Key, "error", err)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			H := w.Header()
+			H.Set("RateLimit-Limit", strconv.Itoa(Options.Limit.capacity()))
+			H.Set("RateLimit-Remaining", strconv.Itoa(Result.Remaining))
+			H.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(Result.Reset)))
+
+			if !Result.Allowed {
+				H.Set("Retry-After", strconv.Itoa(ceilSeconds(Result.RetryAfter)))
+				w.WriteHeader(http.StatusTooManyRequests)
+				if logger != nil {
+					easytls.RequestLogger(r.Context(), logger).Warn("[MiddlewareRateLimit] Rejected request exceeding rate limit", "key", Key, "method", r.Method, "url", r.URL.String(), "remote_addr", r.RemoteAddr)
+				}
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// tokenBucket is the state of a single bucket in a MemoryRateLimitStore.
+type tokenBucket struct {
+	tokens   float64
+	updated  time.Time
+	rate     float64
+	capacity float64
+}
+
+// MemoryRateLimitStore is a RateLimitStore holding the buckets in memory,
+// local to a single server. Buckets which have refilled completely are
+// periodically discarded.
+type MemoryRateLimitStore struct {
+	mu        *sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// NewMemoryRateLimitStore will create a new, empty MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		mu:        &sync.Mutex{},
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Take implements the RateLimitStore interface.
+func (S *MemoryRateLimitStore) Take(Key string, Limit RateLimit) (RateLimitResult, error) {
+
+	if err := Limit.Validate(); err != nil {
+		return RateLimitResult{}, err
+	}
+
+	S.mu.Lock()
+	defer S.mu.Unlock()
+
+	Now := time.Now()
+	Rate := Limit.rate()
+	Capacity := float64(Limit.capacity())
+
+	if Now.Sub(S.lastSweep) > time.Minute {
+		S.sweep(Now)
+	}
+
+	B, ok := S.buckets[Key]
+	if !ok {
+		B = &tokenBucket{tokens: Capacity, updated: Now}
+		S.buckets[Key] = B
+	}
+	B.rate, B.capacity = Rate, Capacity
+
+	// Refill the bucket for the time since it was last used.
+	B.tokens = math.Min(Capacity, B.tokens+Now.Sub(B.updated).Seconds()*Rate)
+	B.updated = Now
+
+	Result := RateLimitResult{}
+	if B.tokens >= 1 {
+		B.tokens--
+		Result.Allowed = true
+	} else {
+		Result.RetryAfter = tokenDelay(1-B.tokens, Rate)
+	}
+
+	Result.Remaining = int(B.tokens)
+	Result.Reset = tokenDelay(Capacity-B.tokens, Rate)
+
+	return Result, nil
+}
+
+// sweep discards all buckets which would have refilled completely. This
+// must be called with the lock held.
+func (S *MemoryRateLimitStore) sweep(Now time.Time) {
+	S.lastSweep = Now
+	for Key, B := range S.buckets {
+		if B.tokens+Now.Sub(B.updated).Seconds()*B.rate >= B.capacity {
+			delete(S.buckets, Key)
+		}
+	}
+}
+
+// tokenDelay returns how long it takes to refill the given number of tokens.
+func tokenDelay(Tokens, Rate float64) time.Duration {
+	if Tokens <= 0 {
+		return 0
+	}
+	return time.Duration(Tokens / Rate * float64(time.Second))
+}
+
+// ceilSeconds rounds a duration up to a whole number of seconds.
+func ceilSeconds(D time.Duration) int {
+	return int(math.Ceil(D.Seconds()))
+}
+
+// remoteIP returns the IP address of the client making the request.
+func remoteIP(r *http.Request) string {
+	Host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return Host
+}
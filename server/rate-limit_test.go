@@ -0,0 +1,100 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+)
+
+func TestMiddlewareRateLimit(T *testing.T) {
+
+	Handler := MiddlewareRateLimit(RateLimitOptions{
+		Limit: RateLimit{Requests: 1, Per: time.Hour, Burst: 2},
+	}, easytls.NewDiscardLogger())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	Send := func(RemoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = RemoteAddr
+		w := httptest.NewRecorder()
+		Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// The burst is allowed through immediately.
+	for i, Remaining := range []string{"1", "0"} {
+		w := Send("10.0.0.1:1234")
+		if w.Code != http.StatusOK {
+			T.Fatalf("Expected request %d of the burst to be allowed, got status %d", i, w.Code)
+		}
+		if w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != Remaining {
+			T.Fatalf("Expected RateLimit-Limit 2 and RateLimit-Remaining %s, got %s and %s", Remaining, w.Header().Get("RateLimit-Limit"), w.Header().Get("RateLimit-Remaining"))
+		}
+	}
+
+	w := Send("10.0.0.1:5678")
+	if w.Code != http.StatusTooManyRequests {
+		T.Fatalf("Expected the request exceeding the burst to be rejected, got status %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		T.Fatalf("Expected a Retry-After header on the rejected request")
+	}
+
+	// Other clients have their own bucket.
+	if w := Send("10.0.0.2:1234"); w.Code != http.StatusOK {
+		T.Fatalf("Expected a request from another client to be allowed, got status %d", w.Code)
+	}
+}
+
+func TestMemoryRateLimitStoreRefill(T *testing.T) {
+
+	Store := NewMemoryRateLimitStore()
+	Limit := RateLimit{Requests: 1, Per: time.Millisecond * 20}
+
+	if Result, _ := Store.Take("key", Limit); !Result.Allowed {
+		T.Fatalf("Expected the first request to be allowed")
+	}
+
+	Result, _ := Store.Take("key", Limit)
+	if Result.Allowed {
+		T.Fatalf("Expected the second request to be rejected")
+	}
+	if Result.RetryAfter <= 0 || Result.RetryAfter > Limit.Per {
+		T.Fatalf("Expected a RetryAfter within %v, got %v", Limit.Per, Result.RetryAfter)
+	}
+
+	time.Sleep(Result.RetryAfter)
+
+	if Result, _ := Store.Take("key", Limit); !Result.Allowed {
+		T.Fatalf("Expected a request to be allowed once the bucket has refilled")
+	}
+}
+
+func TestRateLimitInvalid(T *testing.T) {
+
+	Store := NewMemoryRateLimitStore()
+
+	for _, Limit := range []RateLimit{
+		{},
+		{Requests: 0, Per: time.Second, Burst: 5},
+		{Requests: 10, Per: 0},
+		{Requests: -1, Per: time.Second},
+		{Requests: 1, Per: -time.Second},
+	} {
+		if _, err := Store.Take("key", Limit); err == nil {
+			T.Fatalf("Expected the limit %+v to be rejected", Limit)
+		}
+	}
+
+	// The middleware allows requests it cannot check, without a Retry-After.
+	Handler := MiddlewareRateLimit(RateLimitOptions{Store: Store}, easytls.NewDiscardLogger())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusOK || w.Header().Get("Retry-After") != "" {
+			T.Fatalf("Expected request %d to be allowed without a Retry-After, got status %d and Retry-After [ %s ]", i, w.Code, w.Header().Get("Retry-After"))
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+)
+
+// ProblemContentType is the media type of the error responses written by
+// WriteProblem, as defined by RFC 7807.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object, describing why a request failed.
+type Problem struct {
+	Type      string `json:"type,omitempty"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// WriteProblem will write a problem details response with the given status
+// code and detail message. The title is the standard text of the status, and
+// the ID of the request is included if one was assigned by MiddlewareAccessLog.
+func WriteProblem(w http.ResponseWriter, r *http.Request, StatusCode int, Detail string) {
+
+	P := Problem{
+		Title:    http.StatusText(StatusCode),
+		Status:   StatusCode,
+		Detail:   Detail,
+		Instance: r.URL.Path,
+	}
+	P.RequestID, _ = RequestIDFromContext(r.Context())
+
+	H := w.Header()
+	H.Del("Content-Length")
+	H.Set("Content-Type", ProblemContentType)
+	H.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(StatusCode)
+	json.NewEncoder(w).Encode(P)
+}
+
+// PanicHook is called with every panic recovered by MiddlewareRecovery,
+// along with the stack of the goroutine which panicked.
+type PanicHook = func(r *http.Request, Recovered interface{}, Stack []byte)
+
+// RecoveryOptions configures the middleware created by MiddlewareRecovery.
+type RecoveryOptions struct {
+
+	// OnPanic, if set, is called with every panic recovered, such as to
+	// report it to an error tracking service.
+	OnPanic PanicHook
+
+	// ShowDetail includes the value the handler panicked with in the
+	// response. This should only be used during development, as the value
+	// may expose internal details of the server.
+	ShowDetail bool
+}
+
+// MiddlewareRecovery provides a middleware which recovers from panics in the
+// handlers it wraps. The panic is logged with its stack, the ID of the request
+// and the matched route, and a 500 problem details response is written.
+//
+// If the handler had already started writing its response, the connection is
+// instead aborted, so the client does not mistake the partial response for a
+// complete one. Panics with http.ErrAbortHandler are passed through, as they
+// are used to deliberately abort a response.
+func MiddlewareRecovery(logger easytls.Logger, Options RecoveryOptions) MiddlewareHandler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			Recorder, Writer := newResponseRecorder(w)
+
+			defer func() {
+				Recovered := recover()
+				if Recovered == nil {
+					return
+				}
+				if Recovered == http.ErrAbortHandler {
+					panic(Recovered)
+				}
+
+				Stack := debug.Stack()
+
+				if logger != nil {
+					easytls.RequestLogger(r.Context(), logger).Error("[MiddlewareRecovery] Recovered from panic in handler", "panic", fmt.Sprint(Recovered), "method", r.Method, "route", routeTemplate(r), "url", r.URL.String(), "remote_addr", r.RemoteAddr, "stack", string(Stack))
+				}
+
+				if Options.OnPanic != nil {
+					Options.OnPanic(r, Recovered, Stack)
+				}
+
+				if Recorder.hijacked {
+					return
+				}
+
+				if Recorder.status != 0 {
+					panic(http.ErrAbortHandler)
+				}
+
+				Detail := ""
+				if Options.ShowDetail {
+					Detail = fmt.Sprint(Recovered)
+				}
+				WriteProblem(w, r, http.StatusInternalServerError, Detail)
+			}()
+
+			next.ServeHTTP(Writer, r)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+)
+
+func TestMiddlewareRecovery(T *testing.T) {
+
+	var Hooked interface{}
+	Recovery := MiddlewareRecovery(easytls.NewDiscardLogger(), RecoveryOptions{
+		OnPanic: func(r *http.Request, Recovered interface{}, Stack []byte) {
+			Hooked = Recovered
+		},
+	})
+
+	Handler := MiddlewareAccessLog(easytls.NewDiscardLogger(), AccessLogOptions{})(Recovery(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("broken handler")
+	})))
+
+	r := httptest.NewRequest(http.MethodGet, "/broken", nil)
+	r.Header.Set(DefaultRequestIDHeader, "abc123")
+	w := httptest.NewRecorder()
+	Handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Type") != ProblemContentType {
+		T.Fatalf("Expected a 500 problem response, got %d %v", w.Code, w.Header())
+	}
+
+	P := Problem{}
+	if err := json.Unmarshal(w.Body.Bytes(), &P); err != nil {
+		T.Fatalf("Failed to decode problem response: %v", err)
+	}
+
+	if P.Status != http.StatusInternalServerError || P.RequestID != "abc123" || P.Instance != "/broken" || P.Detail != "" {
+		T.Fatalf("Unexpected problem response: %+v", P)
+	}
+
+	if Hooked != "broken handler" {
+		T.Fatalf("Expected the panic hook to be called, got %v", Hooked)
+	}
+}
+
+func TestMiddlewareRecoveryAfterWrite(T *testing.T) {
+
+	Handler := MiddlewareRecovery(nil, RecoveryOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "partial")
+		panic("broken handler")
+	}))
+
+	defer func() {
+		if Recovered := recover(); Recovered != http.ErrAbortHandler {
+			T.Fatalf("Expected the response to be aborted, got %v", Recovered)
+		}
+	}()
+
+	Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	T.Fatalf("Expected a partially written response to be aborted")
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+)
+
+// maxRequestIDLength is the longest request ID accepted from a client.
+const maxRequestIDLength = 128
+
+// MiddlewareRequestID provides a middleware which assigns an ID to every
+// request, accepting the one sent by the client in the given header, or
+// generating a new one if there is none. The ID is echoed back in the
+// response, stored in the request context for RequestIDFromContext, and
+// forwarded by SimpleClients and the reverse proxy for requests made with
+// that context. The DefaultRequestIDHeader is used if none is given.
+//
+// IDs sent by clients are only accepted if they are at most 128 printable
+// ASCII characters, so they are safe to write to logs.
+func MiddlewareRequestID(Header string) MiddlewareHandler {
+
+	if Header == "" {
+		Header = DefaultRequestIDHeader
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r, _ = withRequestID(w, r, Header)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequestIDFromContext returns the ID assigned to a request by
+// MiddlewareRequestID or MiddlewareAccessLog, if any.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	return easytls.RequestIDFromContext(ctx)
+}
+
+// withRequestID returns the request with its ID stored in the context, along
+// with the ID. An ID already assigned by an earlier middleware is kept.
+func withRequestID(w http.ResponseWriter, r *http.Request, Header string) (*http.Request, string) {
+
+	ID, ok := easytls.RequestIDFromContext(r.Context())
+	if !ok {
+		ID = r.Header.Get(Header)
+		if !validRequestID(ID) {
+			ID = easytls.NewRequestID()
+		}
+		r = r.WithContext(easytls.ContextWithRequestID(r.Context(), ID))
+	}
+
+	r.Header.Set(Header, ID)
+	w.Header().Set(Header, ID)
+
+	return r, ID
+}
+
+func validRequestID(ID string) bool {
+
+	if ID == "" || len(ID) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(ID); i++ {
+		if ID[i] < '!' || ID[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Bearnie-H/easy-tls/client"
+)
+
+func TestMiddlewareRequestIDPropagation(T *testing.T) {
+
+	Forwarded := make(chan string, 1)
+	Upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Forwarded <- r.Header.Get(DefaultRequestIDHeader)
+	}))
+	defer Upstream.Close()
+
+	C := client.NewClientHTTP()
+	Handler := MiddlewareRequestID("")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := C.GetContext(r.Context(), Upstream.URL, nil)
+		if err != nil {
+			T.Errorf("Failed to perform upstream request: %v", err)
+			return
+		}
+		resp.Body.Close()
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(DefaultRequestIDHeader, "abc123")
+	w := httptest.NewRecorder()
+	Handler.ServeHTTP(w, r)
+
+	if ID := <-Forwarded; ID != "abc123" {
+		T.Fatalf("Expected the request ID to be forwarded upstream, got [ %s ]", ID)
+	}
+
+	if w.Header().Get(DefaultRequestIDHeader) != "abc123" {
+		T.Fatalf("Expected the request ID to be echoed in the response")
+	}
+}
+
+func TestMiddlewareRequestIDRejectsInvalid(T *testing.T) {
+
+	var Assigned string
+	Handler := MiddlewareRequestID("")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Assigned, _ = RequestIDFromContext(r.Context())
+	}))
+
+	for _, ID := range []string{"has spaces\ninjected", strings.Repeat("a", maxRequestIDLength+1)} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(DefaultRequestIDHeader, ID)
+		Handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		if Assigned == ID || len(Assigned) != 32 {
+			T.Fatalf("Expected an invalid request ID to be replaced, got [ %s ]", Assigned)
+		}
+	}
+}
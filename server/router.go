@@ -1,8 +1,9 @@
 package server
 
 import (
-	"fmt"
+	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/gorilla/mux"
 )
@@ -36,15 +37,17 @@ func (S *SimpleServer) AddMiddlewares(middlewares ...MiddlewareHandler) {
 
 // AddHandlers will add the given handlers to the router, with the verbose
 // flag determining if a log message should be generated for each added route.
-func (S *SimpleServer) AddHandlers(Router *mux.Router, Handlers ...SimpleHandler) {
-	S.addHandlers(Router, Handlers...)
+// The returned handles allow each route to be replaced, disabled or removed
+// while the server is running.
+func (S *SimpleServer) AddHandlers(Router *mux.Router, Handlers ...SimpleHandler) []*RouteHandle {
+	return S.addHandlers(Router, Handlers...)
 }
 
 // AddSubrouter will add the set of Handlers to the server by creating a dedicated Subrouter
 // of the given Router.
 //
 // Currently, these subrouters are defined based on PathPrefix.
-func (S *SimpleServer) AddSubrouter(Router *mux.Router, PathPrefix string, Handlers ...SimpleHandler) {
+func (S *SimpleServer) AddSubrouter(Router *mux.Router, PathPrefix string, Handlers ...SimpleHandler) []*RouteHandle {
 
 	if !strings.HasSuffix(PathPrefix, "/") {
 		PathPrefix += "/"
@@ -55,52 +58,164 @@ func (S *SimpleServer) AddSubrouter(Router *mux.Router, PathPrefix string, Handl
 	}
 
 	// Don't create subrouters for URLRoots
-	if PathPrefix == "/" {
-		S.AddHandlers(Router, Handlers...)
-	} else {
-		s := Router.PathPrefix(PathPrefix).Subrouter()
-		S.Logger().Printf("Creating subrouter for PathPrefix [ %s ] on Server at [ %s ]", PathPrefix, S.Addr())
-		S.addHandlers(s, Handlers...)
+	if PathPrefix != "/" {
+		S.StructuredLogger().Info("Creating subrouter", "path_prefix", PathPrefix, "addr", S.Addr())
 	}
+
+	return S.addHandlers(Router, Handlers...)
 }
 
-func (S *SimpleServer) addHandlers(Router *mux.Router, Handlers ...SimpleHandler) {
-	// Register the routes, this IS order dependent.
-	for _, Node := range Handlers {
+// addHandlers will add routes for the Handlers after all others. Every route
+// is matched by the router of the server, whichever Router is given.
+func (S *SimpleServer) addHandlers(Router *mux.Router, Handlers ...SimpleHandler) []*RouteHandle {
+	return S.ReplaceRoutes(nil, Handlers...)
+}
 
-		RouteDescriptor := ""
+// ReplaceRoutes will atomically remove the Old routes and add routes for the
+// Handlers in their place, so the new routes are matched in the same order
+// the old ones were, ahead of any routes added since. If none of the Old
+// routes are still registered, the new routes are added after all others, as
+// by AddHandlers. Requests are served by either the old or the new routes
+// throughout, never by neither.
+func (S *SimpleServer) ReplaceRoutes(Old []*RouteHandle, Handlers ...SimpleHandler) []*RouteHandle {
 
-		// Create a route for the handler
-		Route := S.router.NewRoute().Handler(Node.Handler)
+	Handles := make([]*RouteHandle, 0, len(Handlers))
+	for _, Node := range Handlers {
+		Handles = append(Handles, S.newRouteHandle(Node))
+	}
 
-		// Assign the path
-		if Node.Path != "" {
-			Route = Route.PathPrefix(Node.Path)
-			RouteDescriptor = fmt.Sprintf("%s[ %s ] ", RouteDescriptor, Node.Path)
+	Removed := []*RouteHandle{}
+	for _, H := range Old {
+		if H.setState(RouteRemoved) {
+			Removed = append(Removed, H)
 		}
+	}
 
-		// Assign any methods
-		if len(Node.Methods) > 0 {
-			Route = Route.Methods(Node.Methods...)
-			RouteDescriptor = fmt.Sprintf("%s%v ", RouteDescriptor, Node.Methods)
+	S.updateRoutes(func(Routes []*RouteHandle) []*RouteHandle {
+
+		Position := len(Routes)
+		Kept := make([]*RouteHandle, 0, len(Routes)+len(Handles))
+		for _, R := range Routes {
+			if containsRoute(Old, R) {
+				if Position == len(Routes) {
+					Position = len(Kept)
+				}
+				continue
+			}
+			Kept = append(Kept, R)
+		}
+		if Position > len(Kept) {
+			Position = len(Kept)
 		}
 
-		// Add any host-specific matching criteria
-		if Node.Host != "" {
-			Route = Route.Host(Node.Host)
+		return append(Kept[:Position], append(Handles, Kept[Position:]...)...)
+	})
+
+	for _, H := range Removed {
+		S.StructuredLogger().Info("Removed route", "route", routeDescriptor(H.route), "addr", S.Addr())
+	}
+
+	for _, H := range Handles {
+		S.StructuredLogger().Info("Added route", "route", routeDescriptor(H.route), "addr", S.Addr())
+	}
+
+	return Handles
+}
+
+// newRouteHandle will create the handle for a route serving the SimpleHandler.
+// The route matches using its own mux.Route, which is never modified once
+// built, so it is safe to match against concurrently.
+func (S *SimpleServer) newRouteHandle(Node SimpleHandler) *RouteHandle {
+
+	Handle := &RouteHandle{
+		server:  S,
+		route:   Node,
+		mu:      &sync.RWMutex{},
+		raw:     Node.Handler,
+		handler: S.wrapHandler(Node, Node.Handler),
+		state:   RouteActive,
+	}
+
+	if Node.CORS != nil {
+		Handle.cors = newCORSPolicy(*Node.CORS)
+		S.corsRoutes.Store(true)
+	}
+
+	// Create a route for the handler
+	Route := mux.NewRouter().NewRoute().Handler(Handle)
+	Handle.muxRoute = Route
+
+	// Assign the path
+	if Node.Path != "" {
+		Route = Route.PathPrefix(Node.Path)
+	}
+
+	// Assign any methods
+	if len(Node.Methods) > 0 {
+		Route = Route.Methods(Node.Methods...)
+	}
+
+	// Add any host-specific matching criteria
+	if Node.Host != "" {
+		Route = Route.Host(Node.Host)
+	}
+
+	// Add any URL QueryString matchin criteria
+	if len(Node.Queries) > 0 {
+		Pairs := []string{}
+		for _, Q := range Node.Queries {
+			Pairs = append(Pairs, Q.Key, Q.Value)
 		}
+		Route = Route.Queries(Pairs...)
+	}
 
-		// Add any URL QueryString matchin criteria
-		if len(Node.Queries) > 0 {
-			Pairs := []string{}
-			for _, Q := range Node.Queries {
-				Pairs = append(Pairs, Q.Key, Q.Value)
-			}
-			Route = Route.Queries(Pairs...)
+	return Handle
+}
+
+// matchRoute is a mux.MatcherFunc, matching the request against the routes of
+// the server in order. This is the only route added to the router of the
+// server for them, so routes can be changed while requests are being matched.
+func (S *SimpleServer) matchRoute(r *http.Request, Match *mux.RouteMatch) bool {
+
+	MethodMismatch := false
+
+	for _, H := range *S.routes.Load() {
+		M := &mux.RouteMatch{}
+		if H.muxRoute.Match(r, M) {
+			Match.Route = M.Route
+			Match.Handler = M.Handler
+			Match.Vars = M.Vars
+			return true
 		}
+		if M.MatchErr == mux.ErrMethodMismatch {
+			MethodMismatch = true
+		}
+	}
 
-		S.Logger().Printf("Added route: %sto server at [ %s ]", RouteDescriptor, S.Addr())
+	// Report the path matching with the wrong method, for a 405 response.
+	if MethodMismatch {
+		Match.MatchErr = mux.ErrMethodMismatch
 	}
 
-	S.routes = append(S.routes, Handlers...)
+	return false
+}
+
+// updateRoutes will replace the routes of the server with the result of
+// Update, given a copy of the current routes.
+func (S *SimpleServer) updateRoutes(Update func([]*RouteHandle) []*RouteHandle) {
+
+	S.mu.Lock()
+	defer S.mu.Unlock()
+
+	Routes := Update(append([]*RouteHandle{}, *S.routes.Load()...))
+	S.routes.Store(&Routes)
+}
+
+func containsRoute(Handles []*RouteHandle, H *RouteHandle) bool {
+	for _, R := range Handles {
+		if R == H {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// RouteState is the state of a route registered with a SimpleServer.
+type RouteState int
+
+const (
+	// RouteActive routes are matched, and served by their handler.
+	RouteActive RouteState = iota
+
+	// RouteDisabled routes are matched, but respond with a 503 status rather
+	// than calling their handler.
+	RouteDisabled
+
+	// RouteRemoved routes are no longer matched, as if they had never been
+	// added. A removed route cannot be re-enabled.
+	RouteRemoved
+)
+
+func (R RouteState) String() string {
+	switch R {
+	case RouteActive:
+		return "active"
+	case RouteDisabled:
+		return "disabled"
+	case RouteRemoved:
+		return "removed"
+	default:
+		return "unknown"
+	}
+}
+
+// RouteHandle is a handle to a single route added to a SimpleServer, allowing
+// it to be replaced, disabled or removed while the server is running. All
+// operations are safe to perform concurrently with requests to the route.
+type RouteHandle struct {
+	server   *SimpleServer
+	route    SimpleHandler
+	muxRoute *mux.Route
+
+	// The CORS policy of the route, overriding that of the server.
+	cors *corsPolicy
+
+	mu *sync.RWMutex
+
+	// The handler given for the route, and the same handler wrapped with
+	// the per-route middlewares which actually serves it.
+	raw     http.Handler
+	handler http.Handler
+	state   RouteState
+
+	// The state of the route when it was removed, which requests matched
+	// just before the removal are still served in.
+	removedFrom RouteState
+}
+
+// Route returns the SimpleHandler the route was added with, updated with the
+// most recent replacement handler.
+func (H *RouteHandle) Route() SimpleHandler {
+	H.mu.RLock()
+	defer H.mu.RUnlock()
+	Route := H.route
+	Route.Handler = H.raw
+	return Route
+}
+
+// State returns the current state of the route.
+func (H *RouteHandle) State() RouteState {
+	H.mu.RLock()
+	defer H.mu.RUnlock()
+	return H.state
+}
+
+// Replace will atomically replace the handler serving the route. Requests
+// already being served complete with the previous handler. Any identity
+// policy of the route still applies to the new handler.
+func (H *RouteHandle) Replace(Handler http.Handler) {
+
+	Wrapped := H.server.wrapHandler(H.route, Handler)
+
+	H.mu.Lock()
+	H.raw = Handler
+	H.handler = Wrapped
+	H.mu.Unlock()
+
+	H.server.StructuredLogger().Info("Replaced route", "route", routeDescriptor(H.route), "addr", H.server.Addr())
+}
+
+// Disable will make the route respond with a 503 status until it is enabled.
+func (H *RouteHandle) Disable() {
+	if H.setState(RouteDisabled) {
+		H.server.StructuredLogger().Info("Disabled route", "route", routeDescriptor(H.route), "addr", H.server.Addr())
+	}
+}
+
+// Enable will resume serving a disabled route with its handler.
+func (H *RouteHandle) Enable() {
+	if H.setState(RouteActive) {
+		H.server.StructuredLogger().Info("Enabled route", "route", routeDescriptor(H.route), "addr", H.server.Addr())
+	}
+}
+
+// Remove will stop the route from matching any further requests, allowing
+// later routes, or the Not Found handler, to handle them instead.
+func (H *RouteHandle) Remove() {
+	if H.setState(RouteRemoved) {
+		H.server.updateRoutes(func(Routes []*RouteHandle) []*RouteHandle {
+			for i, R := range Routes {
+				if R == H {
+					return append(Routes[:i], Routes[i+1:]...)
+				}
+			}
+			return Routes
+		})
+		H.server.StructuredLogger().Info("Removed route", "route", routeDescriptor(H.route), "addr", H.server.Addr())
+	}
+}
+
+// setState moves the route to the given state, returning whether it changed.
+// Removed routes never change state.
+func (H *RouteHandle) setState(State RouteState) bool {
+
+	H.mu.Lock()
+	defer H.mu.Unlock()
+
+	if H.state == RouteRemoved || H.state == State {
+		return false
+	}
+
+	if State == RouteRemoved {
+		H.removedFrom = H.state
+	}
+
+	H.state = State
+	return true
+}
+
+// ServeHTTP implements http.Handler, dispatching to the current handler of
+// the route.
+func (H *RouteHandle) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	H.mu.RLock()
+	Handler, State := H.handler, H.state
+	if State == RouteRemoved {
+		State = H.removedFrom
+	}
+	H.mu.RUnlock()
+
+	switch State {
+	case RouteActive:
+		Handler.ServeHTTP(w, r)
+	default:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+// Routes will return the handles of all routes currently registered with the
+// server, in the order they are matched.
+func (S *SimpleServer) Routes() []*RouteHandle {
+	return append([]*RouteHandle{}, *S.routes.Load()...)
+}
+
+// RemoveRoutes will remove all of the given routes.
+func RemoveRoutes(Handles ...*RouteHandle) {
+	for _, H := range Handles {
+		H.Remove()
+	}
+}
+
+// DisableRoutes will disable all of the given routes.
+func DisableRoutes(Handles ...*RouteHandle) {
+	for _, H := range Handles {
+		H.Disable()
+	}
+}
+
+// wrapHandler applies the per-route middlewares of the SimpleHandler to the handler.
+func (S *SimpleServer) wrapHandler(Node SimpleHandler, Handler http.Handler) http.Handler {
+
+	// Restrict the route to the authorized client identities
+	if Node.Identity != nil {
+		Handler = MiddlewareRequireIdentity(*Node.Identity, S.StructuredLogger())(Handler)
+	}
+
+	return Handler
+}
+
+// routeDescriptor formats the matching criteria of the route for logging.
+func routeDescriptor(Node SimpleHandler) string {
+
+	Descriptor := []string{}
+
+	if Node.Path != "" {
+		Descriptor = append(Descriptor, "[ "+Node.Path+" ]")
+	}
+
+	if len(Node.Methods) > 0 {
+		Descriptor = append(Descriptor, "["+strings.Join(Node.Methods, " ")+"]")
+	}
+
+	return strings.Join(Descriptor, " ")
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+	"github.com/gorilla/mux"
+)
+
+func statusHandler(Status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(Status)
+	})
+}
+
+func TestRouteHandles(T *testing.T) {
+
+	S := NewServerHTTP()
+	S.SetStructuredLogger(easytls.NewDiscardLogger())
+
+	Handles := S.AddHandlers(S.Router(), NewSimpleHandler(statusHandler(http.StatusOK), "/route", http.MethodGet))
+	if len(Handles) != 1 {
+		T.Fatalf("Expected 1 route handle, got %d", len(Handles))
+	}
+	H := Handles[0]
+
+	Get := func() int {
+		w := httptest.NewRecorder()
+		S.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/route", nil))
+		return w.Code
+	}
+
+	if Status := Get(); Status != http.StatusOK {
+		T.Fatalf("Expected status 200 from the active route, got %d", Status)
+	}
+
+	H.Replace(statusHandler(http.StatusAccepted))
+	if Status := Get(); Status != http.StatusAccepted {
+		T.Fatalf("Expected status 202 from the replaced handler, got %d", Status)
+	}
+
+	H.Disable()
+	if Status := Get(); Status != http.StatusServiceUnavailable {
+		T.Fatalf("Expected status 503 from the disabled route, got %d", Status)
+	}
+
+	H.Enable()
+	if Status := Get(); Status != http.StatusAccepted {
+		T.Fatalf("Expected status 202 from the re-enabled route, got %d", Status)
+	}
+
+	H.Remove()
+	if Status := Get(); Status != http.StatusNotFound {
+		T.Fatalf("Expected status 404 from the removed route, got %d", Status)
+	}
+
+	H.Enable()
+	if H.State() != RouteRemoved {
+		T.Fatalf("Expected a removed route to stay removed, got %s", H.State())
+	}
+
+	for _, R := range S.Routes() {
+		if R == H {
+			T.Fatalf("Expected the removed route to no longer be listed")
+		}
+	}
+
+	// A new route for the same path is matched once the old one is removed.
+	S.AddHandlers(S.Router(), NewSimpleHandler(statusHandler(http.StatusCreated), "/route", http.MethodGet))
+	if Status := Get(); Status != http.StatusCreated {
+		T.Fatalf("Expected status 201 from the new route, got %d", Status)
+	}
+}
+
+func TestRouteHandleConcurrentReplace(T *testing.T) {
+
+	S := NewServerHTTP()
+	S.SetStructuredLogger(easytls.NewDiscardLogger())
+	H := S.AddHandlers(S.Router(), NewSimpleHandler(statusHandler(http.StatusOK), "/route"))[0]
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				S.Router().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/route", nil))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				H.Replace(statusHandler(http.StatusOK))
+				H.Disable()
+				H.Enable()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestReplaceRoutesKeepsOrder(T *testing.T) {
+
+	S := NewServerHTTP()
+	S.SetStructuredLogger(easytls.NewDiscardLogger())
+
+	Handles := S.AddHandlers(S.Router(), NewSimpleHandler(statusHandler(http.StatusOK), "/route", http.MethodGet))
+	S.AddHandlers(S.Router(), NewSimpleHandler(statusHandler(http.StatusTeapot), "/"))
+
+	Get := func(Method, Path string) int {
+		w := httptest.NewRecorder()
+		S.Router().ServeHTTP(w, httptest.NewRequest(Method, Path, nil))
+		return w.Code
+	}
+
+	// The replacement takes the place of the old route, ahead of the catch-all.
+	Handles = S.ReplaceRoutes(Handles, NewSimpleHandler(statusHandler(http.StatusCreated), "/route", http.MethodGet))
+	if Status := Get(http.MethodGet, "/route"); Status != http.StatusCreated {
+		T.Fatalf("Expected status 201 from the replacement route, got %d", Status)
+	}
+	if Routes := S.Routes(); len(Routes) != 2 || Routes[0] != Handles[0] {
+		T.Fatalf("Expected the replacement to be the first of 2 routes, got %v", Routes)
+	}
+
+	// Removed routes are no longer matched at all.
+	RemoveRoutes(Handles...)
+	if Status := Get(http.MethodGet, "/route"); Status != http.StatusTeapot {
+		T.Fatalf("Expected status 418 from the catch-all, got %d", Status)
+	}
+
+	// Path variables and method mismatches behave as with a plain router.
+	S = NewServerHTTP()
+	S.SetStructuredLogger(easytls.NewDiscardLogger())
+	S.AddHandlers(S.Router(), NewSimpleHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] != "42" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}), "/item/{id}", http.MethodPost))
+
+	if Status := Get(http.MethodPost, "/item/42"); Status != http.StatusOK {
+		T.Fatalf("Expected status 200 with the path variable set, got %d", Status)
+	}
+	if Status := Get(http.MethodGet, "/item/42"); Status != http.StatusMethodNotAllowed {
+		T.Fatalf("Expected status 405 for the wrong method, got %d", Status)
+	}
+	if Status := Get(http.MethodGet, "/other"); Status != http.StatusNotFound {
+		T.Fatalf("Expected status 404 for an unknown path, got %d", Status)
+	}
+}
+
+func TestRoutesReloadWhileServing(T *testing.T) {
+
+	S := NewServerHTTP()
+	S.SetStructuredLogger(easytls.NewDiscardLogger())
+	Handles := S.AddHandlers(S.Router(), NewSimpleHandler(statusHandler(http.StatusOK), "/route", http.MethodGet))
+	S.AddHandlers(S.Router(), NewSimpleHandler(statusHandler(http.StatusTeapot), "/"))
+
+	Stop := make(chan struct{})
+	Failures := make(chan int, 1)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-Stop:
+					return
+				default:
+				}
+				w := httptest.NewRecorder()
+				S.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/route", nil))
+				if w.Code != http.StatusOK {
+					select {
+					case Failures <- w.Code:
+					default:
+					}
+				}
+			}
+		}()
+	}
+
+	// Reload the route, as a restarted module does, and add and remove
+	// unrelated routes, all while requests are being served.
+	for i := 0; i < 200; i++ {
+		Handles = S.ReplaceRoutes(Handles, NewSimpleHandler(statusHandler(http.StatusOK), "/route", http.MethodGet))
+		RemoveRoutes(S.AddHandlers(S.Router(), NewSimpleHandler(statusHandler(http.StatusOK), "/other"))...)
+	}
+
+	close(Stop)
+	wg.Wait()
+
+	select {
+	case Status := <-Failures:
+		T.Fatalf("Expected every request to be served by the route while reloading, got status %d", Status)
+	default:
+	}
+
+	if Routes := S.Routes(); len(Routes) != 2 {
+		T.Fatalf("Expected removed routes to be deleted, got %d routes", len(Routes))
+	}
+}
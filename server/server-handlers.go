@@ -7,7 +7,11 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+
+	easytls "github.com/Bearnie-H/easy-tls"
 )
 
 // QueryKeyValue is a key-value pair used to register routes with URL Query parameters.
@@ -41,6 +45,17 @@ type SimpleHandler struct {
 	// Optional: An additional description of the route, to provide additional context
 	// and understanding when displayed via the "/about" handler.
 	Description string `json:",omitempty"`
+
+	// Optional: The policy client certificates must satisfy to be allowed to
+	// call this route, in addition to any server-wide policy.
+	Identity *IdentityPolicy `json:",omitempty"`
+
+	// Optional: The CORS policy of the route, overriding the server-wide policy.
+	CORS *CORSPolicy `json:",omitempty"`
+
+	// Optional: The schemas, parameters and security requirements of the
+	// route, as published in the OpenAPI document of the server.
+	API *APIOperation `json:"-"`
 }
 
 // NewSimpleHandler will create and return a new SimpleHandler, ready to be used.
@@ -90,6 +105,19 @@ func (H *SimpleHandler) AddDescription(Description string) {
 	H.Description = Description
 }
 
+// RequireIdentity will restrict the handler to only be callable by clients
+// presenting a verified certificate which satisfies the given policy.
+// See MiddlewareRequireIdentity for more details.
+func (H *SimpleHandler) RequireIdentity(Policy IdentityPolicy) {
+	H.Identity = &Policy
+}
+
+// DescribeAPI will attach the details of the operation to the handler, to be
+// included in the OpenAPI document of the server.
+func (H *SimpleHandler) DescribeAPI(Operation APIOperation) {
+	H.API = &Operation
+}
+
 // AddPrefixToRoutes will assert that all routes have a given prefix
 func AddPrefixToRoutes(Prefix string, Handlers ...SimpleHandler) []SimpleHandler {
 
@@ -141,13 +169,32 @@ func (S *SimpleServer) enableAboutHandler() {
 		case http.MethodHead:
 			w.WriteHeader(http.StatusOK)
 		default:
+			Routes := []SimpleHandler{}
+			for _, H := range S.Routes() {
+				Routes = append(Routes, H.Route())
+			}
 			enc := json.NewEncoder(w)
 			enc.SetIndent("", "\t")
 			enc.SetEscapeHTML(true)
-			enc.Encode(S.routes)
+			enc.Encode(Routes)
 		}
 	}
 
+	// Registered first, as "/about" would otherwise match these paths as well.
+	S.addHandlers(S.Router(), SimpleHandler{
+		Handler:     listenersHandler(S),
+		Path:        "/about/listeners",
+		Methods:     []string{http.MethodGet, http.MethodHead},
+		Description: "List the addresses this Server accepts connections on, and whether each serves TLS or redirects to HTTPS.",
+	})
+
+	S.addHandlers(S.Router(), SimpleHandler{
+		Handler:     certificatesHandler(S.expiry),
+		Path:        "/about/certificates",
+		Methods:     []string{http.MethodGet, http.MethodHead},
+		Description: "List the certificates used by this Server, and the days until each expires. Optionally filtered with \"?within=<days>\".",
+	})
+
 	S.addHandlers(S.Router(), SimpleHandler{
 		Handler:     http.HandlerFunc(aboutHandler),
 		Path:        "/about",
@@ -156,6 +203,41 @@ func (S *SimpleServer) enableAboutHandler() {
 	})
 }
 
+// enableHealthHandlers will register the handlers serving the reports of
+// the HealthChecker of the server.
+func (S *SimpleServer) enableHealthHandlers() {
+	S.addHandlers(S.Router(), HealthHandlers(S.health)...)
+}
+
+// certificatesHandler will report the status of the certificates tracked by
+// the ExpiryMonitor, as JSON.
+func certificatesHandler(Monitor *easytls.ExpiryMonitor) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		Within := time.Duration(-1)
+		if Days := r.URL.Query().Get("within"); Days != "" {
+			D, err := strconv.Atoi(Days)
+			if err != nil || D < 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			Within = time.Duration(D) * time.Hour * 24
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.Method {
+		case http.MethodHead:
+			w.WriteHeader(http.StatusOK)
+		default:
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "\t")
+			enc.SetEscapeHTML(true)
+			enc.Encode(Monitor.ExpiringWithin(Within))
+		}
+	})
+}
+
 // RegisterSPAHandler will register an HTTP Handler to allow serving a Single Page Application.
 // The application will be based off URLBase, and will serve content based out of PathBase.
 //
@@ -1,12 +1,13 @@
 package server
 
 import (
-	"context"
 	"crypto/tls"
+	"errors"
 	"log"
 	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	easytls "github.com/Bearnie-H/easy-tls"
@@ -28,24 +29,50 @@ type SimpleServer struct {
 	// The router to use to match incoming requests to specific handlers
 	router *mux.Router
 
-	// The set of routes added to the server.
+	// The set of routes added to the server, and not yet removed, in the
+	// order they are matched. Replaced as a whole, under mu, on any change.
 	// Used to build the "/about" handler
-	routes []SimpleHandler
+	routes *atomic.Pointer[[]*RouteHandle]
 
 	// The logger to write all messages to
-	logger *log.Logger
+	logger easytls.Logger
 
 	// The (optional) TLS resources to use
 	tls *easytls.TLSBundle
 
-	// A channel to signal when Shutdown is fully complete and successful
+	// Tracks the expiry of the certificates used by the server
+	expiry *easytls.ExpiryMonitor
+
+	// The health checks registered by the components of the server
+	health *HealthChecker
+
+	// The server-wide CORS policy, and whether any route has its own
+	cors       *atomic.Pointer[corsPolicy]
+	corsRoutes *atomic.Bool
+
+	// The additional listeners to open alongside the primary Addr
+	extraListeners []ListenerConfig
+
+	// The listeners opened by Listen, and not yet served
+	opened []serverListener
+
+	// The listeners the server is accepting connections on
+	listeners []serverListener
+
+	// The hooks to run while shutting down, in order of registration
+	shutdownHooks []namedShutdownHook
+
+	// The timeouts of the phases of shutting down the server
+	shutdownOptions ShutdownOptions
+
+	// Closed once Shutdown is fully complete
 	done chan struct{}
 
-	// How long to wait for a safe shutdown of the server.
-	shutdownTime time.Duration
+	// Whether Shutdown has been called, and the result of it
+	stopping    bool
+	shutdownErr error
 
-	mu     *sync.Mutex
-	active bool
+	mu *sync.Mutex
 }
 
 // NewServerHTTP will create a new HTTP-only server which will serve on the
@@ -72,7 +99,7 @@ func NewServerHTTPS(TLS *easytls.TLSBundle, Addr ...string) (*SimpleServer, erro
 	}
 
 	router := NewDefaultRouter()
-	logger := easytls.NewDefaultLogger()
+	logger := easytls.NewDefaultStructuredLogger()
 
 	// Create the TLS settings as defined in the TLSBundle
 	tls, err := easytls.NewTLSConfig(TLS)
@@ -80,21 +107,47 @@ func NewServerHTTPS(TLS *easytls.TLSBundle, Addr ...string) (*SimpleServer, erro
 		return nil, err
 	}
 
+	expiry := easytls.NewExpiryMonitor(logger)
+	expiry.AddBundle(TLS)
+
 	Server := &SimpleServer{
 		Server: &http.Server{
 			Addr:      Addr[0],
 			TLSConfig: tls,
-			ErrorLog:  logger,
+			ErrorLog:  easytls.NewLogLogger(logger, easytls.LevelError),
 			Handler:   router,
+			ConnState: func(_ net.Conn, State http.ConnState) {
+				// Piggy-back the periodic expiry checks on new connections.
+				if State == http.StateNew {
+					expiry.Poll()
+				}
+			},
+		},
+		router:     router,
+		logger:     logger,
+		tls:        TLS,
+		expiry:     expiry,
+		health:     NewHealthChecker(),
+		cors:       &atomic.Pointer[corsPolicy]{},
+		corsRoutes: &atomic.Bool{},
+		routes:     &atomic.Pointer[[]*RouteHandle]{},
+		shutdownOptions: ShutdownOptions{
+			DrainTimeout:   DefaultDrainTimeout,
+			HookTimeout:    DefaultHookTimeout,
+			RestartTimeout: DefaultRestartTimeout,
 		},
-		router:       router,
-		logger:       logger,
-		tls:          TLS,
-		done:         make(chan struct{}),
-		mu:           &sync.Mutex{},
-		active:       false,
-		shutdownTime: time.Second * 30,
+		done: make(chan struct{}),
+		mu:   &sync.Mutex{},
 	}
+	Server.routes.Store(&[]*RouteHandle{})
+
+	// Every route added to the server is matched by this one route, so the
+	// router itself is never modified while serving.
+	router.NewRoute().MatcherFunc(Server.matchRoute)
+
+	// Answer CORS preflight requests before routing, as the router does not
+	// run middlewares for methods a route does not register.
+	Server.Server.Handler = &corsHandler{server: Server, next: router}
 
 	return Server, nil
 }
@@ -109,6 +162,19 @@ func (S *SimpleServer) TLSBundle() *easytls.TLSBundle {
 	return S.tls
 }
 
+// ExpiryMonitor will return the monitor tracking the expiry of the
+// certificates used by the server.
+func (S *SimpleServer) ExpiryMonitor() *easytls.ExpiryMonitor {
+	return S.expiry
+}
+
+// Health will return the HealthChecker of the server, which components of
+// the server register their health checks with. The reports are served at
+// "/healthz", "/livez" and "/readyz".
+func (S *SimpleServer) Health() *HealthChecker {
+	return S.health
+}
+
 // SetTimeouts will set the given timeouts of the Server.
 // Set 0 to leave uninitialized.
 func (S *SimpleServer) SetTimeouts(ReadTimeout, ReadHeaderTimeout, WriteTimeout, IdleTimeout, ShutdownTimeout time.Duration) {
@@ -133,107 +199,153 @@ func (S *SimpleServer) SetTimeouts(ReadTimeout, ReadHeaderTimeout, WriteTimeout,
 		S.Server.IdleTimeout = IdleTimeout
 	}
 
-	// Set the shutdown timeout to somewhere between 1 second and 1 minute
-	if ShutdownTimeout > 0 && ShutdownTimeout < time.Minute {
-		S.shutdownTime = ShutdownTimeout
+	// How long to wait for in-flight requests to drain while shutting down.
+	if ShutdownTimeout > 0 {
+		S.mu.Lock()
+		S.shutdownOptions.DrainTimeout = ShutdownTimeout
+		S.mu.Unlock()
 	}
 }
 
 // SetLogger will update the logger used by the server from the default to the
-// given output.
+// given output. Messages are written to it as by easytls.NewStdLogger.
 func (S *SimpleServer) SetLogger(logger *log.Logger) {
-	S.logger = logger
-	S.Server.ErrorLog = logger
+	S.SetStructuredLogger(easytls.AsLogger(logger))
 }
 
-// Logger will return the internal logger used by the server.
+// Logger will return the internal logger used by the server, as a standard
+// *log.Logger.
 func (S *SimpleServer) Logger() *log.Logger {
+	return easytls.AsLogLogger(S.logger)
+}
+
+// SetStructuredLogger will update the leveled, structured logger used by the
+// server from the default to the given Logger.
+func (S *SimpleServer) SetStructuredLogger(logger easytls.Logger) {
+	if logger == nil {
+		logger = easytls.NewDefaultStructuredLogger()
+	}
+	S.logger = logger
+	S.Server.ErrorLog = easytls.NewLogLogger(logger, easytls.LevelError)
+	S.expiry.SetLogger(logger)
+}
+
+// StructuredLogger will return the internal leveled, structured logger used
+// by the server.
+func (S *SimpleServer) StructuredLogger() easytls.Logger {
 	return S.logger
 }
 
-// ListenAndServe will start the SimpleServer, serving HTTPS if enabled,
-// or HTTP if not. This will properly wait for the shutdown
-// to FINISH before returning.
-func (S *SimpleServer) ListenAndServe() error {
+// Listen will open the primary Addr and every listener added with
+// AddListener, without serving any of them yet, so that an address which is
+// in use or invalid is reported before the caller moves on. A later call to
+// ListenAndServe serves the listeners opened here.
+func (S *SimpleServer) Listen() error {
 
-	S.enableAboutHandler()
+	S.mu.Lock()
+	Opened := S.opened != nil
+	S.mu.Unlock()
 
-	S.Logger().Printf("Starting server at [ %s ]", S.Addr())
+	if Opened {
+		return errors.New("server error: Server is already listening")
+	}
 
-	var ListenAndServe func() error
+	// Open every listener before serving any, so a bad address fails fast.
+	Configs := S.listenerConfigs()
+	if len(Configs) == 0 {
+		return errors.New("server error: No address or listeners to serve on")
+	}
 
-	if S.tls == nil || !S.tls.Enabled {
-		ListenAndServe = func() error {
-			defer func() { <-S.done }()
-			if err := S.Server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				return err
-			}
-			return nil
-		}
-	} else {
-		ListenAndServe = func() error {
-			defer func() { <-S.done }()
-			if err := S.Server.ListenAndServeTLS(S.tls.KeyPair.Certificate, S.tls.KeyPair.Key); err != nil && err != http.ErrServerClosed {
-				return err
+	Listeners := []serverListener{}
+	for i, Config := range Configs {
+		L, err := S.newListener(Config, i == 0 && S.Addr() != "")
+		if err != nil {
+			for _, L := range Listeners {
+				L.raw.Close()
 			}
-			return nil
+			return err
 		}
+		Listeners = append(Listeners, L)
 	}
 
 	S.mu.Lock()
-	S.active = true
+	S.opened = Listeners
 	S.mu.Unlock()
 
-	return ListenAndServe()
+	return nil
 }
 
-// Serve will serve the SimpleServer at the given Listener, rather than allowing it to build
-// its own set.
-func (S *SimpleServer) Serve(l net.Listener) error {
+// ListenAndServe will start the SimpleServer, serving HTTPS if enabled,
+// or HTTP if not, on the primary Addr and every listener added with
+// AddListener. If the process was started by Restart, the listeners passed
+// from the parent process are used. This will properly wait for the shutdown
+// to FINISH before returning. If any listener fails, the server is shut down
+// and the first error is returned.
+//
+// The listeners already opened by Listen are served, if it was called.
+// A server with an empty Addr serves only the listeners added with AddListener.
+func (S *SimpleServer) ListenAndServe() error {
+
+	S.enableAboutHandler()
+	S.enableHealthHandlers()
+	S.health.SetDraining(false)
 
 	S.mu.Lock()
-	S.active = true
+	Opened := S.opened != nil
 	S.mu.Unlock()
 
-	S.Server.Addr = l.Addr().String()
-
-	err := S.Server.Serve(l)
-	<-S.done
-	return err
-}
-
-// Shutdown will safely shut down the SimpleServer, returning any errors
-func (S *SimpleServer) Shutdown() error {
-
-	S.mu.Lock()
-	defer S.mu.Unlock()
-	if !S.active {
-		return nil
+	if !Opened {
+		if err := S.Listen(); err != nil {
+			return err
+		}
 	}
 
-	defer func() {
-		S.Logger().Printf("Finished shutting down server at [ %s ].", S.Addr())
-		S.done <- struct{}{}
-		close(S.done)
-	}()
+	S.mu.Lock()
+	Listeners := S.opened
+	S.opened = []serverListener{}
+	S.mu.Unlock()
 
-	S.Logger().Printf("Shutting down server at [ %s ]...", S.Addr())
+	Inherited := 0
+	Errors := make(chan error, len(Listeners))
+	for _, L := range Listeners {
+		S.StructuredLogger().Info("Starting server", "addr", L.config.Addr, "network", L.config.Network, "tls", L.config.TLS != nil && L.config.TLS.Enabled)
+		if L.inherited {
+			Inherited++
+		}
+		go func(L serverListener) {
+			Errors <- S.serve(L)
+		}(L)
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), S.shutdownTime)
-	defer cancel()
+	// Once every inherited listener is served, by this or another server, a
+	// parent process waiting in Restart can hand over to this one.
+	notifyReady(Inherited)
 
-	if err := S.Server.Shutdown(ctx); err != nil {
-		S.Logger().Printf("Failed to shut down server at [ %s ] before timeout, force closing - %s", S.Addr(), err)
-		if err := S.Server.Close(); err != nil {
-			S.Logger().Printf("Error while force closing server at [ %s ] - %s", S.Addr(), err)
+	for range Listeners {
+		if err := <-Errors; err != nil {
+			S.StructuredLogger().Error("Listener failed, shutting down server", "addr", S.Addr(), "error", err)
+			S.Shutdown()
 			return err
 		}
-		return err
 	}
 
 	return nil
 }
 
+// Serve will serve the SimpleServer at the given Listener, rather than allowing it to build
+// its own set.
+func (S *SimpleServer) Serve(l net.Listener) error {
+
+	S.Server.Addr = l.Addr().String()
+
+	return S.serve(serverListener{
+		config: ListenerConfig{Network: l.Addr().Network(), Addr: S.Addr()},
+		raw:    l,
+		srv:    S.Server,
+		serve:  func() error { return S.Server.Serve(l) },
+	})
+}
+
 // Addr exposes the underlying local address of the SimpleServer.
 func (S *SimpleServer) Addr() string {
 	return S.Server.Addr
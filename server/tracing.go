@@ -0,0 +1,44 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Bearnie-H/easy-tls/tracing"
+)
+
+// MiddlewareTracing provides a middleware which starts a server span around
+// every request, named by its method and route template, as a child of any
+// W3C traceparent sent by the client. The span is available to handlers
+// through the request context, so the requests they make with a SimpleClient
+// continue the same trace. The tracing.Default Tracer is used if none is given.
+func MiddlewareTracing(Tracer tracing.Tracer) MiddlewareHandler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			T := Tracer
+			if T == nil {
+				T = tracing.Default()
+			}
+
+			Route := routeTemplate(r)
+
+			ctx, Span := T.Start(tracing.Extract(r.Context(), r.Header), r.Method+" "+Route, tracing.SpanKindServer)
+			defer Span.End()
+
+			Span.SetAttributes(tracing.AttrHTTPMethod, r.Method, tracing.AttrHTTPRoute, Route, tracing.AttrURLPath, r.URL.Path)
+			if ID, ok := RequestIDFromContext(ctx); ok {
+				Span.SetAttributes(tracing.AttrRequestID, ID)
+			}
+
+			Recorder, Writer := newResponseRecorder(w)
+			next.ServeHTTP(Writer, r.WithContext(ctx))
+
+			Status := Recorder.Status()
+			Span.SetAttributes(tracing.AttrHTTPStatusCode, Status)
+			if Status >= http.StatusInternalServerError {
+				Span.RecordError(errors.New(http.StatusText(Status)))
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+	"github.com/Bearnie-H/easy-tls/client"
+	"github.com/Bearnie-H/easy-tls/tracing"
+)
+
+func TestMiddlewareTracing(T *testing.T) {
+
+	Recorder := tracing.NewRecorder()
+
+	Forwarded := make(chan string, 1)
+	Upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Forwarded <- r.Header.Get(tracing.HeaderTraceParent)
+	}))
+	defer Upstream.Close()
+
+	C := client.NewClientHTTP()
+	C.SetTracer(Recorder)
+
+	S := NewServerHTTP()
+	S.SetStructuredLogger(easytls.NewDiscardLogger())
+	S.AddMiddlewares(MiddlewareTracing(Recorder))
+	S.AddHandlers(S.Router(), NewSimpleHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := C.GetContext(r.Context(), Upstream.URL, nil)
+		if err != nil {
+			T.Errorf("Failed to perform upstream request: %v", err)
+			return
+		}
+		resp.Body.Close()
+	}), "/items/{ID}", http.MethodGet))
+
+	r := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	r.Header.Set(tracing.HeaderTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	S.Router().ServeHTTP(httptest.NewRecorder(), r)
+
+	Server, ok := Recorder.Find("GET /items/{ID}")
+	if !ok {
+		T.Fatalf("Expected a server span named by the route template, got %+v", Recorder.Spans())
+	}
+
+	if Server.Parent.SpanID.String() != "00f067aa0ba902b7" || Server.SpanContext.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		T.Fatalf("Expected the server span to continue the incoming trace, got %+v", Server)
+	}
+
+	if Server.Attributes[tracing.AttrHTTPStatusCode] != http.StatusOK {
+		T.Fatalf("Expected the status code to be recorded, got %v", Server.Attributes)
+	}
+
+	Children := Recorder.Children(Server)
+	if len(Children) != 1 || Children[0].Kind != tracing.SpanKindClient {
+		T.Fatalf("Expected a single client span as a child of the server span, got %+v", Children)
+	}
+
+	if TraceParent := <-Forwarded; TraceParent != Children[0].SpanContext.TraceParent() {
+		T.Fatalf("Expected the client span to be propagated upstream, got [ %s ]", TraceParent)
+	}
+}
@@ -3,13 +3,39 @@ package easytls
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
 	"io/ioutil"
+	"os"
 )
 
 // KeyPair is a single matched pair of TLS Certificate and Key files.
+//
+// The pair can alternatively be given directly as PEM-encoded bytes, in which
+// case the filenames are ignored.
 type KeyPair struct {
 	Certificate string
 	Key         string
+
+	CertificatePEM []byte
+	KeyPEM         []byte
+}
+
+// AuthoritySet is a set of Certificate Authorities, built from any combination
+// of filenames, PEM-encoded certificates, and an existing pool.
+type AuthoritySet struct {
+
+	// Certificates is a set of filenames of Certificate Authority certificates.
+	Certificates []string
+
+	// PEM is a set of PEM-encoded Certificate Authority certificates.
+	PEM [][]byte
+
+	// Pool is an existing pool of Certificate Authorities to start from.
+	// This pool is never modified.
+	Pool *x509.CertPool
 }
 
 // TLSBundle is a toggle-able set of TLS resources to be used to generate a
@@ -24,9 +50,35 @@ type TLSBundle struct {
 	// Authorities for TLS communications.
 	//
 	// If no explicit CA Certificates are provided, the default set of System
-	// Certificate Authorities will be used.
+	// Certificate Authorities will be used to verify servers, and no client
+	// certificates will be accepted.
 	AuthorityCertificates []string
 
+	// AuthorityPEM is a set of PEM-encoded Certificate Authority certificates,
+	// added to the whitelist alongside any from AuthorityCertificates.
+	AuthorityPEM [][]byte
+
+	// AuthorityPool is an existing pool of Certificate Authorities to start
+	// the whitelist from. This pool is never modified.
+	AuthorityPool *x509.CertPool
+
+	// ServerAuthorities, if set, replaces the Authorities above when
+	// verifying the certificates presented by servers. This allows a host
+	// acting as both a client and server to trust a distinct set of
+	// Certificate Authorities for each role.
+	ServerAuthorities *AuthoritySet
+
+	// ClientAuthorities, if set, replaces the Authorities above when
+	// verifying the certificates presented by clients.
+	ClientAuthorities *AuthoritySet
+
+	// IncludeSystemAuthorities will merge the System Certificate Pool into
+	// the explicitly provided Certificate Authorities used to verify servers,
+	// rather than only using one or the other. Clients are never verified
+	// against the System Certificate Pool, unless it is explicitly given as
+	// the Pool of the ClientAuthorities.
+	IncludeSystemAuthorities bool
+
 	// KeyPair is the matched pair of "Client" Certificate and Key to use and
 	// present during a TLS handshake.
 	//
@@ -36,10 +88,33 @@ type TLSBundle struct {
 	// Required for Servers.
 	KeyPair KeyPair
 
+	// Certificates is a set of pre-loaded certificates to present, in
+	// addition to the KeyPair.
+	Certificates []tls.Certificate
+
+	// FS is an optional filesystem to read the KeyPair and
+	// AuthorityCertificates files from, rather than the OS filesystem.
+	FS fs.FS
+
 	// Auth defines the policy to use during the TLS handshake to verify the
 	// other host's certificate.
 	Auth tls.ClientAuthType
 
+	// Policy defines the protocol-level settings, such as the allowed TLS
+	// versions and cipher suites. The zero value only allows TLS 1.3.
+	// See NewTLSPolicy for a set of named presets.
+	Policy TLSPolicy
+
+	// Reload defines whether the KeyPair and AuthorityCertificates should be
+	// watched for changes and swapped in while the resulting tls.Config is in
+	// use. See ReloadPolicy for more details.
+	Reload ReloadPolicy
+
+	// Revocation defines how peer certificates are checked for revocation,
+	// and whether OCSP responses are stapled by servers. See
+	// RevocationPolicy for more details.
+	Revocation RevocationPolicy
+
 	// Enabled allows this to be toggled. If disabled, this will create an
 	// nil tls.Config when used, turning TLS off for whatever client or server
 	// which uses the returned tls.Config{}.
@@ -61,6 +136,20 @@ func NewTLSBundle(CertificateFile, KeyFile string, CertificateAuthorityFiles ...
 	}
 }
 
+// NewTLSBundleFromPEM will create and return a new default TLSBundle from a given
+// set of PEM-encoded resources, rather than filenames.
+func NewTLSBundleFromPEM(Certificate, Key []byte, CertificateAuthorities ...[]byte) *TLSBundle {
+	return &TLSBundle{
+		AuthorityPEM: CertificateAuthorities,
+		KeyPair: KeyPair{
+			CertificatePEM: Certificate,
+			KeyPEM:         Key,
+		},
+		Auth:    tls.NoClientCert,
+		Enabled: true,
+	}
+}
+
 // NewTLSConfig will convert the TLSBundle, containing the filenames of the
 // relevant certificates and Authorization policy, into a workable tls.Config
 // object, ready to be used by either a SimpleClient or SimpleServer application.
@@ -71,55 +160,284 @@ func NewTLSConfig(TLS *TLSBundle) (*tls.Config, error) {
 		return nil, nil
 	}
 
+	if err := TLS.Validate(); err != nil {
+		return nil, err
+	}
+
 	// Create the tls.Config to return if everything goes well.
 	returnConfig := &tls.Config{}
 
 	// If no KeyPairs are provided, don't attempt to load Client-side certificates
-	if TLS.KeyPair.Certificate != "" && TLS.KeyPair.Key != "" {
-		cert, err := tls.LoadX509KeyPair(TLS.KeyPair.Certificate, TLS.KeyPair.Key)
+	certs, err := TLS.loadCertificates()
+	if err != nil {
+		return nil, err
+	}
+	returnConfig.Certificates = certs
+
+	Roots, Clients, err := TLS.loadAuthorities()
+	if err != nil {
+		return nil, err
+	}
+
+	// The way we implement TLS CAs here expects that the full set of accepted CAs is a whitelist, and whether we check or care about certificates is based on the ClientAuth.
+	returnConfig.RootCAs = Roots
+	returnConfig.ClientCAs = Clients
+
+	// Set the allowed versions, cipher suites and other protocol settings.
+	TLS.Policy.apply(returnConfig)
+
+	// Define how the Client Certificates will be checked.
+	returnConfig.ClientAuth = TLS.Auth
+
+	// If the resources should be watched for changes, hand the certificate
+	// selection off to the reloader rather than the static values loaded above.
+	if TLS.Reload.Enabled {
+		newCertificateReloader(TLS, certs, Roots, Clients).configure(returnConfig)
+	}
+
+	// Revocation checks wrap whichever certificate selection is in place.
+	if TLS.Revocation.enabled() {
+		Checker, err := newRevocationChecker(TLS, certs)
 		if err != nil {
 			return nil, err
 		}
-		returnConfig.Certificates = append(returnConfig.Certificates, cert)
+		Checker.configure(returnConfig)
 	}
 
-	// If CA Certificates are provided, attempt to load and build a pool from the full set
-	if TLS.AuthorityCertificates == nil || len(TLS.AuthorityCertificates) > 0 {
-		caCertPool := x509.NewCertPool()
+	return returnConfig, nil
+}
 
-		for _, AuthorityCert := range TLS.AuthorityCertificates {
+// Validate will check that the TLSBundle is internally consistent, returning
+// an error describing the first problem found.
+func (TLS *TLSBundle) Validate() error {
 
-			// Load the CA cert
-			caCert, err := ioutil.ReadFile(AuthorityCert)
-			if err != nil {
-				return nil, err
-			}
+	if (TLS.KeyPair.Certificate == "") != (TLS.KeyPair.Key == "") {
+		return errors.New("easytls error: KeyPair must include both a Certificate and Key file")
+	}
+
+	if (len(TLS.KeyPair.CertificatePEM) == 0) != (len(TLS.KeyPair.KeyPEM) == 0) {
+		return errors.New("easytls error: KeyPair must include both a Certificate and Key PEM")
+	}
+
+	if TLS.Auth < tls.NoClientCert || TLS.Auth > tls.RequireAndVerifyClientCert {
+		return fmt.Errorf("easytls error: Unknown client authentication policy [ %d ]", TLS.Auth)
+	}
+
+	for _, Set := range []*AuthoritySet{TLS.commonAuthorities(), TLS.ServerAuthorities} {
+		if Set != nil && Set.Pool != nil && TLS.IncludeSystemAuthorities {
+			return errors.New("easytls error: An existing Pool cannot be merged with the System Certificate Pool")
+		}
+	}
+
+	return TLS.Policy.Validate()
+}
 
-			// Create and append the CA Cert to the pool of approved certificate authorities.
-			// This sets up so that ONLY the CA who signed this certificate can verify the received server certificate.
-			caCertPool.AppendCertsFromPEM(caCert)
+// loadCertificates will load the KeyPair defined by the bundle, along with
+// any pre-loaded Certificates.
+func (TLS *TLSBundle) loadCertificates() ([]tls.Certificate, error) {
+
+	Certificates := []tls.Certificate{}
+
+	var CertificatePEM, KeyPEM []byte
+	var err error
+
+	switch {
+	case len(TLS.KeyPair.CertificatePEM) > 0 && len(TLS.KeyPair.KeyPEM) > 0:
+		CertificatePEM, KeyPEM = TLS.KeyPair.CertificatePEM, TLS.KeyPair.KeyPEM
+	case TLS.KeyPair.Certificate != "" && TLS.KeyPair.Key != "":
+		if CertificatePEM, err = TLS.readFile(TLS.KeyPair.Certificate); err != nil {
+			return nil, err
 		}
+		if KeyPEM, err = TLS.readFile(TLS.KeyPair.Key); err != nil {
+			return nil, err
+		}
+	}
 
-		// The way we implement TLS CAs here expects that the full set of accepted CAs is a whitelist, and whether we check or care about certificates is based on the ClientAuth.
-		returnConfig.RootCAs = caCertPool
-		returnConfig.ClientCAs = caCertPool
+	if CertificatePEM != nil {
+		cert, err := tls.X509KeyPair(CertificatePEM, KeyPEM)
+		if err != nil {
+			return nil, err
+		}
+		Certificates = append(Certificates, cert)
+	}
+
+	return append(Certificates, TLS.Certificates...), nil
+}
+
+// loadAuthorities will build the pools of Certificate Authorities defined by
+// the bundle, to verify servers and clients respectively. The System
+// Certificate Pool is only ever used to verify servers, so a certificate from
+// any public Certificate Authority is never accepted from a client.
+func (TLS *TLSBundle) loadAuthorities() (Roots, Clients *x509.CertPool, err error) {
+
+	Common := TLS.commonAuthorities()
+
+	// If no CA Certificates are provided, default to the system Certificate Pool
+	if Common.Certificates != nil && len(Common.Certificates) == 0 && len(Common.PEM) == 0 && Common.Pool == nil {
+		if Roots, err = x509.SystemCertPool(); err != nil {
+			return nil, nil, err
+		}
+		// A nil pool would verify clients against the System pool, so use an
+		// empty one instead.
+		Clients = x509.NewCertPool()
 	} else {
-		// If no CA Certificates are provided, default to the system Certificate Pool
-		caCertPool, err := x509.SystemCertPool()
+		if Roots, err = TLS.loadAuthoritySet(Common, TLS.IncludeSystemAuthorities); err != nil {
+			return nil, nil, err
+		}
+		if Clients, err = TLS.loadAuthoritySet(Common, false); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if TLS.ServerAuthorities != nil {
+		if Roots, err = TLS.loadAuthoritySet(TLS.ServerAuthorities, TLS.IncludeSystemAuthorities); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if TLS.ClientAuthorities != nil {
+		if Clients, err = TLS.loadAuthoritySet(TLS.ClientAuthorities, false); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return Roots, Clients, nil
+}
+
+// loadAuthoritySet will build a single pool from an AuthoritySet, starting
+// from the System Certificate Pool if System is set.
+func (TLS *TLSBundle) loadAuthoritySet(Set *AuthoritySet, System bool) (*x509.CertPool, error) {
+
+	// If CA Certificates are provided, attempt to load and build a pool from the full set
+	caCertPool := x509.NewCertPool()
+
+	switch {
+	case Set.Pool != nil:
+		caCertPool = Set.Pool.Clone()
+	case System:
+		System, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, err
+		}
+		caCertPool = System
+	}
+
+	for _, AuthorityCert := range Set.Certificates {
+
+		// Load the CA cert
+		caCert, err := TLS.readFile(AuthorityCert)
 		if err != nil {
 			return nil, err
 		}
 
-		returnConfig.RootCAs = caCertPool
-		returnConfig.ClientCAs = caCertPool
+		// Create and append the CA Cert to the pool of approved certificate authorities.
+		// This sets up so that ONLY the CA who signed this certificate can verify the received server certificate.
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("easytls error: Failed to parse any certificates from [ %s ]", AuthorityCert)
+		}
 	}
 
-	// If there are Certificates, the TLS min version can be set.
-	// Default to the maximum supported version, sorry if this breaks old applications.
-	returnConfig.MinVersion = tls.VersionTLS13
+	for i, caCert := range Set.PEM {
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("easytls error: Failed to parse any certificates from PEM [ %d ]", i)
+		}
+	}
 
-	// Define how the Client Certificates will be checked.
-	returnConfig.ClientAuth = TLS.Auth
+	return caCertPool, nil
+}
 
-	return returnConfig, nil
+// commonAuthorities returns the Authorities shared by both server and client
+// verification, as an AuthoritySet.
+func (TLS *TLSBundle) commonAuthorities() *AuthoritySet {
+	return &AuthoritySet{
+		Certificates: TLS.AuthorityCertificates,
+		PEM:          TLS.AuthorityPEM,
+		Pool:         TLS.AuthorityPool,
+	}
+}
+
+// authorityCertificates parses the Certificate Authorities given by filename
+// or PEM. Authorities only present in an existing Pool cannot be listed, and
+// any which fail to load are skipped.
+func (TLS *TLSBundle) authorityCertificates() []*x509.Certificate {
+
+	Certificates := []*x509.Certificate{}
+
+	for _, Set := range []*AuthoritySet{TLS.commonAuthorities(), TLS.ServerAuthorities, TLS.ClientAuthorities} {
+		if Set == nil {
+			continue
+		}
+
+		Contents := append([][]byte{}, Set.PEM...)
+		for _, Filename := range Set.Certificates {
+			if PEM, err := TLS.readFile(Filename); err == nil {
+				Contents = append(Contents, PEM)
+			}
+		}
+
+		for _, Rest := range Contents {
+			for {
+				var Block *pem.Block
+				if Block, Rest = pem.Decode(Rest); Block == nil {
+					break
+				}
+				if Cert, err := x509.ParseCertificate(Block.Bytes); err == nil {
+					Certificates = append(Certificates, Cert)
+				}
+			}
+		}
+	}
+
+	return Certificates
+}
+
+// serverAuthorityFiles returns the files used to verify servers.
+func (TLS *TLSBundle) serverAuthorityFiles() []string {
+
+	if TLS.ServerAuthorities != nil {
+		return TLS.ServerAuthorities.Certificates
+	}
+
+	return TLS.AuthorityCertificates
+}
+
+// readFile will read the full contents of the named file, from the FS of the
+// bundle if one is set.
+func (TLS *TLSBundle) readFile(Filename string) ([]byte, error) {
+
+	if TLS.FS != nil {
+		return fs.ReadFile(TLS.FS, Filename)
+	}
+
+	return ioutil.ReadFile(Filename)
+}
+
+// statFile will describe the named file, from the FS of the bundle if one is set.
+func (TLS *TLSBundle) statFile(Filename string) (fs.FileInfo, error) {
+
+	if TLS.FS != nil {
+		return fs.Stat(TLS.FS, Filename)
+	}
+
+	return os.Stat(Filename)
+}
+
+// watchedFiles returns the set of files which this bundle is built from.
+func (TLS *TLSBundle) watchedFiles() []string {
+
+	Files := []string{}
+
+	if len(TLS.KeyPair.CertificatePEM) == 0 && TLS.KeyPair.Certificate != "" && TLS.KeyPair.Key != "" {
+		Files = append(Files, TLS.KeyPair.Certificate, TLS.KeyPair.Key)
+	}
+
+	Files = append(Files, TLS.AuthorityCertificates...)
+
+	for _, Set := range []*AuthoritySet{TLS.ServerAuthorities, TLS.ClientAuthorities} {
+		if Set != nil {
+			Files = append(Files, Set.Certificates...)
+		}
+	}
+
+	return Files
 }
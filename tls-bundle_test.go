@@ -0,0 +1,269 @@
+package easytls_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"testing/fstest"
+
+	easytls "github.com/Bearnie-H/easy-tls"
+	"github.com/Bearnie-H/easy-tls/client"
+)
+
+func TestInMemorySources(T *testing.T) {
+
+	CA, err := easytls.NewCertificateAuthority("EasyTLS Test CA", 0)
+	if err != nil {
+		T.Fatalf("Failed to create CA - %s", err)
+	}
+
+	ServerCert, err := CA.IssueServerCertificate(easytls.CertificateOptions{CommonName: "server", IPAddresses: []net.IP{net.ParseIP("127.0.0.1")}})
+	if err != nil {
+		T.Fatalf("Failed to issue server certificate - %s", err)
+	}
+
+	ClientCert, err := CA.IssueClientCertificate(easytls.CertificateOptions{CommonName: "client"})
+	if err != nil {
+		T.Fatalf("Failed to issue client certificate - %s", err)
+	}
+
+	// The server is built from raw PEM bytes.
+	ServerKey, err := ServerCert.KeyPEM()
+	if err != nil {
+		T.Fatalf("Failed to encode key - %s", err)
+	}
+	ServerBundle := easytls.NewTLSBundleFromPEM(ServerCert.CertificatePEM(), ServerKey, CA.CertificatePEM())
+	ServerBundle.Auth = tls.RequireAndVerifyClientCert
+
+	// The client is built from a filesystem, a pre-loaded certificate and an existing pool.
+	ClientKey, err := ClientCert.KeyPEM()
+	if err != nil {
+		T.Fatalf("Failed to encode key - %s", err)
+	}
+	Pool := x509.NewCertPool()
+	Pool.AddCert(CA.Certificate)
+
+	FS := fstest.MapFS{
+		"certs/client.crt": &fstest.MapFile{Data: ClientCert.CertificatePEM()},
+		"certs/client.key": &fstest.MapFile{Data: ClientKey},
+	}
+
+	for Name, ClientBundle := range map[string]*easytls.TLSBundle{
+		"fs.FS":           {FS: FS, KeyPair: easytls.KeyPair{Certificate: "certs/client.crt", Key: "certs/client.key"}, AuthorityPool: Pool, Enabled: true},
+		"tls.Certificate": {Certificates: []tls.Certificate{ClientCert.TLSCertificate()}, AuthorityPEM: [][]byte{CA.CertificatePEM()}, Enabled: true},
+	} {
+		URL := startTestServer(T, ServerBundle)
+
+		C, err := client.NewClientHTTPS(ClientBundle)
+		if err != nil {
+			T.Fatalf("[%s] Failed to create client - %s", Name, err)
+		}
+
+		resp, err := C.Get(URL+"/hello", nil)
+		if err != nil {
+			T.Fatalf("[%s] Failed to perform request - %s", Name, err)
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestPolicyValidation(T *testing.T) {
+
+	for _, Preset := range []string{easytls.PolicyModern, easytls.PolicyIntermediate, easytls.PolicyFIPS} {
+		P, err := easytls.NewTLSPolicy(Preset)
+		if err != nil {
+			T.Fatalf("Failed to create preset [ %s ] - %s", Preset, err)
+		}
+		if err := P.Validate(); err != nil {
+			T.Fatalf("Expected preset [ %s ] to be valid - %s", Preset, err)
+		}
+	}
+
+	for Name, P := range map[string]easytls.TLSPolicy{
+		"min above max":         {MinVersion: tls.VersionTLS13, MaxVersion: tls.VersionTLS12},
+		"insecure version":      {MinVersion: tls.VersionTLS10},
+		"suites with only 1.3":  {CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}},
+		"insecure suite":        {MinVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_RSA_WITH_RC4_128_SHA}},
+		"missing HTTP/2 suite":  {MinVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}},
+		"renegotiate with 1.3":  {Renegotiation: tls.RenegotiateOnceAsClient},
+		"unknown curve":         {CurvePreferences: []tls.CurveID{42}},
+		"empty ALPN identifier": {NextProtos: []string{""}},
+	} {
+		if err := P.Validate(); err == nil {
+			T.Errorf("Expected policy [ %s ] to be rejected", Name)
+		}
+	}
+}
+
+func TestPolicyTLS12(T *testing.T) {
+
+	_, ServerBundle, ClientBundle := newTestAuthority(T)
+
+	Policy, err := easytls.NewTLSPolicy(easytls.PolicyIntermediate)
+	if err != nil {
+		T.Fatalf("Failed to create policy - %s", err)
+	}
+
+	ServerBundle.Policy = Policy
+	ClientBundle.Policy = Policy
+	ClientBundle.Policy.MaxVersion = tls.VersionTLS12
+
+	URL := startTestServer(T, ServerBundle)
+
+	C, err := client.NewClientHTTPS(ClientBundle)
+	if err != nil {
+		T.Fatalf("Failed to create client - %s", err)
+	}
+
+	resp, err := C.Get(URL+"/hello", nil)
+	if err != nil {
+		T.Fatalf("Failed to perform TLS 1.2 request - %s", err)
+	}
+	resp.Body.Close()
+
+	if resp.TLS.Version != tls.VersionTLS12 {
+		T.Fatalf("Expected TLS 1.2 to be negotiated, got [ 0x%04X ]", resp.TLS.Version)
+	}
+}
+
+func TestSeparateAuthorities(T *testing.T) {
+
+	// ServerCA issues the server certificate, and ClientCA issues the client certificate.
+	ServerCA, ServerBundle, _ := newTestAuthority(T)
+	ClientCA, _, ClientBundle := newTestAuthority(T)
+
+	ServerBundle.AuthorityCertificates = nil
+	ServerBundle.ClientAuthorities = &easytls.AuthoritySet{PEM: [][]byte{ClientCA.CertificatePEM()}}
+
+	ClientBundle.AuthorityCertificates = nil
+	ClientBundle.ServerAuthorities = &easytls.AuthoritySet{PEM: [][]byte{ServerCA.CertificatePEM()}}
+
+	URL := startTestServer(T, ServerBundle)
+
+	C, err := client.NewClientHTTPS(ClientBundle)
+	if err != nil {
+		T.Fatalf("Failed to create client - %s", err)
+	}
+
+	resp, err := C.Get(URL+"/hello", nil)
+	if err != nil {
+		T.Fatalf("Failed to perform request with separate authorities - %s", err)
+	}
+	resp.Body.Close()
+
+	// A client certificate from the server's CA must not be trusted for clients.
+	Mismatched, err := ServerCA.IssueClientCertificate(easytls.CertificateOptions{CommonName: "mismatched"})
+	if err != nil {
+		T.Fatalf("Failed to issue client certificate - %s", err)
+	}
+	ClientBundle.Certificates = []tls.Certificate{Mismatched.TLSCertificate()}
+	ClientBundle.KeyPair = easytls.KeyPair{}
+
+	C, err = client.NewClientHTTPS(ClientBundle)
+	if err != nil {
+		T.Fatalf("Failed to create client - %s", err)
+	}
+
+	if resp, err := C.Get(URL+"/hello", nil); err == nil {
+		resp.Body.Close()
+		T.Fatalf("Expected client certificate from the server authority to be rejected")
+	}
+
+	// Merging an existing pool with the System pool is not possible.
+	ClientBundle.IncludeSystemAuthorities = true
+	ClientBundle.ServerAuthorities.Pool = x509.NewCertPool()
+	if err := ClientBundle.Validate(); err == nil {
+		T.Fatalf("Expected merging a Pool with the System pool to be rejected")
+	}
+}
+
+// EnvSystemAuthoritiesTestChild marks the process started by
+// TestSystemAuthoritiesNotTrustedForClients, which has its own System
+// Certificate Pool.
+const EnvSystemAuthoritiesTestChild = "EASYTLS_TEST_SYSTEM_AUTHORITIES_CHILD"
+
+func TestSystemAuthoritiesNotTrustedForClients(T *testing.T) {
+
+	// The System Certificate Pool is only loaded once per process, so the
+	// test runs in a new process with a "public" authority added to it.
+	if os.Getenv(EnvSystemAuthoritiesTestChild) == "" {
+		if runtime.GOOS != "linux" {
+			T.Skip("The System Certificate Pool can only be replaced on Linux")
+		}
+		Cmd := exec.Command(os.Args[0], "-test.run=^TestSystemAuthoritiesNotTrustedForClients$")
+		Cmd.Env = append(os.Environ(), EnvSystemAuthoritiesTestChild+"=1")
+		if Output, err := Cmd.CombinedOutput(); err != nil {
+			T.Fatalf("Child test failed - %s\n%s", err, Output)
+		}
+		return
+	}
+
+	PublicCA, err := easytls.NewCertificateAuthority("EasyTLS Public CA", 0)
+	if err != nil {
+		T.Fatalf("Failed to create CA - %s", err)
+	}
+
+	SystemFile := filepath.Join(T.TempDir(), "system.pem")
+	if err := os.WriteFile(SystemFile, PublicCA.CertificatePEM(), 0600); err != nil {
+		T.Fatalf("Failed to write System Certificate Pool - %s", err)
+	}
+	os.Setenv("SSL_CERT_FILE", SystemFile)
+	os.Setenv("SSL_CERT_DIR", T.TempDir())
+
+	PrivateCA, err := easytls.NewCertificateAuthority("EasyTLS Private CA", 0)
+	if err != nil {
+		T.Fatalf("Failed to create CA - %s", err)
+	}
+
+	ServerCert, err := PrivateCA.IssueServerCertificate(easytls.CertificateOptions{CommonName: "server", IPAddresses: []net.IP{net.ParseIP("127.0.0.1")}})
+	if err != nil {
+		T.Fatalf("Failed to issue server certificate - %s", err)
+	}
+	ServerKey, err := ServerCert.KeyPEM()
+	if err != nil {
+		T.Fatalf("Failed to encode key - %s", err)
+	}
+
+	ServerBundle := easytls.NewTLSBundleFromPEM(ServerCert.CertificatePEM(), ServerKey, PrivateCA.CertificatePEM())
+	ServerBundle.Auth = tls.RequireAndVerifyClientCert
+	ServerBundle.IncludeSystemAuthorities = true
+	URL := startTestServer(T, ServerBundle)
+
+	for _, Case := range []struct {
+		CA      *easytls.CertificateAuthority
+		Allowed bool
+	}{
+		{PrivateCA, true},
+		{PublicCA, false},
+	} {
+		ClientCert, err := Case.CA.IssueClientCertificate(easytls.CertificateOptions{CommonName: "billing-svc.example.com"})
+		if err != nil {
+			T.Fatalf("Failed to issue client certificate - %s", err)
+		}
+
+		C, err := client.NewClientHTTPS(&easytls.TLSBundle{
+			Certificates: []tls.Certificate{ClientCert.TLSCertificate()},
+			AuthorityPEM: [][]byte{PrivateCA.CertificatePEM()},
+			Enabled:      true,
+		})
+		if err != nil {
+			T.Fatalf("Failed to create client - %s", err)
+		}
+
+		resp, err := C.Get(URL+"/hello", nil)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if Case.Allowed && err != nil {
+			T.Fatalf("Expected client certificate from %s to be accepted - %s", Case.CA.Certificate.Subject.CommonName, err)
+		}
+		if !Case.Allowed && err == nil {
+			T.Fatalf("Expected client certificate from %s to be rejected", Case.CA.Certificate.Subject.CommonName)
+		}
+	}
+}
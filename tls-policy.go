@@ -0,0 +1,216 @@
+package easytls
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+)
+
+// Named TLSPolicy presets, to be used with NewTLSPolicy.
+const (
+	// PolicyModern only allows TLS 1.3, which provides its own fixed set of
+	// strong cipher suites. This is the default when no policy is given.
+	PolicyModern string = "modern"
+
+	// PolicyIntermediate allows TLS 1.2 and 1.3, restricted to forward-secret
+	// AEAD cipher suites, for compatibility with older peers.
+	PolicyIntermediate string = "intermediate"
+
+	// PolicyFIPS allows TLS 1.2 and 1.3, restricted to the AES-GCM cipher
+	// suites and NIST curves approved under FIPS 140.
+	PolicyFIPS string = "fips"
+)
+
+// TLSPolicy defines the protocol-level settings used during a TLS handshake.
+// The zero value is a valid policy, allowing only TLS 1.3 as PolicyModern
+// does, but leaving the curves to the defaults of crypto/tls.
+type TLSPolicy struct {
+
+	// MinVersion and MaxVersion bound the acceptable TLS versions.
+	// MinVersion defaults to TLS 1.3, MaxVersion to the highest supported.
+	MinVersion uint16
+	MaxVersion uint16
+
+	// CipherSuites is the set of enabled TLS 1.2 cipher suites.
+	// TLS 1.3 cipher suites are not configurable.
+	CipherSuites []uint16
+
+	// CurvePreferences is the set of elliptic curves to use for key
+	// exchange, in preference order. Defaults to those of crypto/tls.
+	CurvePreferences []tls.CurveID
+
+	// NextProtos is the set of supported ALPN protocols, in preference order.
+	NextProtos []string
+
+	// SessionTicketsDisabled turns off session ticket resumption.
+	SessionTicketsDisabled bool
+
+	// Renegotiation defines what types of renegotiation are supported.
+	// This only applies to clients, and only for TLS 1.2.
+	Renegotiation tls.RenegotiationSupport
+}
+
+// NewTLSPolicy will return the TLSPolicy for the given named preset.
+func NewTLSPolicy(Preset string) (TLSPolicy, error) {
+
+	switch Preset {
+	case PolicyModern:
+		return TLSPolicy{
+			MinVersion:       tls.VersionTLS13,
+			CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+		}, nil
+	case PolicyIntermediate:
+		return TLSPolicy{
+			MinVersion: tls.VersionTLS12,
+			CipherSuites: []uint16{
+				tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+				tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+				tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+				tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+				tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+			},
+			CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+		}, nil
+	case PolicyFIPS:
+		return TLSPolicy{
+			MinVersion: tls.VersionTLS12,
+			MaxVersion: tls.VersionTLS13,
+			CipherSuites: []uint16{
+				tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+				tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+				tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			},
+			CurvePreferences: []tls.CurveID{tls.CurveP256, tls.CurveP384},
+		}, nil
+	default:
+		return TLSPolicy{}, fmt.Errorf("easytls policy error: Unknown policy preset [ %s ]", Preset)
+	}
+}
+
+// Validate will check that the policy is internally consistent, and only
+// makes use of secure protocol versions, cipher suites and curves.
+func (P *TLSPolicy) Validate() error {
+
+	Min, Max := P.versions()
+
+	for _, V := range []uint16{Min, Max} {
+		switch V {
+		case tls.VersionTLS12, tls.VersionTLS13:
+		case tls.VersionTLS10, tls.VersionTLS11, tls.VersionSSL30:
+			return fmt.Errorf("easytls policy error: Insecure TLS version [ %s ] is not allowed", versionName(V))
+		default:
+			return fmt.Errorf("easytls policy error: Unknown TLS version [ %s ]", versionName(V))
+		}
+	}
+
+	if Min > Max {
+		return fmt.Errorf("easytls policy error: MinVersion [ %s ] is greater than MaxVersion [ %s ]", versionName(Min), versionName(Max))
+	}
+
+	if len(P.CipherSuites) > 0 {
+
+		if Min == tls.VersionTLS13 {
+			return errors.New("easytls policy error: CipherSuites cannot be configured when only TLS 1.3 is allowed")
+		}
+
+		Allowed := make(map[uint16]*tls.CipherSuite)
+		for _, Suite := range tls.CipherSuites() {
+			Allowed[Suite.ID] = Suite
+		}
+
+		HTTP2Compatible := false
+		for _, ID := range P.CipherSuites {
+			Suite, ok := Allowed[ID]
+			if !ok {
+				return fmt.Errorf("easytls policy error: Cipher suite [ %s ] is insecure or unknown", tls.CipherSuiteName(ID))
+			}
+
+			onlyTLS13 := true
+			for _, V := range Suite.SupportedVersions {
+				if V != tls.VersionTLS13 {
+					onlyTLS13 = false
+				}
+			}
+			if onlyTLS13 {
+				return fmt.Errorf("easytls policy error: Cipher suite [ %s ] is TLS 1.3 only, and cannot be configured", Suite.Name)
+			}
+
+			if ID == tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 || ID == tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+				HTTP2Compatible = true
+			}
+		}
+
+		// HTTP/2 over TLS 1.2 mandates one of these suites, and servers
+		// will refuse to start without them.
+		if !HTTP2Compatible {
+			return errors.New("easytls policy error: CipherSuites must include an ECDHE AES_128_GCM_SHA256 cipher suite for HTTP/2")
+		}
+	}
+
+	for _, Curve := range P.CurvePreferences {
+		switch Curve {
+		case tls.X25519, tls.CurveP256, tls.CurveP384, tls.CurveP521:
+		default:
+			return fmt.Errorf("easytls policy error: Unknown curve [ %s ]", Curve)
+		}
+	}
+
+	for _, Proto := range P.NextProtos {
+		if Proto == "" || len(Proto) > 255 {
+			return fmt.Errorf("easytls policy error: Invalid ALPN protocol [ %s ]", Proto)
+		}
+	}
+
+	if P.Renegotiation != tls.RenegotiateNever && Min == tls.VersionTLS13 {
+		return errors.New("easytls policy error: Renegotiation requires allowing TLS 1.2")
+	}
+
+	return nil
+}
+
+// apply will set the protocol-level settings of the tls.Config.
+func (P *TLSPolicy) apply(Config *tls.Config) {
+
+	Config.MinVersion, Config.MaxVersion = P.versions()
+	Config.CipherSuites = P.CipherSuites
+	Config.CurvePreferences = P.CurvePreferences
+	Config.NextProtos = P.NextProtos
+	Config.SessionTicketsDisabled = P.SessionTicketsDisabled
+	Config.Renegotiation = P.Renegotiation
+}
+
+// versions returns the effective minimum and maximum TLS versions, with defaults applied.
+func (P *TLSPolicy) versions() (Min, Max uint16) {
+
+	Min, Max = P.MinVersion, P.MaxVersion
+
+	// Default to the maximum supported version, sorry if this breaks old applications.
+	if Min == 0 {
+		Min = tls.VersionTLS13
+	}
+
+	if Max == 0 {
+		Max = tls.VersionTLS13
+	}
+
+	return Min, Max
+}
+
+func versionName(Version uint16) string {
+	switch Version {
+	case tls.VersionSSL30:
+		return "SSLv3"
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	default:
+		return fmt.Sprintf("0x%04X", Version)
+	}
+}
@@ -0,0 +1,25 @@
+// Package tracing implements a small, dependency-free distributed tracing
+// interface, compatible with OpenTelemetry and the W3C Trace Context.
+//
+// A Tracer starts Spans, each belonging to a trace and optionally to a parent
+// Span, carried between functions in a context.Context and between processes
+// in the "traceparent" header. The other packages of this library start spans
+// around the requests served by a SimpleServer, the requests performed by a
+// SimpleClient, the requests forwarded by the reverse proxy, and the Start,
+// Stop and Reload of plugin modules.
+//
+// The Default Tracer does nothing beyond propagating any incoming trace
+// context, so tracing costs nothing until a Tracer is installed with
+// SetDefault. To export spans, implement Tracer around an exporter such as
+// the OpenTelemetry SDK:
+//
+//	type otelTracer struct{ t trace.Tracer }
+//
+//	func (T otelTracer) Start(ctx context.Context, Name string, Kind tracing.SpanKind) (context.Context, tracing.Span) {
+//		ctx, S := T.t.Start(ctx, Name, trace.WithSpanKind(otelKind(Kind)))
+//		return ctx, otelSpan{S}
+//	}
+//
+// A Recorder keeps every span in memory, to assert the span trees produced
+// by a test.
+package tracing
@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// The W3C Trace Context headers.
+const (
+	HeaderTraceParent = "traceparent"
+	HeaderTraceState  = "tracestate"
+)
+
+// ErrInvalidTraceParent is returned when parsing a malformed traceparent header.
+var ErrInvalidTraceParent = errors.New("tracing error: Invalid traceparent header")
+
+// TraceParent formats the context as a W3C traceparent header value.
+func (C SpanContext) TraceParent() string {
+	Flags := 0
+	if C.Sampled {
+		Flags = 1
+	}
+	return fmt.Sprintf("00-%s-%s-%02x", C.TraceID, C.SpanID, Flags)
+}
+
+// ParseTraceParent parses a W3C traceparent header value.
+func ParseTraceParent(Value string) (SpanContext, error) {
+
+	C := SpanContext{}
+
+	// Later versions may append fields, but must keep this prefix.
+	if len(Value) < 55 || Value[2] != '-' || Value[35] != '-' || Value[52] != '-' {
+		return C, ErrInvalidTraceParent
+	}
+
+	Version, err := hex.DecodeString(Value[0:2])
+	if err != nil || Version[0] == 0xff || (Version[0] == 0 && len(Value) != 55) || (len(Value) > 55 && Value[55] != '-') {
+		return C, ErrInvalidTraceParent
+	}
+
+	if _, err := hex.Decode(C.TraceID[:], []byte(Value[3:35])); err != nil {
+		return C, ErrInvalidTraceParent
+	}
+
+	if _, err := hex.Decode(C.SpanID[:], []byte(Value[36:52])); err != nil {
+		return C, ErrInvalidTraceParent
+	}
+
+	Flags, err := hex.DecodeString(Value[53:55])
+	if err != nil || !C.IsValid() {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+	C.Sampled = Flags[0]&1 == 1
+
+	return C, nil
+}
+
+// Inject writes the context of the Span carried by ctx into the headers of
+// an outgoing request, replacing any already present. Nothing is written if
+// ctx carries no valid Span context.
+func Inject(ctx context.Context, H http.Header) {
+
+	C := SpanContextFromContext(ctx)
+	if !C.IsValid() {
+		return
+	}
+
+	H.Set(HeaderTraceParent, C.TraceParent())
+	if C.TraceState != "" {
+		H.Set(HeaderTraceState, C.TraceState)
+	} else {
+		H.Del(HeaderTraceState)
+	}
+}
+
+// Extract returns a copy of ctx carrying the Span context in the headers of
+// an incoming request, as the remote parent of the next Span. The context is
+// returned unchanged if the headers carry no valid traceparent.
+func Extract(ctx context.Context, H http.Header) context.Context {
+
+	C, err := ParseTraceParent(H.Get(HeaderTraceParent))
+	if err != nil {
+		return ctx
+	}
+	C.TraceState = H.Get(HeaderTraceState)
+
+	return ContextWithRemoteSpanContext(ctx, C)
+}
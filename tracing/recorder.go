@@ -0,0 +1,154 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RecordedSpan is a completed Span, as kept by a Recorder.
+type RecordedSpan struct {
+	Name        string
+	Kind        SpanKind
+	SpanContext SpanContext
+	Parent      SpanContext
+	Attributes  map[string]interface{}
+	Err         error
+	Start       time.Time
+	End         time.Time
+}
+
+// Recorder is a Tracer which keeps every completed Span in memory, for
+// asserting the span trees produced by a test.
+type Recorder struct {
+	mu    *sync.Mutex
+	spans []RecordedSpan
+}
+
+// NewRecorder will create a new, empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		mu: &sync.Mutex{},
+	}
+}
+
+// Start implements Tracer, starting a sampled Span which is recorded when it ends.
+func (R *Recorder) Start(ctx context.Context, Name string, Kind SpanKind) (context.Context, Span) {
+
+	Parent := SpanContextFromContext(ctx)
+
+	S := &recordedSpan{
+		recorder: R,
+		mu:       &sync.Mutex{},
+		data: RecordedSpan{
+			Name:       Name,
+			Kind:       Kind,
+			Parent:     Parent,
+			Attributes: make(map[string]interface{}),
+			Start:      time.Now(),
+		},
+	}
+
+	C := SpanContext{Sampled: true, TraceState: Parent.TraceState}
+	if Parent.IsValid() {
+		C.TraceID = Parent.TraceID
+	} else {
+		rand.Read(C.TraceID[:])
+	}
+	rand.Read(C.SpanID[:])
+	S.data.SpanContext = C
+
+	return ContextWithSpan(ctx, S), S
+}
+
+// Spans returns the completed Spans, in the order they ended.
+func (R *Recorder) Spans() []RecordedSpan {
+	R.mu.Lock()
+	defer R.mu.Unlock()
+	return append([]RecordedSpan{}, R.spans...)
+}
+
+// Find returns the first completed Span with the given name.
+func (R *Recorder) Find(Name string) (RecordedSpan, bool) {
+	for _, S := range R.Spans() {
+		if S.Name == Name {
+			return S, true
+		}
+	}
+	return RecordedSpan{}, false
+}
+
+// Children returns the completed Spans whose parent is the given Span.
+func (R *Recorder) Children(Parent RecordedSpan) []RecordedSpan {
+	Children := []RecordedSpan{}
+	for _, S := range R.Spans() {
+		if S.Parent.SpanID == Parent.SpanContext.SpanID && S.Parent.TraceID == Parent.SpanContext.TraceID {
+			Children = append(Children, S)
+		}
+	}
+	return Children
+}
+
+// Reset discards all of the completed Spans.
+func (R *Recorder) Reset() {
+	R.mu.Lock()
+	defer R.mu.Unlock()
+	R.spans = nil
+}
+
+type recordedSpan struct {
+	recorder *Recorder
+	mu       *sync.Mutex
+	data     RecordedSpan
+	ended    bool
+}
+
+func (S *recordedSpan) SpanContext() SpanContext {
+	return S.data.SpanContext
+}
+
+func (S *recordedSpan) SetAttributes(KeysAndValues ...interface{}) {
+
+	S.mu.Lock()
+	defer S.mu.Unlock()
+
+	if S.ended {
+		return
+	}
+
+	for i := 0; i < len(KeysAndValues); i += 2 {
+		Key := fmt.Sprint(KeysAndValues[i])
+		var Value interface{}
+		if i+1 < len(KeysAndValues) {
+			Value = KeysAndValues[i+1]
+		}
+		S.data.Attributes[Key] = Value
+	}
+}
+
+func (S *recordedSpan) RecordError(err error) {
+	S.mu.Lock()
+	defer S.mu.Unlock()
+	if !S.ended {
+		S.data.Err = err
+	}
+}
+
+func (S *recordedSpan) End() {
+
+	S.mu.Lock()
+	if S.ended {
+		S.mu.Unlock()
+		return
+	}
+	S.ended = true
+	S.data.End = time.Now()
+	Data := S.data
+	S.mu.Unlock()
+
+	S.recorder.mu.Lock()
+	S.recorder.spans = append(S.recorder.spans, Data)
+	S.recorder.mu.Unlock()
+}
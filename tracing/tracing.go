@@ -0,0 +1,175 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"sync/atomic"
+)
+
+// The attribute keys set on the spans started by this library, following the
+// OpenTelemetry semantic conventions where one exists.
+const (
+	AttrHTTPMethod     = "http.request.method"
+	AttrHTTPRoute      = "http.route"
+	AttrHTTPStatusCode = "http.response.status_code"
+	AttrURLFull        = "url.full"
+	AttrURLPath        = "url.path"
+	AttrServerAddress  = "server.address"
+	AttrRequestID      = "easytls.request_id"
+	AttrPluginModule   = "easytls.plugin.module"
+)
+
+// SpanKind describes the relationship of a Span to the other spans of its trace.
+type SpanKind int
+
+// The kinds of Span.
+const (
+	SpanKindInternal SpanKind = iota
+	SpanKindServer
+	SpanKindClient
+)
+
+func (K SpanKind) String() string {
+	switch K {
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	default:
+		return "internal"
+	}
+}
+
+// TraceID identifies a single trace.
+type TraceID [16]byte
+
+// IsValid returns whether the ID is not all zeroes.
+func (ID TraceID) IsValid() bool { return ID != TraceID{} }
+
+func (ID TraceID) String() string { return hex.EncodeToString(ID[:]) }
+
+// SpanID identifies a single Span within a trace.
+type SpanID [8]byte
+
+// IsValid returns whether the ID is not all zeroes.
+func (ID SpanID) IsValid() bool { return ID != SpanID{} }
+
+func (ID SpanID) String() string { return hex.EncodeToString(ID[:]) }
+
+// SpanContext is the part of a Span propagated to its children, including
+// those in other processes.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+
+	// Sampled indicates the trace is being recorded by the caller.
+	Sampled bool
+
+	// TraceState is the vendor-specific "tracestate" header, passed on unchanged.
+	TraceState string
+
+	// Remote indicates the context was received from another process.
+	Remote bool
+}
+
+// IsValid returns whether the context identifies a Span.
+func (C SpanContext) IsValid() bool {
+	return C.TraceID.IsValid() && C.SpanID.IsValid()
+}
+
+// Span is a single timed operation within a trace.
+type Span interface {
+
+	// SpanContext returns the context propagated to the children of the Span.
+	SpanContext() SpanContext
+
+	// SetAttributes attaches the given alternating key/value pairs to the Span.
+	SetAttributes(KeysAndValues ...interface{})
+
+	// RecordError marks the Span as failed with the given error.
+	RecordError(err error)
+
+	// End completes the Span. Calls after the first have no effect.
+	End()
+}
+
+// Tracer starts Spans. Implementations wrap a tracing backend, such as the
+// OpenTelemetry SDK, to export them.
+type Tracer interface {
+
+	// Start begins a new Span as a child of the Span carried by ctx, if any,
+	// returning it along with a copy of ctx carrying it.
+	Start(ctx context.Context, Name string, Kind SpanKind) (context.Context, Span)
+}
+
+type tracerHolder struct{ Tracer }
+
+var global atomic.Value
+
+func init() {
+	global.Store(tracerHolder{Noop()})
+}
+
+// Default returns the Tracer used by this library when none is explicitly
+// given. This is a no-op Tracer until SetDefault is called.
+func Default() Tracer {
+	return global.Load().(tracerHolder).Tracer
+}
+
+// SetDefault replaces the Tracer returned by Default. A nil Tracer restores
+// the no-op Tracer.
+func SetDefault(T Tracer) {
+	if T == nil {
+		T = Noop()
+	}
+	global.Store(tracerHolder{T})
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns a copy of ctx carrying the given Span.
+func ContextWithSpan(ctx context.Context, S Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, S)
+}
+
+// SpanFromContext returns the Span carried by ctx, or a no-op Span if there is none.
+func SpanFromContext(ctx context.Context) Span {
+	if S, ok := ctx.Value(spanKey{}).(Span); ok {
+		return S
+	}
+	return noopSpan{}
+}
+
+// SpanContextFromContext returns the context of the Span carried by ctx,
+// which is invalid if there is none.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	return SpanFromContext(ctx).SpanContext()
+}
+
+// ContextWithRemoteSpanContext returns a copy of ctx carrying a Span context
+// received from another process, to be used as the parent of the next Span.
+func ContextWithRemoteSpanContext(ctx context.Context, C SpanContext) context.Context {
+	C.Remote = true
+	return ContextWithSpan(ctx, noopSpan{context: C})
+}
+
+// Noop returns a Tracer which records nothing. Its Spans carry the context of
+// their parent, so an incoming trace context is still propagated onwards.
+func Noop() Tracer {
+	return noopTracer{}
+}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, Name string, Kind SpanKind) (context.Context, Span) {
+	return ctx, noopSpan{context: SpanContextFromContext(ctx)}
+}
+
+type noopSpan struct {
+	context SpanContext
+}
+
+func (S noopSpan) SpanContext() SpanContext                   { return S.context }
+func (S noopSpan) SetAttributes(KeysAndValues ...interface{}) {}
+func (S noopSpan) RecordError(err error)                      {}
+func (S noopSpan) End()                                       {}
@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestParseTraceParent(T *testing.T) {
+
+	const Valid = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	C, err := ParseTraceParent(Valid)
+	if err != nil {
+		T.Fatalf("Failed to parse valid traceparent: %v", err)
+	}
+
+	if C.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || C.SpanID.String() != "00f067aa0ba902b7" || !C.Sampled {
+		T.Fatalf("Unexpected span context: %+v", C)
+	}
+
+	if C.TraceParent() != Valid {
+		T.Fatalf("Expected the traceparent to round-trip, got [ %s ]", C.TraceParent())
+	}
+
+	for _, Invalid := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-4bf92f3577b34da6a3ce929d0e0e473g-00f067aa0ba902b7-01",
+	} {
+		if _, err := ParseTraceParent(Invalid); err == nil {
+			T.Fatalf("Expected traceparent [ %s ] to be rejected", Invalid)
+		}
+	}
+
+	if _, err := ParseTraceParent("01" + Valid[2:] + "-future"); err != nil {
+		T.Fatalf("Expected a later version with extra fields to be accepted: %v", err)
+	}
+}
+
+func TestNoopPropagation(T *testing.T) {
+
+	In := http.Header{}
+	In.Set(HeaderTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	In.Set(HeaderTraceState, "vendor=value")
+
+	ctx, Span := Noop().Start(Extract(context.Background(), In), "operation", SpanKindServer)
+	Span.End()
+
+	Out := http.Header{}
+	Inject(ctx, Out)
+
+	if Out.Get(HeaderTraceParent) != In.Get(HeaderTraceParent) || Out.Get(HeaderTraceState) != "vendor=value" {
+		T.Fatalf("Expected the no-op Tracer to propagate the incoming context, got %v", Out)
+	}
+
+	Out = http.Header{}
+	Inject(context.Background(), Out)
+	if len(Out) != 0 {
+		T.Fatalf("Expected nothing to be injected without a span context, got %v", Out)
+	}
+}
+
+func TestRecorderTree(T *testing.T) {
+
+	R := NewRecorder()
+
+	ctx, Root := R.Start(context.Background(), "root", SpanKindServer)
+	_, Child := R.Start(ctx, "child", SpanKindClient)
+	Child.SetAttributes("key", "value")
+	Child.End()
+	Child.End()
+	Root.End()
+
+	if len(R.Spans()) != 2 {
+		T.Fatalf("Expected 2 spans to be recorded, got %d", len(R.Spans()))
+	}
+
+	RootSpan, _ := R.Find("root")
+	Children := R.Children(RootSpan)
+	if len(Children) != 1 || Children[0].Name != "child" || Children[0].Attributes["key"] != "value" {
+		T.Fatalf("Unexpected children of the root span: %+v", Children)
+	}
+
+	if Children[0].SpanContext.TraceID != RootSpan.SpanContext.TraceID || RootSpan.Parent.IsValid() {
+		T.Fatalf("Expected the child to share the trace of the root span")
+	}
+}